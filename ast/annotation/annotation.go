@@ -1,11 +1,14 @@
 package annotation
 
 import (
+	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/oleiade/gomme"
 
+	"github.com/yisaer/idl-parser/ast/typ"
 	"github.com/yisaer/idl-parser/ast/utils"
 )
 
@@ -20,7 +23,7 @@ func parseValidChar(code string) gomme.Result[string, string] {
 	remaining := code
 	for len(remaining) > 0 {
 		r, size := utf8.DecodeRuneInString(remaining)
-		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '/') {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("./-+_", r)) {
 			break
 		}
 		matched += string(r)
@@ -32,22 +35,59 @@ func parseValidChar(code string) gomme.Result[string, string] {
 	return gomme.Success[string, string](matched, remaining)
 }
 
+// parseQuotedString parses one or more adjacent string literals, such as
+// "a" "\n" "b", and returns their contents joined. The escapes \", \\, \n,
+// \t and \r are decoded; any other backslash is kept as written.
 func parseQuotedString(code string) gomme.Result[string, string] {
-	return gomme.Delimited(
-		gomme.Token[string](`"`),
-		parseValidChar,
-		gomme.Token[string](`"`),
-	)(code)
+	var b strings.Builder
+	rest := code
+	for n := 0; ; n++ {
+		lit := rest
+		if n > 0 {
+			lit = utils.ParseEmpty0(rest).Remaining
+		}
+		if !strings.HasPrefix(lit, `"`) {
+			if n == 0 {
+				return gomme.Failure[string, string](gomme.NewError[string](code, "string literal"), code)
+			}
+			return gomme.Success(b.String(), rest)
+		}
+		i := 1
+		for ; i < len(lit) && lit[i] != '"'; i++ {
+			if lit[i] != '\\' || i+1 == len(lit) {
+				b.WriteByte(lit[i])
+				continue
+			}
+			i++
+			switch lit[i] {
+			case '"', '\\':
+				b.WriteByte(lit[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(lit[i])
+			}
+		}
+		if i == len(lit) {
+			return gomme.Failure[string, string](gomme.NewError[string](lit, "closing quote"), code)
+		}
+		rest = lit[i+1:]
+	}
 }
 
 func parseKVPairs(code string) gomme.Result[map[string]string, string] {
 	return gomme.Map(gomme.SeparatedList0(
 		gomme.SeparatedPair(
-			gomme.Recognize(
+			utils.InLeftEmpty(gomme.Recognize(
 				gomme.Pair(
 					gomme.Alpha1[string](),
 					gomme.Alphanumeric0[string](),
-				)),
+				))),
 			utils.InEmpty(gomme.Token[string]("=")),
 			gomme.Alternative(
 				parseQuotedString,
@@ -65,6 +105,19 @@ func parseKVPairs(code string) gomme.Result[map[string]string, string] {
 		})(code)
 }
 
+// parsePositional parses the single unnamed value of an annotation such as
+// @length_from(count), stored under the "value" key.
+func parsePositional(code string) gomme.Result[map[string]string, string] {
+	return gomme.Map(
+		utils.InEmpty(gomme.Alternative(
+			parseQuotedString,
+			parseValidChar,
+		)),
+		func(value string) (map[string]string, error) {
+			return map[string]string{"value": value}, nil
+		})(code)
+}
+
 func ParseAnnotation(code string) gomme.Result[Annotation, string] {
 	return gomme.Map(
 		gomme.SeparatedPair(
@@ -74,10 +127,12 @@ func ParseAnnotation(code string) gomme.Result[Annotation, string] {
 			),
 			gomme.Whitespace0[string](),
 			gomme.Optional(
-				gomme.Delimited(
+				gomme.Preceded(
 					gomme.Token[string]("("),
-					parseKVPairs,
-					gomme.Token[string](")"),
+					gomme.Alternative(
+						gomme.Terminated(parseKVPairs, utils.InLeftEmpty(gomme.Token[string](")"))),
+						gomme.Terminated(parsePositional, gomme.Token[string](")")),
+					),
 				),
 			),
 		),
@@ -108,3 +163,43 @@ func ParseAnnotations(code string) gomme.Result[Annotations, string] {
 		},
 	)(code)
 }
+
+func (a Annotations) Get(name string) (Annotation, bool) {
+	for _, anno := range a {
+		if anno.Name == name {
+			return anno, true
+		}
+	}
+	return Annotation{}, false
+}
+
+// Extensibility returns the extensibility kind declared by
+// @extensibility(KIND) or by one of the shorthands @final, @appendable and
+// @mutable, and whether one is declared.
+func (a Annotations) Extensibility() (typ.Extensibility, bool, error) {
+	var ext typ.Extensibility
+	found := false
+	for _, anno := range a {
+		var e typ.Extensibility
+		switch anno.Name {
+		case "extensibility":
+			var err error
+			if e, err = typ.ParseExtensibility(anno.Values["value"]); err != nil {
+				return 0, false, err
+			}
+		case "final":
+			e = typ.FinalExtensibility
+		case "appendable":
+			e = typ.AppendableExtensibility
+		case "mutable":
+			e = typ.MutableExtensibility
+		default:
+			continue
+		}
+		if found && e != ext {
+			return 0, false, fmt.Errorf("conflicting extensibility %v and %v", ext, e)
+		}
+		ext, found = e, true
+	}
+	return ext, found, nil
+}
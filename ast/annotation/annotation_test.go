@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast/typ"
 )
 
 func TestAnnotation(t *testing.T) {
@@ -20,6 +22,17 @@ func TestAnnotation(t *testing.T) {
 		{`@format(a = "b", c = "d")`, Annotation{Name: "format", Values: map[string]string{"a": "b", "c": "d"}}},
 		{`@format(a = "b", c = 123)`, Annotation{Name: "format", Values: map[string]string{"a": "b", "c": "123"}}},
 		{`@format(a = "b.c", c = 123)`, Annotation{Name: "format", Values: map[string]string{"a": "b.c", "c": "123"}}},
+		{"@length_from(count)", Annotation{Name: "length_from", Values: map[string]string{"value": "count"}}},
+		{"@length_prefix( 2 )", Annotation{Name: "length_prefix", Values: map[string]string{"value": "2"}}},
+		{`@format("b")`, Annotation{Name: "format", Values: map[string]string{"value": "b"}}},
+		{"@range(min=-40, max=1.5e3)", Annotation{Name: "range", Values: map[string]string{"min": "-40", "max": "1.5e3"}}},
+		{`@default("a b, c")`, Annotation{Name: "default", Values: map[string]string{"value": "a b, c"}}},
+		{"@default(RUNNING_STATE)", Annotation{Name: "default", Values: map[string]string{"value": "RUNNING_STATE"}}},
+		{`@default(value="say \"hi\"")`, Annotation{Name: "default", Values: map[string]string{"value": `say "hi"`}}},
+		{`@format("C:\\dir\\x")`, Annotation{Name: "format", Values: map[string]string{"value": `C:\dir\x`}}},
+		{"@verbatim (language=\"comment\", text=\n  \"Line one\" \"\\n\"\n  \"line two\")",
+			Annotation{Name: "verbatim", Values: map[string]string{"language": "comment", "text": "Line one\nline two"}}},
+		{"@default (value=0.0 )", Annotation{Name: "default", Values: map[string]string{"value": "0.0"}}},
 	}
 
 	for _, test := range tests {
@@ -43,3 +56,30 @@ func TestAnnotations(t *testing.T) {
 		require.Equal(t, test.expected, result.Output)
 	}
 }
+
+func TestAnnotationsExtensibility(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected typ.Extensibility
+		found    bool
+		err      string
+	}{
+		{`@key`, typ.FinalExtensibility, false, ""},
+		{`@extensibility(MUTABLE)`, typ.MutableExtensibility, true, ""},
+		{`@appendable @extensibility(APPENDABLE)`, typ.AppendableExtensibility, true, ""},
+		{`@extensibility`, 0, false, `unknown extensibility "", expect FINAL, APPENDABLE or MUTABLE`},
+		{`@mutable @final`, 0, false, "conflicting extensibility MUTABLE and FINAL"},
+	}
+	for _, test := range tests {
+		result := ParseAnnotations(test.input)
+		require.Nil(t, result.Err)
+		ext, found, err := result.Output.Extensibility()
+		if test.err != "" {
+			require.EqualError(t, err, test.err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, test.expected, ext)
+		require.Equal(t, test.found, found)
+	}
+}
@@ -1,11 +1,18 @@
 package ast
 
 import (
+	"fmt"
+
 	"github.com/oleiade/gomme"
 
 	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
 	"github.com/yisaer/idl-parser/ast/struct_type"
 	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/union_type"
 	"github.com/yisaer/idl-parser/ast/utils"
 )
 
@@ -35,7 +42,7 @@ func Parse(code string) gomme.Result[Module, string] {
 	}
 	nameResult :=
 		utils.InEmpty(
-			gomme.Recognize(gomme.Pair(gomme.Alpha1[string](), gomme.Alphanumeric0[string]())),
+			utils.Identifier,
 		)(moduleTokenResult.Remaining)
 	if nameResult.Err != nil {
 		return gomme.Failure[string, Module](nameResult.Err, code)
@@ -46,12 +53,20 @@ func Parse(code string) gomme.Result[Module, string] {
 			gomme.Terminated(gomme.Alternative(
 				gomme.Map(bitset.Parse, func(output bitset.BitSet) (ModuleContent, error) { return output, nil }),
 				gomme.Map(struct_type.Parse, func(output struct_type.Struct) (ModuleContent, error) { return output, nil }),
+				gomme.Map(enum_type.Parse, func(output enum_type.Enum) (ModuleContent, error) { return output, nil }),
+				gomme.Map(union_type.Parse, func(output union_type.Union) (ModuleContent, error) { return output, nil }),
+				gomme.Map(typedef_type.Parse, func(output typedef_type.Typedef) (ModuleContent, error) { return output, nil }),
+				gomme.Map(const_type.Parse, func(output const_type.Const) (ModuleContent, error) { return output, nil }),
+				gomme.Map(forward_type.Parse, func(output forward_type.Forward) (ModuleContent, error) { return output, nil }),
 				gomme.Map(Parse, func(output Module) (ModuleContent, error) { return output, nil }),
 			),
 				gomme.Optional(utils.InEmpty(gomme.Token[string](";"))),
 			),
 		)),
-		utils.InEmpty(gomme.Token[string]("}")),
+		gomme.Terminated(
+			utils.InEmpty(gomme.Token[string]("}")),
+			gomme.Optional(utils.InEmpty(gomme.Token[string](";"))),
+		),
 	)(nameResult.Remaining)
 	if contentResult.Err != nil {
 		return gomme.Failure[string, Module](contentResult.Err, code)
@@ -62,3 +77,82 @@ func Parse(code string) gomme.Result[Module, string] {
 		Type:    typ.ModuleContentTypeToString(typ.ModuleType),
 	}, contentResult.Remaining)
 }
+
+// Merge joins module trees into one: modules of the same name at the same
+// level are combined, keeping their contents in order, which also joins a
+// module reopened within one tree. Trees with different root names are
+// placed under an unnamed root, whose contents the global scope holds
+// directly.
+func Merge(modules ...Module) Module {
+	if len(modules) == 0 {
+		return Module{}
+	}
+	root := Module{Name: modules[0].Name, Type: typ.ModuleContentTypeToString(typ.ModuleType)}
+	for _, m := range modules[1:] {
+		if m.Name != root.Name {
+			root.Name = ""
+		}
+	}
+	for _, m := range modules {
+		if root.Name == "" && m.Name != "" {
+			root.Content = mergeContent(root.Content, []ModuleContent{m})
+			continue
+		}
+		root.Content = mergeContent(root.Content, m.Content)
+	}
+	return root
+}
+
+// ParseSchema parses every top-level module of code and merges them, so a
+// module may be reopened at the top level too. Content other than modules
+// is an error.
+func ParseSchema(code string) (Module, error) {
+	modules, err := ParseModules(code)
+	if err != nil {
+		return Module{}, err
+	}
+	return Merge(modules...), nil
+}
+
+// ParseModules parses every top-level module of code in source order,
+// without merging them. The parse error of content after the first module
+// wraps the error of the parser.
+func ParseModules(code string) ([]Module, error) {
+	var modules []Module
+	for rest := code; len(modules) == 0 || rest != ""; {
+		res := Parse(rest)
+		if res.Err != nil {
+			if len(modules) > 0 {
+				return nil, fmt.Errorf("unexpected content after module %v: %w", modules[len(modules)-1].Name, res.Err)
+			}
+			return nil, res.Err
+		}
+		modules = append(modules, res.Output)
+		rest = utils.ParseEmpty0(res.Remaining).Remaining
+	}
+	return modules, nil
+}
+
+func mergeContent(dst, src []ModuleContent) []ModuleContent {
+	for _, con := range src {
+		m, ok := con.(Module)
+		if !ok {
+			dst = append(dst, con)
+			continue
+		}
+		merged := false
+		for i, prev := range dst {
+			if pm, ok := prev.(Module); ok && pm.Name == m.Name {
+				pm.Content = mergeContent(append([]ModuleContent(nil), pm.Content...), m.Content)
+				dst[i] = pm
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			m.Content = mergeContent(nil, m.Content)
+			dst = append(dst, m)
+		}
+	}
+	return dst
+}
@@ -8,8 +8,12 @@ import (
 
 	"github.com/yisaer/idl-parser/ast/annotation"
 	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
 	"github.com/yisaer/idl-parser/ast/struct_type"
 	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
 	"github.com/yisaer/idl-parser/ast/typeref"
 )
 
@@ -207,3 +211,92 @@ module outer {
 		require.Equal(t, test.expected, string(v))
 	}
 }
+
+func TestParseModuleWithEnum(t *testing.T) {
+	code := `module spi {
+		enum Status { OK, FAILED };
+		struct Reply {
+			Status status;
+		};
+	}`
+	result := Parse(code)
+	require.Nil(t, result.Err)
+	require.Len(t, result.Output.Content, 2)
+	require.Equal(t, enum_type.Enum{Name: "Status", Members: []string{"OK", "FAILED"}, Type: "Enum"}, result.Output.Content[0])
+	require.Equal(t, typ.EnumType, result.Output.Content[0].ModuleContentType())
+}
+
+func TestParseForward(t *testing.T) {
+	code := `module tree {
+		struct Node;
+		struct Node {
+			long value;
+			sequence<Node> children;
+		};
+	}`
+	result := Parse(code)
+	require.Nil(t, result.Err)
+	require.Len(t, result.Output.Content, 2)
+	require.Equal(t, forward_type.Forward{Kind: "struct", Name: "Node", Type: "Forward"}, result.Output.Content[0])
+
+	def, _, err := NewGlobalScope(result.Output).Resolve("tree::Node")
+	require.NoError(t, err)
+	require.Equal(t, typ.StructType, def.ModuleContentType())
+}
+
+func TestParseROSGenerated(t *testing.T) {
+	code := `// generated from rosidl_adapter/resource/msg.idl.em
+// with input from geometry_msgs/msg/PoseWithCovariance.msg
+// generated code does not contain a copyright notice
+
+#include "geometry_msgs/msg/Pose.idl"
+
+module geometry_msgs {
+  module msg {
+    typedef double double__36[36];
+    module PoseWithCovariance_Constants {
+      @verbatim (language="comment", text=
+        "Size of the covariance matrix side")
+      const uint8 SIDE = 6;
+    };
+    @verbatim (language="comment", text=
+      "This represents a pose in free space with uncertainty.")
+    struct PoseWithCovariance {
+      geometry_msgs::msg::Pose pose;
+
+      @verbatim (language="comment", text=
+        "Row-major representation of the 6x6 covariance matrix" "\n"
+        "The orientation parameters use a fixed-axis representation.")
+      double__36 covariance;
+
+      @default (value="map")
+      string<16> frame_id;
+    };
+  };
+};
+`
+	result := Parse(code)
+	require.Nil(t, result.Err)
+	require.Equal(t, "", result.Remaining)
+	msg := result.Output.Content[0].(Module)
+	require.Equal(t, "msg", msg.Name)
+	require.Len(t, msg.Content, 3)
+	require.Equal(t, typedef_type.Typedef{
+		Name:    "double__36",
+		Aliased: typeref.NewDoubleType(),
+		Dims:    []int{36},
+		Type:    "Typedef",
+	}, msg.Content[0])
+	constants := msg.Content[1].(Module)
+	require.Equal(t, "SIDE", constants.Content[0].(const_type.Const).Name)
+
+	st := msg.Content[2].(struct_type.Struct)
+	require.Equal(t, annotation.Annotations{{Name: "verbatim", Values: map[string]string{
+		"language": "comment",
+		"text":     "This represents a pose in free space with uncertainty.",
+	}}}, st.Annotations)
+	require.Equal(t, "Row-major representation of the 6x6 covariance matrix\nThe orientation parameters use a fixed-axis representation.",
+		st.Fields[1].Annotations[0].Values["text"])
+	require.Equal(t, typeref.NewBoundedString(16), st.Fields[2].Type)
+	require.Equal(t, annotation.Annotations{{Name: "default", Values: map[string]string{"value": "map"}}}, st.Fields[2].Annotations)
+}
@@ -33,9 +33,7 @@ func parseField(code string) gomme.Result[Field, string] {
 		gomme.SeparatedPair(
 			bitFieldParser,
 			gomme.Whitespace1[string](),
-			gomme.Recognize(
-				gomme.Pair(gomme.Alpha1[string](), gomme.Alphanumeric0[string]()),
-			),
+			gomme.Parser[string, string](utils.Identifier),
 		),
 		func(output gomme.PairContainer[typeref.BitFieldType, string]) (Field, error) {
 			return Field{
@@ -53,7 +51,7 @@ func Parse(code string) gomme.Result[BitSet, string] {
 	}
 	nameResult :=
 		utils.InEmpty(
-			gomme.Recognize(gomme.Pair(gomme.Alpha1[string](), gomme.Alphanumeric0[string]())),
+			utils.Identifier,
 		)(bitsetTokenResult.Remaining)
 	if nameResult.Err != nil {
 		return gomme.Failure[string, BitSet](nameResult.Err, code)
@@ -0,0 +1,81 @@
+package const_type
+
+import (
+	"strings"
+
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+// Const is a constant declaration such as "const uint8 MAX = 10". Value
+// holds the literal as written, string literals keeping their quotes.
+type Const struct {
+	Annotations annotation.Annotations `json:"annotations,omitempty"`
+	Name        string                 `json:"name"`
+	ValueType   typeref.TypeRef        `json:"value_type"`
+	Value       string                 `json:"value"`
+	Type        string                 `json:"type"`
+}
+
+func (c Const) GetName() string {
+	return c.Name
+}
+
+func (Const) ModuleContentType() typ.ModuleContentType {
+	return typ.ConstType
+}
+
+// parseValue takes the literal up to the ";" that ends the declaration,
+// skipping over semicolons inside string literals.
+func parseValue(code string) gomme.Result[string, string] {
+	quoted := false
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			if value := strings.TrimSpace(code[:i]); value != "" {
+				return gomme.Success(value, code[i:])
+			}
+			return gomme.Failure[string, string](gomme.NewError[string](code, "constant value"), code)
+		}
+	}
+	return gomme.Failure[string, string](gomme.NewError[string](code, "constant value"), code)
+}
+
+// Parse parses a constant declaration, optionally preceded by annotations.
+func Parse(code string) gomme.Result[Const, string] {
+	annotationsResult := annotation.ParseAnnotations(code)
+	constTokenResult := utils.InLeftEmpty(gomme.Terminated(gomme.Token[string]("const"), utils.ParseEmpty1))(annotationsResult.Remaining)
+	if constTokenResult.Err != nil {
+		return gomme.Failure[string, Const](constTokenResult.Err, code)
+	}
+	valueTypeResult := typeref.ParseTypeRef(constTokenResult.Remaining)
+	if valueTypeResult.Err != nil {
+		return gomme.Failure[string, Const](valueTypeResult.Err, code)
+	}
+	nameResult := utils.InEmpty(utils.Identifier)(valueTypeResult.Remaining)
+	if nameResult.Err != nil {
+		return gomme.Failure[string, Const](nameResult.Err, code)
+	}
+	valueResult := gomme.Preceded(gomme.Token[string]("="), parseValue)(nameResult.Remaining)
+	if valueResult.Err != nil {
+		return gomme.Failure[string, Const](valueResult.Err, code)
+	}
+	c := Const{
+		Name:      nameResult.Output,
+		ValueType: valueTypeResult.Output,
+		Value:     valueResult.Output,
+		Type:      typ.ModuleContentTypeToString(typ.ConstType),
+	}
+	if len(annotationsResult.Output) > 0 {
+		c.Annotations = annotationsResult.Output
+	}
+	return gomme.Success(c, valueResult.Remaining)
+}
@@ -0,0 +1,40 @@
+package const_type
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func TestParseConst(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Const
+	}{
+		{"const uint8 DEBUG = 10;", Const{Name: "DEBUG", ValueType: typeref.NewOctetType(), Value: "10", Type: "Const"}},
+		{"const double PI=-3.14 ;", Const{Name: "PI", ValueType: typeref.NewDoubleType(), Value: "-3.14", Type: "Const"}},
+		{`const string SEP = "a;\"b";`, Const{Name: "SEP", ValueType: typeref.NewStringType(), Value: `"a;\"b"`, Type: "Const"}},
+		{
+			`@verbatim (language="comment", text="Debug level") const uint8 DEBUG = 10;`,
+			Const{
+				Annotations: annotation.Annotations{{Name: "verbatim", Values: map[string]string{"language": "comment", "text": "Debug level"}}},
+				Name:        "DEBUG",
+				ValueType:   typeref.NewOctetType(),
+				Value:       "10",
+				Type:        "Const",
+			},
+		},
+	}
+	for _, test := range tests {
+		result := Parse(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output)
+		require.Equal(t, ";", result.Remaining)
+	}
+
+	require.NotNil(t, Parse("const long A = ;").Err)
+	require.NotNil(t, Parse("const long A = 1").Err)
+}
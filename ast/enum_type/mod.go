@@ -0,0 +1,53 @@
+package enum_type
+
+import (
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+type Enum struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	Type    string   `json:"type"`
+}
+
+func (e Enum) GetName() string {
+	return e.Name
+}
+
+func (Enum) ModuleContentType() typ.ModuleContentType {
+	return typ.EnumType
+}
+
+func Parse(code string) gomme.Result[Enum, string] {
+	enumTokenResult := gomme.Token[string]("enum")(code)
+	if enumTokenResult.Err != nil {
+		return gomme.Failure[string, Enum](enumTokenResult.Err, code)
+	}
+	nameResult := utils.InEmpty(utils.Identifier)(enumTokenResult.Remaining)
+	if nameResult.Err != nil {
+		return gomme.Failure[string, Enum](nameResult.Err, code)
+	}
+	membersResult := utils.InEmpty(
+		gomme.Delimited(
+			utils.InEmpty(gomme.Token[string]("{")),
+			gomme.SeparatedList1(utils.Identifier, utils.InEmpty(gomme.Token[string](","))),
+			gomme.Pair(
+				gomme.Optional(utils.InEmpty(gomme.Token[string](","))),
+				utils.InEmpty(gomme.Token[string]("}")),
+			),
+		))(nameResult.Remaining)
+	if membersResult.Err != nil {
+		return gomme.Failure[string, Enum](membersResult.Err, code)
+	}
+	return gomme.Success(
+		Enum{
+			Name:    nameResult.Output,
+			Members: membersResult.Output,
+			Type:    typ.ModuleContentTypeToString(typ.EnumType),
+		},
+		membersResult.Remaining,
+	)
+}
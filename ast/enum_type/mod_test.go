@@ -0,0 +1,31 @@
+package enum_type
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnum(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Enum
+	}{
+		{"enum Color { RED, GREEN, BLUE }", Enum{Name: "Color", Members: []string{"RED", "GREEN", "BLUE"}, Type: "Enum"}},
+		{`enum Mode {
+			IDLE, // waiting
+			RUN,
+		}`, Enum{Name: "Mode", Members: []string{"IDLE", "RUN"}, Type: "Enum"}},
+	}
+
+	for _, test := range tests {
+		result := Parse(test.input)
+		require.Nil(t, result.Err)
+		require.Equal(t, test.expected, result.Output)
+	}
+}
+
+func TestParseEnumEmpty(t *testing.T) {
+	result := Parse("enum Color {}")
+	require.NotNil(t, result.Err)
+}
@@ -0,0 +1,50 @@
+package forward_type
+
+import (
+	"strings"
+
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+// Forward is a forward declaration such as "struct Node;", which lets the
+// struct or union be referenced before its definition. Kind is "struct" or
+// "union".
+type Forward struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (f Forward) GetName() string {
+	return f.Name
+}
+
+func (Forward) ModuleContentType() typ.ModuleContentType {
+	return typ.ForwardType
+}
+
+// Parse parses a forward declaration up to, but not including, its ";".
+func Parse(code string) gomme.Result[Forward, string] {
+	kindResult := gomme.Terminated(
+		gomme.Alternative(gomme.Token[string]("struct"), gomme.Token[string]("union")),
+		utils.ParseEmpty1,
+	)(code)
+	if kindResult.Err != nil {
+		return gomme.Failure[string, Forward](kindResult.Err, code)
+	}
+	nameResult := utils.Identifier(kindResult.Remaining)
+	if nameResult.Err != nil {
+		return gomme.Failure[string, Forward](nameResult.Err, code)
+	}
+	if rest := utils.ParseEmpty0(nameResult.Remaining).Remaining; !strings.HasPrefix(rest, ";") {
+		return gomme.Failure[string, Forward](gomme.NewError[string](rest, ";"), code)
+	}
+	return gomme.Success(Forward{
+		Kind: kindResult.Output,
+		Name: nameResult.Output,
+		Type: typ.ModuleContentTypeToString(typ.ForwardType),
+	}, nameResult.Remaining)
+}
@@ -0,0 +1,26 @@
+package forward_type
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Forward
+	}{
+		{"struct Node;", Forward{Kind: "struct", Name: "Node", Type: "Forward"}},
+		{"union Value ;", Forward{Kind: "union", Name: "Value", Type: "Forward"}},
+	}
+	for _, test := range tests {
+		result := Parse(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output)
+	}
+
+	require.NotNil(t, Parse("struct Node { long a; };").Err)
+	require.NotNil(t, Parse("structNode;").Err)
+	require.NotNil(t, Parse("enum E;").Err)
+}
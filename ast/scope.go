@@ -0,0 +1,153 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+)
+
+// Scope is one naming scope of a schema. The global scope holds only the
+// root module, or the contents of an unnamed root as built by Merge; every
+// module opens a nested scope. A module declared more than once is one scope
+// holding the contents of all its declarations.
+type Scope struct {
+	module Module
+	parent *Scope
+	path   string
+}
+
+func NewGlobalScope(root Module) *Scope {
+	root = Merge(root)
+	if root.Name == "" {
+		return &Scope{module: root}
+	}
+	return &Scope{module: Module{Content: []ModuleContent{root}}}
+}
+
+// Module returns the module whose contents the scope holds.
+func (s *Scope) Module() Module {
+	return s.module
+}
+
+// Path returns the scoped name of the scope, e.g. "spi::can".
+func (s *Scope) Path() string {
+	return s.path
+}
+
+func (s *Scope) Child(m Module) *Scope {
+	path := m.Name
+	if s.path != "" {
+		path = s.path + "::" + m.Name
+	}
+	return &Scope{module: m, parent: s, path: path}
+}
+
+// Qualify returns the scoped name of a definition declared in s.
+func (s *Scope) Qualify(name string) string {
+	if s.path == "" {
+		return name
+	}
+	return s.path + "::" + name
+}
+
+// Find returns the definition of name declared in s, preferring it over
+// forward declarations of the same name, which are returned only when the
+// definition is missing.
+func (s *Scope) Find(name string) (ModuleContent, bool) {
+	var forward ModuleContent
+	for _, con := range s.module.Content {
+		if con.GetName() != name {
+			continue
+		}
+		if con.ModuleContentType() != typ.ForwardType {
+			return con, true
+		}
+		if forward == nil {
+			forward = con
+		}
+	}
+	return forward, forward != nil
+}
+
+// Resolve looks up a possibly scoped name following the IDL rules: the first
+// component is searched in s and then in each enclosing scope, and the
+// remaining components are searched inside the module found. A leading "::"
+// starts the search at the global scope. It returns the definition together
+// with the scope that contains it.
+func (s *Scope) Resolve(name string) (ModuleContent, *Scope, error) {
+	start := s
+	parts := strings.Split(name, "::")
+	if parts[0] == "" {
+		for start.parent != nil {
+			start = start.parent
+		}
+		parts = parts[1:]
+	}
+	var found ModuleContent
+	var owner *Scope
+	for cur := start; cur != nil; cur = cur.parent {
+		if con, ok := cur.Find(parts[0]); ok {
+			found, owner = con, cur
+			break
+		}
+	}
+	if found == nil {
+		return nil, nil, fmt.Errorf("type %v not found", name)
+	}
+	for _, part := range parts[1:] {
+		module, ok := found.(Module)
+		if !ok {
+			return nil, nil, fmt.Errorf("%v in %v is not a module", found.GetName(), name)
+		}
+		owner = owner.Child(module)
+		if found, ok = owner.Find(part); !ok {
+			return nil, nil, fmt.Errorf("type %v not found", name)
+		}
+	}
+	return found, owner, nil
+}
+
+// Member is a field of a struct, its own or inherited, together with the
+// scope its type names resolve in.
+type Member struct {
+	struct_type.Field
+	Scope *Scope
+}
+
+// Members returns the fields of st, declared in s, preceded by the fields it
+// inherits, those of the most basic struct first.
+func (s *Scope) Members(st struct_type.Struct) ([]Member, error) {
+	var levels [][]Member
+	seen := make(map[string]bool)
+	for {
+		name := s.Qualify(st.Name)
+		if seen[name] {
+			return nil, fmt.Errorf("struct %v inherits from itself", name)
+		}
+		seen[name] = true
+		level := make([]Member, len(st.Fields))
+		for i, f := range st.Fields {
+			level[i] = Member{Field: f, Scope: s}
+		}
+		levels = append(levels, level)
+		if st.Base == "" {
+			break
+		}
+		def, owner, err := s.Resolve(st.Base)
+		if err != nil {
+			return nil, fmt.Errorf("base of struct %v: %v", name, err)
+		}
+		base, ok := def.(struct_type.Struct)
+		if !ok {
+			return nil, fmt.Errorf("base %v of struct %v is not a struct", st.Base, name)
+		}
+		st, s = base, owner
+	}
+	var members []Member
+	for i := len(levels) - 1; i >= 0; i-- {
+		members = append(members, levels[i]...)
+	}
+	return members, nil
+}
@@ -0,0 +1,131 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeResolve(t *testing.T) {
+	result := Parse(`module a {
+	struct X { octet v; };
+	module b {
+		struct X { short v; };
+		struct Y { X local; };
+	};
+}`)
+	require.Nil(t, result.Err)
+	global := NewGlobalScope(result.Output)
+
+	def, owner, err := global.Resolve("a::b::Y")
+	require.NoError(t, err)
+	require.Equal(t, "Y", def.GetName())
+	require.Equal(t, "a::b", owner.Path())
+	require.Equal(t, "a::b::Y", owner.Qualify("Y"))
+
+	tests := []struct {
+		name  string
+		owner string
+		err   string
+	}{
+		{"X", "a::b", ""},
+		{"::a::X", "a", ""},
+		{"a::X", "a", ""},
+		{"b::X", "a::b", ""},
+		{"Z", "", "type Z not found"},
+		{"X::v", "", "X in X::v is not a module"},
+	}
+	for _, test := range tests {
+		_, found, err := owner.Resolve(test.name)
+		if test.err != "" {
+			require.EqualError(t, err, test.err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.owner, found.Path(), test.name)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	parse := func(code string) Module {
+		result := Parse(code)
+		require.Nil(t, result.Err)
+		return result.Output
+	}
+	header := parse(`module std_msgs { module msg { struct Header { long stamp; }; }; };`)
+	point := parse(`module geometry_msgs { module msg { struct Point { double x; }; }; };`)
+	pose := parse(`module geometry_msgs { module msg { struct Pose { std_msgs::msg::Header header; Point position; }; }; };`)
+
+	merged := Merge(header, point, pose)
+	require.Equal(t, "", merged.Name)
+	require.Len(t, merged.Content, 2)
+	msg := merged.Content[1].(Module).Content[0].(Module)
+	require.Equal(t, []string{"Point", "Pose"}, []string{msg.Content[0].GetName(), msg.Content[1].GetName()})
+
+	global := NewGlobalScope(merged)
+	_, owner, err := global.Resolve("geometry_msgs::msg::Pose")
+	require.NoError(t, err)
+	require.Equal(t, "geometry_msgs::msg", owner.Path())
+	_, found, err := owner.Resolve("std_msgs::msg::Header")
+	require.NoError(t, err)
+	require.Equal(t, "std_msgs::msg", found.Path())
+
+	same := Merge(point, pose)
+	require.Equal(t, "geometry_msgs", same.Name)
+	require.Len(t, same.Content[0].(Module).Content, 2)
+	require.Len(t, point.Content[0].(Module).Content, 1)
+}
+
+func TestReopenedModule(t *testing.T) {
+	result := Parse(`module spi {
+	module can { struct Id { octet bid; }; };
+	struct A { can::Id id; };
+	module can {
+		module ext { struct Ext { long x; }; };
+		struct Frame { Id id; };
+	};
+	module can { module ext { struct More { long y; }; }; };
+};`)
+	require.Nil(t, result.Err)
+	require.Len(t, result.Output.Content, 4)
+
+	global := NewGlobalScope(result.Output)
+	for _, name := range []string{"spi::can::Id", "spi::can::Frame", "spi::can::ext::Ext", "spi::can::ext::More"} {
+		_, _, err := global.Resolve(name)
+		require.NoError(t, err, name)
+	}
+	_, owner, err := global.Resolve("spi::can::Frame")
+	require.NoError(t, err)
+	_, found, err := owner.Resolve("Id")
+	require.NoError(t, err)
+	require.Equal(t, "spi::can", found.Path())
+
+	merged := Merge(result.Output)
+	require.Len(t, merged.Content, 2)
+	can := merged.Content[0].(Module)
+	require.Equal(t, []string{"Id", "ext", "Frame"}, []string{can.Content[0].GetName(), can.Content[1].GetName(), can.Content[2].GetName()})
+	require.Len(t, can.Content[1].(Module).Content, 2)
+}
+
+func TestParseSchema(t *testing.T) {
+	module, err := ParseSchema(`module a { struct X { long x; }; };
+module a { struct Y { X x; }; };
+`)
+	require.NoError(t, err)
+	require.Equal(t, "a", module.Name)
+	require.Len(t, module.Content, 2)
+
+	module, err = ParseSchema(`module a { struct X { long x; }; }; module b { struct Y { a::X x; }; };`)
+	require.NoError(t, err)
+	_, _, err = NewGlobalScope(module).Resolve("b::Y")
+	require.NoError(t, err)
+
+	_, err = ParseSchema(`module a { struct X { long x; }; }; garbage`)
+	require.ErrorContains(t, err, "unexpected content after module a")
+
+	modules, err := ParseModules(`module a { struct X { long x; }; }; module a { struct Y { X x; }; };`)
+	require.NoError(t, err)
+	require.Len(t, modules, 2)
+	require.Equal(t, "a", modules[1].Name)
+	require.Len(t, modules[1].Content, 1)
+}
@@ -16,9 +16,12 @@ type Field struct {
 }
 
 type Struct struct {
-	Name   string  `json:"name"`
-	Fields []Field `json:"fields"`
-	Type   string  `json:"type"`
+	Annotations   annotation.Annotations `json:"annotations,omitempty"`
+	Name          string                 `json:"name"`
+	Base          string                 `json:"base,omitempty"`
+	Extensibility typ.Extensibility      `json:"extensibility,omitempty"`
+	Fields        []Field                `json:"fields"`
+	Type          string                 `json:"type"`
 }
 
 func (s Struct) GetName() string {
@@ -29,7 +32,8 @@ func (Struct) ModuleContentType() typ.ModuleContentType {
 	return typ.StructType
 }
 
-func parseField(code string) gomme.Result[Field, string] {
+// ParseField parses one annotated member declaration such as "@id(1) long x".
+func ParseField(code string) gomme.Result[Field, string] {
 	var typeRefParser gomme.Parser[string, typeref.TypeRef] = typeref.ParseTypeRef
 	var annotationsParser gomme.Parser[string, annotation.Annotations] = annotation.ParseAnnotations
 	var optionalWhitespace gomme.Parser[string, string] = gomme.Whitespace0[string]()
@@ -40,17 +44,12 @@ func parseField(code string) gomme.Result[Field, string] {
 			gomme.SeparatedPair(
 				typeRefParser,
 				gomme.Whitespace1[string](),
-				gomme.Recognize(
-					gomme.Pair(gomme.Alpha1[string](), gomme.Alphanumeric0[string]()),
-				),
+				gomme.Parser[string, string](utils.Identifier),
 			),
 		),
 		func(output gomme.PairContainer[annotation.Annotations, gomme.PairContainer[typeref.TypeRef, string]]) (Field, error) {
-			if len(output.Left) < 1 {
-				output.Left = nil
-			}
 			return Field{
-				Annotations: output.Left,
+				Annotations: nonEmpty(output.Left),
 				Type:        output.Right.Left,
 				Name:        output.Right.Right,
 			}, nil
@@ -58,36 +57,62 @@ func parseField(code string) gomme.Result[Field, string] {
 	)(code)
 }
 
+// Parse parses a struct definition, optionally preceded by annotations and
+// optionally inheriting from a base struct as in "struct Derived : Base".
 func Parse(code string) gomme.Result[Struct, string] {
-	structTokenResult := gomme.Token[string]("struct")(code)
+	annotationsResult := annotation.ParseAnnotations(code)
+	structTokenResult := utils.InLeftEmpty(gomme.Token[string]("struct"))(annotationsResult.Remaining)
 	if structTokenResult.Err != nil {
 		return gomme.Failure[string, Struct](structTokenResult.Err, code)
 	}
 	nameResult :=
 		utils.InEmpty(
-			gomme.Recognize(gomme.Pair(gomme.Alpha1[string](), gomme.Alphanumeric0[string]())),
+			utils.Identifier,
 		)(structTokenResult.Remaining)
 	if nameResult.Err != nil {
 		return gomme.Failure[string, Struct](nameResult.Err, code)
 	}
+	baseResult := gomme.Optional(
+		gomme.Preceded(
+			utils.InEmpty(gomme.Token[string](":")),
+			utils.InEmpty(typeref.ParseTypeName),
+		),
+	)(nameResult.Remaining)
+	if baseResult.Err != nil {
+		return gomme.Failure[string, Struct](baseResult.Err, code)
+	}
 	fieldsResult := utils.InEmpty(
 		gomme.Delimited(
 			utils.InEmpty(gomme.Token[string]("{")),
-			gomme.SeparatedList0(parseField, utils.InEmpty(gomme.Token[string](";"))),
+			gomme.SeparatedList0(ParseField, utils.InEmpty(gomme.Token[string](";"))),
 			gomme.Pair(
 				gomme.Optional(utils.InEmpty(gomme.Token[string](";"))),
 				utils.InEmpty(gomme.Token[string]("}")),
 			),
-		))(nameResult.Remaining)
+		))(baseResult.Remaining)
 	if fieldsResult.Err != nil {
 		return gomme.Failure[string, Struct](fieldsResult.Err, code)
 	}
+	ext, _, err := annotationsResult.Output.Extensibility()
+	if err != nil {
+		return gomme.Failure[string, Struct](gomme.NewError[string](code, err.Error()), code)
+	}
 	return gomme.Success(
 		Struct{
-			Name:   nameResult.Output,
-			Fields: fieldsResult.Output,
-			Type:   typ.ModuleContentTypeToString(typ.StructType),
+			Annotations:   nonEmpty(annotationsResult.Output),
+			Name:          nameResult.Output,
+			Base:          baseResult.Output.Name,
+			Extensibility: ext,
+			Fields:        fieldsResult.Output,
+			Type:          typ.ModuleContentTypeToString(typ.StructType),
 		},
 		fieldsResult.Remaining,
 	)
 }
+
+func nonEmpty(annos annotation.Annotations) annotation.Annotations {
+	if len(annos) == 0 {
+		return nil
+	}
+	return annos
+}
@@ -101,3 +101,57 @@ func TestParseStructSequence(t *testing.T) {
 	result := Parse(code)
 	require.Nil(t, result.Err)
 }
+
+func TestParseStructUnderscoreNames(t *testing.T) {
+	result := Parse(`struct Reading_Sample { unsigned long device_id; }`)
+	require.Nil(t, result.Err)
+	require.Equal(t, "Reading_Sample", result.Output.Name)
+	require.Equal(t, "device_id", result.Output.Fields[0].Name)
+}
+
+func TestParseStructInheritance(t *testing.T) {
+	tests := []struct {
+		input string
+		base  string
+	}{
+		{`struct Derived : Base { long x; }`, "Base"},
+		{`struct Derived: ::m::Base{ long x; }`, "::m::Base"},
+		{`struct Derived :m::Base { long x; }`, "m::Base"},
+		{`struct Derived { long x; }`, ""},
+	}
+	for _, test := range tests {
+		result := Parse(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, "Derived", result.Output.Name)
+		require.Equal(t, test.base, result.Output.Base)
+		require.Len(t, result.Output.Fields, 1)
+	}
+
+	result := Parse(`struct Derived : { long x; }`)
+	require.NotNil(t, result.Err)
+}
+
+func TestParseStructExtensibility(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected typ.Extensibility
+		err      bool
+	}{
+		{`struct S { long a; }`, typ.FinalExtensibility, false},
+		{`@extensibility(APPENDABLE) struct S { long a; }`, typ.AppendableExtensibility, false},
+		{`@extensibility(mutable) struct S { @id(3) long a; }`, typ.MutableExtensibility, false},
+		{`@appendable struct S : B { long a; }`, typ.AppendableExtensibility, false},
+		{`@final @extensibility(FINAL) struct S { long a; }`, typ.FinalExtensibility, false},
+		{`@extensibility(OPEN) struct S { long a; }`, 0, true},
+		{`@final @mutable struct S { long a; }`, 0, true},
+	}
+	for _, test := range tests {
+		result := Parse(test.input)
+		if test.err {
+			require.NotNil(t, result.Err, test.input)
+			continue
+		}
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output.Extensibility, test.input)
+	}
+}
@@ -1,11 +1,21 @@
 package typ
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ModuleContentType int
 
 const (
 	BitSetType ModuleContentType = iota
 	StructType
 	ModuleType
+	EnumType
+	UnionType
+	TypedefType
+	ConstType
+	ForwardType
 )
 
 func ModuleContentTypeToString(ct ModuleContentType) string {
@@ -16,6 +26,16 @@ func ModuleContentTypeToString(ct ModuleContentType) string {
 		return "Struct"
 	case ModuleType:
 		return "Module"
+	case EnumType:
+		return "Enum"
+	case UnionType:
+		return "Union"
+	case TypedefType:
+		return "Typedef"
+	case ConstType:
+		return "Const"
+	case ForwardType:
+		return "Forward"
 	}
 	return ""
 }
@@ -36,4 +56,49 @@ const (
 	BooleanType
 	FloatType
 	StringType
+	Int8Type
+	DoubleType
 )
+
+// Extensibility is the XTypes extensibility kind of a struct or union,
+// which governs how its members are laid out on the wire and how the type
+// may evolve. The zero value is FinalExtensibility.
+type Extensibility int
+
+const (
+	FinalExtensibility Extensibility = iota
+	AppendableExtensibility
+	MutableExtensibility
+)
+
+var extensibilityNames = [...]string{"FINAL", "APPENDABLE", "MUTABLE"}
+
+func (e Extensibility) String() string {
+	if e < 0 || int(e) >= len(extensibilityNames) {
+		return fmt.Sprintf("Extensibility(%d)", int(e))
+	}
+	return extensibilityNames[e]
+}
+
+// ParseExtensibility parses FINAL, APPENDABLE or MUTABLE, in any case.
+func ParseExtensibility(s string) (Extensibility, error) {
+	for i, name := range extensibilityNames {
+		if strings.EqualFold(s, name) {
+			return Extensibility(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown extensibility %q, expect FINAL, APPENDABLE or MUTABLE", s)
+}
+
+func (e Extensibility) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *Extensibility) UnmarshalText(b []byte) error {
+	v, err := ParseExtensibility(string(b))
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
@@ -0,0 +1,16 @@
+package typ
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtensibility(t *testing.T) {
+	e, err := ParseExtensibility("appendable")
+	require.NoError(t, err)
+	require.Equal(t, AppendableExtensibility, e)
+	require.Equal(t, "APPENDABLE", e.String())
+	_, err = ParseExtensibility("open")
+	require.EqualError(t, err, `unknown extensibility "open", expect FINAL, APPENDABLE or MUTABLE`)
+}
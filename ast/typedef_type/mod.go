@@ -0,0 +1,79 @@
+package typedef_type
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+// Typedef names the Aliased type or, when Dims is set, an array of it:
+// "typedef double double__9[9]" has Aliased double and Dims [9]. The first
+// dimension is the outermost.
+type Typedef struct {
+	Name    string          `json:"name"`
+	Aliased typeref.TypeRef `json:"aliased"`
+	Dims    []int           `json:"dims,omitempty"`
+	Type    string          `json:"type"`
+}
+
+func (t Typedef) GetName() string {
+	return t.Name
+}
+
+func (Typedef) ModuleContentType() typ.ModuleContentType {
+	return typ.TypedefType
+}
+
+func parseDim(code string) gomme.Result[int, string] {
+	return gomme.Map(
+		gomme.Delimited(
+			gomme.Token[string]("["),
+			utils.InEmpty(gomme.Digit1[string]()),
+			gomme.Token[string]("]"),
+		),
+		func(dim string) (int, error) {
+			n, err := strconv.Atoi(dim)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid array dimension %v", dim)
+			}
+			return n, nil
+		},
+	)(code)
+}
+
+func Parse(code string) gomme.Result[Typedef, string] {
+	typedefTokenResult := gomme.Terminated(gomme.Token[string]("typedef"), utils.ParseEmpty1)(code)
+	if typedefTokenResult.Err != nil {
+		return gomme.Failure[string, Typedef](typedefTokenResult.Err, code)
+	}
+	aliasedResult := typeref.ParseTypeRef(typedefTokenResult.Remaining)
+	if aliasedResult.Err != nil {
+		return gomme.Failure[string, Typedef](aliasedResult.Err, code)
+	}
+	nameResult := utils.InLeftEmpty(utils.Identifier)(aliasedResult.Remaining)
+	if nameResult.Err != nil {
+		return gomme.Failure[string, Typedef](nameResult.Err, code)
+	}
+	dimsResult := gomme.Many0(utils.InLeftEmpty(parseDim))(nameResult.Remaining)
+	if dimsResult.Err != nil {
+		return gomme.Failure[string, Typedef](dimsResult.Err, code)
+	}
+	if rest := utils.ParseEmpty0(dimsResult.Remaining).Remaining; strings.HasPrefix(rest, "[") {
+		return gomme.Failure[string, Typedef](gomme.NewError[string](rest, "array dimension"), code)
+	}
+	td := Typedef{
+		Name:    nameResult.Output,
+		Aliased: aliasedResult.Output,
+		Type:    typ.ModuleContentTypeToString(typ.TypedefType),
+	}
+	if len(dimsResult.Output) > 0 {
+		td.Dims = dimsResult.Output
+	}
+	return gomme.Success(td, dimsResult.Remaining)
+}
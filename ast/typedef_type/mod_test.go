@@ -0,0 +1,38 @@
+package typedef_type
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func TestParseTypedef(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Typedef
+	}{
+		{"typedef double double__9[9];", Typedef{Name: "double__9", Aliased: typeref.NewDoubleType(), Dims: []int{9}, Type: "Typedef"}},
+		{"typedef long Matrix[2] [3];", Typedef{Name: "Matrix", Aliased: typeref.NewLongType(), Dims: []int{2, 3}, Type: "Typedef"}},
+		{"typedef sequence<octet, 8> Bytes;", Typedef{Name: "Bytes", Aliased: typeref.NewBoundedSequence(typeref.NewOctetType(), 8), Type: "Typedef"}},
+		{
+			"typedef geometry_msgs::msg::Point geometry_msgs__msg__Point__3[3];",
+			Typedef{
+				Name:    "geometry_msgs__msg__Point__3",
+				Aliased: typeref.TypeName{Name: "geometry_msgs::msg::Point", SelfType: "geometry_msgs::msg::Point"},
+				Dims:    []int{3},
+				Type:    "Typedef",
+			},
+		},
+	}
+	for _, test := range tests {
+		result := Parse(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output)
+		require.Equal(t, ";", result.Remaining)
+	}
+
+	require.NotNil(t, Parse("typedef long A[0];").Err)
+	require.NotNil(t, Parse("typedefs long A;").Err)
+}
@@ -1,6 +1,9 @@
 package typeref
 
 import (
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/oleiade/gomme"
 
 	"github.com/yisaer/idl-parser/ast/typ"
@@ -14,17 +17,34 @@ type TypeRef interface {
 func ParseTypeRef(code string) gomme.Result[TypeRef, string] {
 	return gomme.Alternative(
 		gomme.Map(ParseSequence, func(seq Sequence) (TypeRef, error) { return seq, nil }),
-		gomme.Map(ParseOctet, func(octet OctetType) (TypeRef, error) { return octet, nil }),
-		gomme.Map(ParseShort, func(short ShortType) (TypeRef, error) { return short, nil }),
-		gomme.Map(ParseUnsignedShort, func(us UnsignedShortType) (TypeRef, error) { return us, nil }),
-		gomme.Map(ParseLongLong, func(longlong LongLongType) (TypeRef, error) { return longlong, nil }),
-		gomme.Map(ParseLong, func(long LongType) (TypeRef, error) { return long, nil }),
-		gomme.Map(ParseUnsignedLongLong, func(ull UnsignedLongLongType) (TypeRef, error) { return ull, nil }),
-		gomme.Map(ParseUnsignedLong, func(ul UnsignedLongType) (TypeRef, error) { return ul, nil }),
-		gomme.Map(ParseBoolean, func(b BooleanType) (TypeRef, error) { return b, nil }),
-		gomme.Map(ParseFloat, func(f FloatType) (TypeRef, error) { return f, nil }),
-		gomme.Map(ParseString, func(s StringType) (TypeRef, error) { return s, nil }),
+		keyword(ParseSizedInteger),
+		gomme.Map(keyword(ParseOctet), func(octet OctetType) (TypeRef, error) { return octet, nil }),
+		gomme.Map(keyword(ParseShort), func(short ShortType) (TypeRef, error) { return short, nil }),
+		gomme.Map(keyword(ParseUnsignedShort), func(us UnsignedShortType) (TypeRef, error) { return us, nil }),
+		gomme.Map(keyword(ParseLongLong), func(longlong LongLongType) (TypeRef, error) { return longlong, nil }),
+		gomme.Map(keyword(ParseLong), func(long LongType) (TypeRef, error) { return long, nil }),
+		gomme.Map(keyword(ParseUnsignedLongLong), func(ull UnsignedLongLongType) (TypeRef, error) { return ull, nil }),
+		gomme.Map(keyword(ParseUnsignedLong), func(ul UnsignedLongType) (TypeRef, error) { return ul, nil }),
+		gomme.Map(keyword(ParseBoolean), func(b BooleanType) (TypeRef, error) { return b, nil }),
+		gomme.Map(keyword(ParseFloat), func(f FloatType) (TypeRef, error) { return f, nil }),
+		gomme.Map(keyword(ParseDouble), func(d DoubleType) (TypeRef, error) { return d, nil }),
+		gomme.Map(keyword(ParseString), func(s StringType) (TypeRef, error) { return s, nil }),
 		gomme.Map(ParseBitField, func(bitfield BitFieldType) (TypeRef, error) { return bitfield, nil }),
 		gomme.Map(ParseTypeName, func(name TypeName) (TypeRef, error) { return name, nil }),
 	)(code)
 }
+
+// keyword makes a type keyword parser fail when the keyword is only the
+// start of a longer identifier, such as double in double__3.
+func keyword[T any](parser gomme.Parser[string, T]) gomme.Parser[string, T] {
+	return func(code string) gomme.Result[T, string] {
+		result := parser(code)
+		if result.Err != nil {
+			return result
+		}
+		if r, _ := utf8.DecodeRuneInString(result.Remaining); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return gomme.Failure[string, T](gomme.NewError[string](code, "type keyword"), code)
+		}
+		return result
+	}
+}
@@ -1,21 +1,31 @@
 package typeref
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/oleiade/gomme"
 
 	"github.com/yisaer/idl-parser/ast/typ"
 	"github.com/yisaer/idl-parser/ast/utils"
 )
 
+// Sequence is sequence<InnerType> or, when Bound is non-zero, the bounded
+// sequence<InnerType, Bound>.
 type Sequence struct {
 	SelfType  string  `json:"self_type"`
 	InnerType TypeRef `json:"inner_type"`
+	Bound     int     `json:"bound,omitempty"`
 }
 
 func NewSequence(innerType TypeRef) Sequence {
 	return Sequence{SelfType: "sequence", InnerType: innerType}
 }
 
+func NewBoundedSequence(innerType TypeRef, bound int) Sequence {
+	return Sequence{SelfType: "sequence", InnerType: innerType, Bound: bound}
+}
+
 func (s Sequence) TypeRefType() typ.FieldRefType {
 	return typ.SequenceType
 }
@@ -30,11 +40,24 @@ func ParseSequence(code string) gomme.Result[Sequence, string] {
 			gomme.Token[string]("sequence"),
 			utils.InLeftEmpty(gomme.Delimited(
 				gomme.Token[string]("<"),
-				utils.InEmpty(ParseTypeRef),
+				gomme.Pair(
+					utils.InEmpty(ParseTypeRef),
+					gomme.Optional(gomme.Preceded(
+						gomme.Token[string](","),
+						utils.InEmpty(gomme.Digit1[string]()),
+					)),
+				),
 				gomme.Token[string](">"),
 			))),
-		func(innerType TypeRef) (Sequence, error) {
-			return NewSequence(innerType), nil
+		func(output gomme.PairContainer[TypeRef, string]) (Sequence, error) {
+			if output.Right == "" {
+				return NewSequence(output.Left), nil
+			}
+			bound, err := strconv.Atoi(output.Right)
+			if err != nil || bound == 0 {
+				return Sequence{}, fmt.Errorf("invalid sequence bound %v", output.Right)
+			}
+			return NewBoundedSequence(output.Left, bound), nil
 		},
 	)(code)
 	return result
@@ -17,6 +17,8 @@ func TestSeq(t *testing.T) {
 		{"sequence<unsigned long>", Sequence{SelfType: "sequence", InnerType: UnsignedLongType{SelfType: "unsigned long"}}},
 		{"sequence<unsigned long long>", Sequence{SelfType: "sequence", InnerType: UnsignedLongLongType{SelfType: "unsigned long long"}}},
 		{"sequence<idbits>", Sequence{SelfType: "sequence", InnerType: TypeName{Name: "idbits", SelfType: "idbits"}}},
+		{"sequence<octet, 8>", Sequence{SelfType: "sequence", InnerType: OctetType{SelfType: "octet"}, Bound: 8}},
+		{"sequence< sequence<short,2> ,16>", Sequence{SelfType: "sequence", InnerType: Sequence{SelfType: "sequence", InnerType: ShortType{SelfType: "short"}, Bound: 2}, Bound: 16}},
 	}
 
 	for _, test := range tests {
@@ -24,3 +26,9 @@ func TestSeq(t *testing.T) {
 		require.Equal(t, test.expected, result.Output)
 	}
 }
+
+func TestSeqInvalidBound(t *testing.T) {
+	require.NotNil(t, ParseSequence("sequence<octet, 0>").Err)
+	require.NotNil(t, ParseSequence("sequence<octet, 99999999999999999999>").Err)
+	require.NotNil(t, ParseSequence("sequence<octet, n>").Err)
+}
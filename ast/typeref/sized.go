@@ -0,0 +1,52 @@
+package typeref
+
+import (
+	"strings"
+
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+)
+
+type Int8Type struct {
+	SelfType string `json:"self_type"`
+}
+
+func NewInt8Type() Int8Type {
+	return Int8Type{SelfType: "int8"}
+}
+
+func (t Int8Type) TypeName() string {
+	return "int8"
+}
+
+func (Int8Type) TypeRefType() typ.FieldRefType {
+	return typ.Int8Type
+}
+
+// sizedIntegers maps the sized integer keywords of IDL 4 to their types.
+// Apart from int8 they are spellings of the classic integer types.
+var sizedIntegers = []struct {
+	keyword string
+	ref     func() TypeRef
+}{
+	{"int8", func() TypeRef { return NewInt8Type() }},
+	{"uint8", func() TypeRef { return NewOctetType() }},
+	{"int16", func() TypeRef { return NewShortType() }},
+	{"uint16", func() TypeRef { return NewUnsignedShortType() }},
+	{"int32", func() TypeRef { return NewLongType() }},
+	{"uint32", func() TypeRef { return NewUnsignedLong() }},
+	{"int64", func() TypeRef { return NewLongLongType() }},
+	{"uint64", func() TypeRef { return NewUnsignedLongLong() }},
+}
+
+// ParseSizedInteger parses int8, uint8, int16, uint16, int32, uint32, int64
+// or uint64.
+func ParseSizedInteger(code string) gomme.Result[TypeRef, string] {
+	for _, sized := range sizedIntegers {
+		if rest, ok := strings.CutPrefix(code, sized.keyword); ok {
+			return gomme.Success(sized.ref(), rest)
+		}
+	}
+	return gomme.Failure[string, TypeRef](gomme.NewError[string](code, "sized integer"), code)
+}
@@ -1,13 +1,20 @@
 package typeref
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/oleiade/gomme"
 
 	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/utils"
 )
 
+// StringType is string or, when Bound is non-zero, the bounded string<Bound>
+// of at most Bound characters.
 type StringType struct {
 	SelfType string `json:"self_type"`
+	Bound    int    `json:"bound,omitempty"`
 }
 
 func NewStringType() StringType {
@@ -16,14 +23,34 @@ func NewStringType() StringType {
 	}
 }
 
+func NewBoundedString(bound int) StringType {
+	return StringType{SelfType: "string", Bound: bound}
+}
+
 func (t StringType) TypeName() string {
 	return "string"
 }
 
 func ParseString(code string) gomme.Result[StringType, string] {
 	return gomme.Map(
-		gomme.Token[string]("string"),
-		func(_ string) (StringType, error) { return NewStringType(), nil },
+		gomme.Preceded(
+			gomme.Token[string]("string"),
+			gomme.Optional(utils.InLeftEmpty(gomme.Delimited(
+				gomme.Token[string]("<"),
+				utils.InEmpty(gomme.Digit1[string]()),
+				gomme.Token[string](">"),
+			))),
+		),
+		func(bound string) (StringType, error) {
+			if bound == "" {
+				return NewStringType(), nil
+			}
+			n, err := strconv.Atoi(bound)
+			if err != nil || n == 0 {
+				return StringType{}, fmt.Errorf("invalid string bound %v", bound)
+			}
+			return NewBoundedString(n), nil
+		},
 	)(code)
 }
 
@@ -153,3 +153,55 @@ func TestString(t *testing.T) {
 		require.Equal(t, test.expected, result.Output)
 	}
 }
+
+func TestSizedInteger(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TypeRef
+	}{
+		{"int8 x", Int8Type{SelfType: "int8"}},
+		{"uint8 x", OctetType{SelfType: "octet"}},
+		{"int16 x", ShortType{SelfType: "short"}},
+		{"uint16 x", UnsignedShortType{SelfType: "unsigned short"}},
+		{"int32 x", LongType{SelfType: "long"}},
+		{"uint32 x", UnsignedLongType{SelfType: "unsigned long"}},
+		{"int64 x", LongLongType{SelfType: "long long"}},
+		{"uint64 x", UnsignedLongLongType{SelfType: "unsigned long long"}},
+	}
+
+	for _, test := range tests {
+		result := ParseTypeRef(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output)
+		require.Equal(t, " x", result.Remaining)
+	}
+}
+
+func TestDouble(t *testing.T) {
+	result := ParseTypeRef("double")
+	require.Equal(t, DoubleType{SelfType: "double"}, result.Output)
+}
+
+func TestBoundedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected TypeRef
+	}{
+		{"string<10>", StringType{SelfType: "string", Bound: 10}},
+		{"string < 4 >", StringType{SelfType: "string", Bound: 4}},
+		{"sequence<string<8>, 2>", Sequence{SelfType: "sequence", InnerType: StringType{SelfType: "string", Bound: 8}, Bound: 2}},
+	}
+
+	for _, test := range tests {
+		result := ParseTypeRef(test.input)
+		require.Nil(t, result.Err, test.input)
+		require.Equal(t, test.expected, result.Output)
+	}
+}
+
+func TestKeywordPrefixedTypeName(t *testing.T) {
+	for _, name := range []string{"double__36", "octets", "string_list", "int8_t", "longitude"} {
+		result := ParseTypeRef(name + " x")
+		require.Equal(t, TypeName{Name: name, SelfType: name}, result.Output, name)
+	}
+}
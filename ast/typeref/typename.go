@@ -4,6 +4,7 @@ import (
 	"github.com/oleiade/gomme"
 
 	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/utils"
 )
 
 type TypeName struct {
@@ -23,8 +24,8 @@ func ParseTypeName(code string) gomme.Result[TypeName, string] {
 	return gomme.Map(
 		gomme.Recognize(
 			gomme.Pair(
-				gomme.Alpha1[string](),
-				gomme.Alphanumeric0[string](),
+				gomme.Optional(gomme.Token[string]("::")),
+				gomme.SeparatedList1(utils.Identifier, gomme.Token[string]("::")),
 			),
 		),
 		func(name string) (TypeName, error) {
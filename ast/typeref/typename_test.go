@@ -11,3 +11,22 @@ func TestParseTypeName(t *testing.T) {
 	result := ParseTypeName(code)
 	require.Equal(t, "idbits", result.Output.Name)
 }
+
+func TestParseScopedTypeName(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		remaining string
+	}{
+		{"spi::idbits id;", "spi::idbits", " id;"},
+		{"::spi::can::Frame f;", "::spi::can::Frame", " f;"},
+		{"Frame: x", "Frame", ": x"},
+	}
+
+	for _, test := range tests {
+		result := ParseTypeName(test.input)
+		require.Nil(t, result.Err)
+		require.Equal(t, test.expected, result.Output.Name)
+		require.Equal(t, test.remaining, result.Remaining)
+	}
+}
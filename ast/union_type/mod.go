@@ -0,0 +1,135 @@
+package union_type
+
+import (
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+// Case is one branch of a union: the member selected when the discriminator
+// equals one of Labels or, for the default branch, none of the other labels.
+type Case struct {
+	Labels  []string          `json:"labels,omitempty"`
+	Default bool              `json:"default,omitempty"`
+	Field   struct_type.Field `json:"field"`
+}
+
+type Union struct {
+	Annotations   annotation.Annotations `json:"annotations,omitempty"`
+	Name          string                 `json:"name"`
+	Discriminator typeref.TypeRef        `json:"discriminator"`
+	Extensibility typ.Extensibility      `json:"extensibility,omitempty"`
+	Cases         []Case                 `json:"cases"`
+	Type          string                 `json:"type"`
+}
+
+func (u Union) GetName() string {
+	return u.Name
+}
+
+func (Union) ModuleContentType() typ.ModuleContentType {
+	return typ.UnionType
+}
+
+// label is a case label; an empty value stands for default.
+type label struct {
+	value string
+}
+
+func parseLabel(code string) gomme.Result[label, string] {
+	return gomme.Alternative(
+		gomme.Map(
+			gomme.Delimited(
+				gomme.Token[string]("case"),
+				utils.InEmpty(gomme.Alternative(
+					gomme.Recognize(gomme.Pair(gomme.Optional(gomme.Token[string]("-")), gomme.Digit1[string]())),
+					gomme.Map(typeref.ParseTypeName, func(name typeref.TypeName) (string, error) { return name.Name, nil }),
+				)),
+				gomme.Token[string](":"),
+			),
+			func(value string) (label, error) { return label{value: value}, nil },
+		),
+		gomme.Map(
+			gomme.Terminated(gomme.Token[string]("default"), utils.InEmpty(gomme.Token[string](":"))),
+			func(string) (label, error) { return label{}, nil },
+		),
+	)(code)
+}
+
+func parseCase(code string) gomme.Result[Case, string] {
+	return gomme.Map(
+		gomme.Pair(
+			gomme.Many1(utils.InEmpty(parseLabel)),
+			gomme.Terminated(struct_type.ParseField, utils.InEmpty(gomme.Token[string](";"))),
+		),
+		func(output gomme.PairContainer[[]label, struct_type.Field]) (Case, error) {
+			c := Case{Field: output.Right}
+			for _, l := range output.Left {
+				if l.value == "" {
+					c.Default = true
+					continue
+				}
+				c.Labels = append(c.Labels, l.value)
+			}
+			return c, nil
+		},
+	)(code)
+}
+
+// Parse parses a discriminated union:
+//
+//	union Name switch (long) { case 1: long a; default: string b; }
+//
+// The definition may be preceded by annotations.
+func Parse(code string) gomme.Result[Union, string] {
+	annotationsResult := annotation.ParseAnnotations(code)
+	if len(annotationsResult.Output) == 0 {
+		annotationsResult.Output = nil
+	}
+	unionTokenResult := utils.InLeftEmpty(gomme.Token[string]("union"))(annotationsResult.Remaining)
+	if unionTokenResult.Err != nil {
+		return gomme.Failure[string, Union](unionTokenResult.Err, code)
+	}
+	nameResult := utils.InEmpty(utils.Identifier)(unionTokenResult.Remaining)
+	if nameResult.Err != nil {
+		return gomme.Failure[string, Union](nameResult.Err, code)
+	}
+	discriminatorResult := gomme.Preceded(
+		gomme.Token[string]("switch"),
+		utils.InEmpty(gomme.Delimited(
+			gomme.Token[string]("("),
+			utils.InEmpty(typeref.ParseTypeRef),
+			gomme.Token[string](")"),
+		)),
+	)(nameResult.Remaining)
+	if discriminatorResult.Err != nil {
+		return gomme.Failure[string, Union](discriminatorResult.Err, code)
+	}
+	casesResult := gomme.Delimited(
+		utils.InEmpty(gomme.Token[string]("{")),
+		gomme.Many1(utils.InEmpty(parseCase)),
+		utils.InEmpty(gomme.Token[string]("}")),
+	)(discriminatorResult.Remaining)
+	if casesResult.Err != nil {
+		return gomme.Failure[string, Union](casesResult.Err, code)
+	}
+	ext, _, err := annotationsResult.Output.Extensibility()
+	if err != nil {
+		return gomme.Failure[string, Union](gomme.NewError[string](code, err.Error()), code)
+	}
+	return gomme.Success(
+		Union{
+			Annotations:   annotationsResult.Output,
+			Name:          nameResult.Output,
+			Discriminator: discriminatorResult.Output,
+			Extensibility: ext,
+			Cases:         casesResult.Output,
+			Type:          typ.ModuleContentTypeToString(typ.UnionType),
+		},
+		casesResult.Remaining,
+	)
+}
@@ -0,0 +1,60 @@
+package union_type
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func TestParseUnion(t *testing.T) {
+	code := `union Value switch (long) {
+		case 1: long i;
+		case 2:
+		case -3: @id(7) string s;
+		case Kind::REAL: float f;
+		default: sequence<octet> raw;
+	}`
+	result := Parse(code)
+	require.Nil(t, result.Err)
+	require.Equal(t, Union{
+		Name:          "Value",
+		Discriminator: typeref.NewLongType(),
+		Cases: []Case{
+			{Labels: []string{"1"}, Field: struct_type.Field{Type: typeref.NewLongType(), Name: "i"}},
+			{Labels: []string{"2", "-3"}, Field: struct_type.Field{
+				Annotations: annotation.Annotations{{Name: "id", Values: map[string]string{"value": "7"}}},
+				Type:        typeref.NewStringType(),
+				Name:        "s",
+			}},
+			{Labels: []string{"Kind::REAL"}, Field: struct_type.Field{Type: typeref.NewFloatType(), Name: "f"}},
+			{Default: true, Field: struct_type.Field{Type: typeref.NewSequence(typeref.NewOctetType()), Name: "raw"}},
+		},
+		Type: "Union",
+	}, result.Output)
+}
+
+func TestParseUnionInvalid(t *testing.T) {
+	for _, code := range []string{
+		`union U { case 1: long a; }`,
+		`union U switch (long) { }`,
+		`union U switch (long) { case 1 long a; }`,
+		`union U switch (long) { case 1: long a }`,
+	} {
+		result := Parse(code)
+		require.NotNil(t, result.Err, code)
+	}
+}
+
+func TestParseUnionExtensibility(t *testing.T) {
+	result := Parse(`@extensibility(APPENDABLE) union U switch (long) { case 1: long a; }`)
+	require.Nil(t, result.Err)
+	require.Equal(t, typ.AppendableExtensibility, result.Output.Extensibility)
+
+	result = Parse(`@final @mutable union U switch (long) { case 1: long a; }`)
+	require.NotNil(t, result.Err)
+}
@@ -1,6 +1,12 @@
 package utils
 
-import "github.com/oleiade/gomme"
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/oleiade/gomme"
+)
 
 func ParseComment(code string) gomme.Result[string, string] {
 	return gomme.Recognize(
@@ -22,11 +28,25 @@ func ParseComment(code string) gomme.Result[string, string] {
 		))(code)
 }
 
+// ParseDirective parses a preprocessor line such as #include "a.idl". The
+// parser does not act on directives; they are skipped like comments.
+func ParseDirective(code string) gomme.Result[string, string] {
+	if !strings.HasPrefix(code, "#") {
+		return gomme.Failure[string, string](gomme.NewError[string](code, "Directive"), code)
+	}
+	end := strings.IndexByte(code, '\n')
+	if end < 0 {
+		end = len(code)
+	}
+	return gomme.Success(code[:end], code[end:])
+}
+
 func ParseEmpty0(code string) gomme.Result[string, string] {
 	return gomme.Recognize(
 		gomme.Many0(
 			gomme.Alternative(
 				ParseComment,
+				ParseDirective,
 				gomme.Whitespace1[string](),
 			)))(code)
 }
@@ -36,6 +56,7 @@ func ParseEmpty1(code string) gomme.Result[string, string] {
 		gomme.Many1(
 			gomme.Alternative(
 				ParseComment,
+				ParseDirective,
 				gomme.Whitespace1[string](),
 			)))(code)
 }
@@ -55,10 +76,18 @@ func InEmpty[Output any](parser gomme.Parser[string, Output]) gomme.Parser[strin
 	)
 }
 
+// Identifier parses an IDL identifier: a letter followed by letters, digits
+// and underscores.
 func Identifier(code string) gomme.Result[string, string] {
-	return gomme.Recognize(
-		gomme.Pair(
-			gomme.Alpha1[string](),
-			gomme.Alphanumeric0[string](),
-		))(code)
+	end := 0
+	for i, r := range code {
+		if !unicode.IsLetter(r) && (i == 0 || r != '_' && !unicode.IsDigit(r)) {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+	if end == 0 {
+		return gomme.Failure[string, string](gomme.NewError[string](code, "Identifier"), code)
+	}
+	return gomme.Success(code[:end], code[end:])
 }
@@ -16,3 +16,23 @@ func TestInEmpty(t *testing.T) {
 	result = InEmpty(gomme.Token[string](";"))(code)
 	require.Equal(t, result.Output, ";")
 }
+
+func TestIdentifier(t *testing.T) {
+	result := Identifier("length_from(count)")
+	require.Nil(t, result.Err)
+	require.Equal(t, "length_from", result.Output)
+	require.Equal(t, "(count)", result.Remaining)
+
+	result = Identifier("a1_b2 x")
+	require.Equal(t, "a1_b2", result.Output)
+
+	require.NotNil(t, Identifier("_a").Err)
+	require.NotNil(t, Identifier("1a").Err)
+}
+
+func TestParseDirective(t *testing.T) {
+	result := ParseEmpty0("#include \"a/b.idl\"\n// c\n#include <x.idl>\nmodule m")
+	require.Equal(t, "module m", result.Remaining)
+
+	require.NotNil(t, ParseDirective("include").Err)
+}
@@ -0,0 +1,274 @@
+// Package avro maps IDL structs to Avro schemas and re-encodes the values
+// produced by converter.Decode as Avro binary records.
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+)
+
+type kind uint8
+
+const (
+	kindInt kind = iota
+	kindLong
+	// kindUnsigned is an Avro long holding an unsigned 64-bit IDL value;
+	// values above the int64 range cannot be encoded.
+	kindUnsigned
+	kindFloat
+	kindDouble
+	kindBoolean
+	kindString
+	kindBytes
+	kindArray
+	kindEnum
+	kindRecord
+)
+
+var primitives = map[kind]string{
+	kindInt:      "int",
+	kindLong:     "long",
+	kindUnsigned: "long",
+	kindFloat:    "float",
+	kindDouble:   "double",
+	kindBoolean:  "boolean",
+	kindString:   "string",
+	kindBytes:    "bytes",
+}
+
+var kinds = map[typ.FieldRefType]kind{
+	typ.Int8Type:             kindInt,
+	typ.OctetType:            kindInt,
+	typ.ShortType:            kindInt,
+	typ.UnsignedShortType:    kindInt,
+	typ.LongType:             kindInt,
+	typ.UnsignedLongType:     kindLong,
+	typ.LongLongType:         kindLong,
+	typ.UnsignedLongLongType: kindUnsigned,
+	typ.FloatType:            kindFloat,
+	typ.DoubleType:           kindDouble,
+	typ.BooleanType:          kindBoolean,
+	typ.StringType:           kindString,
+}
+
+// node is the Avro type of an IDL type.
+type node struct {
+	kind kind
+	// name is the Avro full name of a record or enum, e.g. "tm.gps.Fix".
+	name    string
+	elem    *node
+	fields  []field
+	symbols []string
+}
+
+type field struct {
+	name string
+	node *node
+}
+
+// Codec converts between the values of converter.Decode for one IDL struct
+// and Avro binary records of the matching schema.
+type Codec struct {
+	root   *node
+	schema []byte
+}
+
+// NewCodec maps the struct typeName, a scoped name such as "spi::CANFrame",
+// to Avro. Structs and bitsets become records and enums enums, each named
+// after its scoped name with "::" replaced by "."; sequence<octet> becomes
+// bytes, other sequences and typedef arrays arrays, and double double.
+// Integers up to 16 bits and long become
+// int, unsigned long and long long become long, and unsigned long long
+// becomes a long that only holds values up to the int64 maximum. Unions are
+// not supported.
+func NewCodec(module ast.Module, typeName string) (*Codec, error) {
+	def, owner, err := ast.NewGlobalScope(module).Resolve(typeName)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := def.(struct_type.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a struct", typeName)
+	}
+	m := &mapper{named: make(map[string]*node)}
+	root, err := m.record(st, owner)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := json.MarshalIndent(root.schema(make(map[string]bool)), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{root: root, schema: schema}, nil
+}
+
+// Schema returns the Avro schema as JSON.
+func (c *Codec) Schema() []byte {
+	return c.schema
+}
+
+// mapper builds nodes, sharing one node per named type so that recursive
+// types terminate.
+type mapper struct {
+	named map[string]*node
+}
+
+func (m *mapper) record(st struct_type.Struct, s *ast.Scope) (*node, error) {
+	n := &node{kind: kindRecord, name: avroName(s.Qualify(st.Name))}
+	m.named[n.name] = n
+	members, err := s.Members(st)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range members {
+		fn, err := m.typeNode(f.Type, f.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("st %v field %v: %v", st.Name, f.Name, err)
+		}
+		n.fields = append(n.fields, field{name: f.Name, node: fn})
+	}
+	return n, nil
+}
+
+func (m *mapper) typeNode(t typeref.TypeRef, s *ast.Scope) (*node, error) {
+	if k, ok := kinds[t.TypeRefType()]; ok {
+		return &node{kind: k}, nil
+	}
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		inner := t.(typeref.Sequence).InnerType
+		if inner.TypeRefType() == typ.OctetType {
+			return &node{kind: kindBytes}, nil
+		}
+		elem, err := m.typeNode(inner, s)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: kindArray, elem: elem}, nil
+	case typ.SelfDefinedTypeType:
+		return m.reference(t.TypeName(), s)
+	}
+	return nil, fmt.Errorf("unsupported type:%v", t.TypeName())
+}
+
+func (m *mapper) reference(name string, s *ast.Scope) (*node, error) {
+	def, owner, err := s.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := m.named[avroName(owner.Qualify(def.GetName()))]; ok {
+		return n, nil
+	}
+	switch d := def.(type) {
+	case struct_type.Struct:
+		return m.record(d, owner)
+	case enum_type.Enum:
+		n := &node{kind: kindEnum, name: avroName(owner.Qualify(d.Name)), symbols: d.Members}
+		m.named[n.name] = n
+		return n, nil
+	case bitset.BitSet:
+		return m.bitSet(d, owner), nil
+	case typedef_type.Typedef:
+		return m.typedef(d, owner)
+	case union_type.Union:
+		return nil, fmt.Errorf("union %v is not supported", name)
+	}
+	return nil, fmt.Errorf("%v is not a type", name)
+}
+
+// typedef maps a typedef to the node of the aliased type, with an array for
+// each dimension. Arrays of octets stay arrays so that their length is kept
+// by the element count.
+func (m *mapper) typedef(td typedef_type.Typedef, s *ast.Scope) (*node, error) {
+	n, err := m.typeNode(td.Aliased, s)
+	if err != nil {
+		return nil, fmt.Errorf("typedef %v: %v", td.Name, err)
+	}
+	for range td.Dims {
+		n = &node{kind: kindArray, elem: n}
+	}
+	return n, nil
+}
+
+// bitSet maps a bitset to a record with an int for each bitfield narrower
+// than 32 bits and a long for the others.
+func (m *mapper) bitSet(bs bitset.BitSet, s *ast.Scope) *node {
+	n := &node{kind: kindRecord, name: avroName(s.Qualify(bs.Name))}
+	m.named[n.name] = n
+	for _, f := range bs.Fields {
+		k := kindInt
+		switch {
+		case f.Type.Width == 64:
+			k = kindUnsigned
+		case f.Type.Width >= 32:
+			k = kindLong
+		}
+		n.fields = append(n.fields, field{name: f.Name, node: &node{kind: k}})
+	}
+	return n
+}
+
+func avroName(scoped string) string {
+	return strings.ReplaceAll(scoped, "::", ".")
+}
+
+type recordSchema struct {
+	Type      string        `json:"type"`
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace,omitempty"`
+	Fields    []fieldSchema `json:"fields"`
+}
+
+type fieldSchema struct {
+	Name string `json:"name"`
+	Type any    `json:"type"`
+}
+
+type enumSchema struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Symbols   []string `json:"symbols"`
+}
+
+type arraySchema struct {
+	Type  string `json:"type"`
+	Items any    `json:"items"`
+}
+
+// schema returns the JSON value of the schema of n. A named type is defined
+// where it first appears and referenced by its full name afterwards.
+func (n *node) schema(defined map[string]bool) any {
+	if p, ok := primitives[n.kind]; ok {
+		return p
+	}
+	if n.kind == kindArray {
+		return arraySchema{Type: "array", Items: n.elem.schema(defined)}
+	}
+	if defined[n.name] {
+		return n.name
+	}
+	defined[n.name] = true
+	namespace, name := "", n.name
+	if i := strings.LastIndex(n.name, "."); i >= 0 {
+		namespace, name = n.name[:i], n.name[i+1:]
+	}
+	if n.kind == kindEnum {
+		return enumSchema{Type: "enum", Name: name, Namespace: namespace, Symbols: n.symbols}
+	}
+	fields := make([]fieldSchema, len(n.fields))
+	for i, f := range n.fields {
+		fields[i] = fieldSchema{Name: f.name, Type: f.node.schema(defined)}
+	}
+	return recordSchema{Type: "record", Name: name, Namespace: namespace, Fields: fields}
+}
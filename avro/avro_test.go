@@ -0,0 +1,291 @@
+package avro
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/converter"
+)
+
+const telemetrySchema = `module tm {
+	enum Mode { OFF, ON };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<40> code;
+	};
+	module gps {
+		struct Fix {
+			float lat;
+			float lon;
+		};
+	};
+	struct Reading {
+		short temperature;
+		Mode mode;
+		string note;
+		unsigned long long counter;
+		unsigned long uptime;
+		Flags flags;
+		gps::Fix at;
+		sequence<gps::Fix, 16> track;
+		@length_prefix(1) sequence<octet> raw;
+		sequence<sequence<long>> matrix;
+		boolean valid;
+	};
+}`
+
+const readingAvroSchema = `{
+  "type": "record",
+  "name": "Reading",
+  "namespace": "tm",
+  "fields": [
+    {"name": "temperature", "type": "int"},
+    {"name": "mode", "type": {"type": "enum", "name": "Mode", "namespace": "tm", "symbols": ["OFF", "ON"]}},
+    {"name": "note", "type": "string"},
+    {"name": "counter", "type": "long"},
+    {"name": "uptime", "type": "long"},
+    {"name": "flags", "type": {"type": "record", "name": "Flags", "namespace": "tm", "fields": [
+      {"name": "level", "type": "int"},
+      {"name": "code", "type": "long"}
+    ]}},
+    {"name": "at", "type": {"type": "record", "name": "Fix", "namespace": "tm.gps", "fields": [
+      {"name": "lat", "type": "float"},
+      {"name": "lon", "type": "float"}
+    ]}},
+    {"name": "track", "type": {"type": "array", "items": "tm.gps.Fix"}},
+    {"name": "raw", "type": "bytes"},
+    {"name": "matrix", "type": {"type": "array", "items": {"type": "array", "items": "int"}}},
+    {"name": "valid", "type": "boolean"}
+  ]
+}`
+
+func parseModule(t *testing.T, code string) ast.Module {
+	result := ast.Parse(code)
+	require.Nil(t, result.Err)
+	return result.Output
+}
+
+func TestSchema(t *testing.T) {
+	c, err := NewCodec(parseModule(t, telemetrySchema), "tm::Reading")
+	require.NoError(t, err)
+	require.JSONEq(t, readingAvroSchema, string(c.Schema()))
+}
+
+func TestEncode(t *testing.T) {
+	c, err := NewCodec(parseModule(t, `module m {
+	enum E { A, B, C };
+	struct S {
+		long a;
+		long long b;
+		string s;
+		E e;
+		sequence<short> list;
+		sequence<octet> raw;
+		boolean t;
+		float f;
+	};
+}`), "m::S")
+	require.NoError(t, err)
+	got, err := c.Encode(map[string]interface{}{
+		"a":    int64(1),
+		"b":    int64(-65),
+		"s":    "foo",
+		"e":    "C",
+		"list": []interface{}{int64(3), int64(-1)},
+		"raw":  []byte{0xAB},
+		"t":    true,
+		"f":    float64(1),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x02,
+		0x81, 0x01,
+		0x06, 'f', 'o', 'o',
+		0x04,
+		0x04, 0x06, 0x01, 0x00,
+		0x02, 0xAB,
+		0x01,
+		0x00, 0x00, 0x80, 0x3F,
+	}, got)
+}
+
+// TestRoundTrip decodes an IDL payload with the converter, re-encodes it as
+// Avro and reads it back with the schema.
+func TestRoundTrip(t *testing.T) {
+	module := parseModule(t, telemetrySchema)
+	conv, err := converter.NewIDLConverterFromModule(module, "tm::Reading")
+	require.NoError(t, err)
+	codec, err := NewCodec(module, "tm::Reading")
+	require.NoError(t, err)
+
+	fix := func(lat, lon float64) map[string]interface{} {
+		return map[string]interface{}{"lat": lat, "lon": lon}
+	}
+	payload, err := conv.Encode(map[string]interface{}{
+		"temperature": -40,
+		"mode":        "ON",
+		"note":        "calibrated",
+		"counter":     uint64(math.MaxInt64),
+		"uptime":      uint32(math.MaxUint32),
+		"flags":       map[string]interface{}{"level": 5, "code": uint64(1) << 39},
+		"at":          fix(1.5, -2.25),
+		"track":       []interface{}{fix(0.5, 0.25), fix(-1, 2)},
+		"raw":         []interface{}{0, 127, 255},
+		"matrix":      []interface{}{[]interface{}{1, -2}, []interface{}{}},
+		"valid":       true,
+	})
+	require.NoError(t, err)
+	decoded, err := conv.Decode(payload)
+	require.NoError(t, err)
+
+	record, err := codec.Encode(decoded)
+	require.NoError(t, err)
+	got, err := codec.Decode(record)
+	require.NoError(t, err)
+	require.Equal(t, decoded, got)
+
+	conv.NumberMode = converter.NumberModeNative
+	native, err := conv.Decode(payload)
+	require.NoError(t, err)
+	nativeRecord, err := codec.Encode(native)
+	require.NoError(t, err)
+	require.Equal(t, record, nativeRecord)
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	c, err := NewCodec(parseModule(t, `module m {
+	struct Node {
+		long value;
+		sequence<Node> children;
+	};
+}`), "m::Node")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "type": "record",
+  "name": "Node",
+  "namespace": "m",
+  "fields": [
+    {"name": "value", "type": "int"},
+    {"name": "children", "type": {"type": "array", "items": "m.Node"}}
+  ]
+}`, string(c.Schema()))
+	tree := map[string]interface{}{
+		"value": int64(1),
+		"children": []interface{}{
+			map[string]interface{}{"value": int64(2), "children": []interface{}{}},
+		},
+	}
+	record, err := c.Encode(tree)
+	require.NoError(t, err)
+	got, err := c.Decode(record)
+	require.NoError(t, err)
+	require.Equal(t, tree, got)
+}
+
+func TestNewCodecErrors(t *testing.T) {
+	tests := []struct {
+		code     string
+		typeName string
+		err      string
+	}{
+		{`module m { struct S { long a; }; }`, "m::T", "type m::T not found"},
+		{`module m { enum E { A }; }`, "m::E", "m::E is not a struct"},
+		{`module m { struct S { Missing a; }; }`, "m::S", "st S field a: type Missing not found"},
+		{`module m { union U switch (long) { case 1: long a; }; struct S { U u; }; }`, "m::S", "st S field u: union U is not supported"},
+	}
+	for _, test := range tests {
+		_, err := NewCodec(parseModule(t, test.code), test.typeName)
+		require.EqualError(t, err, test.err, test.code)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	c, err := NewCodec(parseModule(t, `module m {
+	enum E { A };
+	struct S {
+		long a;
+		unsigned long long b;
+		E e;
+	};
+}`), "m::S")
+	require.NoError(t, err)
+	tests := []struct {
+		value map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"a": 1, "b": 1}, "record m.S missing field e"},
+		{map[string]interface{}{"a": 1, "b": 1, "e": "A", "x": 1}, "record m.S has no field x"},
+		{map[string]interface{}{"a": int64(math.MaxInt32) + 1, "b": 1, "e": "A"}, "record m.S encode field a error:2147483648 out of int range"},
+		{map[string]interface{}{"a": 1, "b": uint64(math.MaxUint64), "e": "A"}, "record m.S encode field b error:18446744073709551615 out of long range"},
+		{map[string]interface{}{"a": 1, "b": 1, "e": "B"}, `record m.S encode field e error:"B" is not a member of enum m.E`},
+		{map[string]interface{}{"a": "1", "b": 1, "e": "A"}, "record m.S encode field a error:expect integer got string"},
+	}
+	for _, test := range tests {
+		_, err := c.Encode(test.value)
+		require.EqualError(t, err, test.err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	c, err := NewCodec(parseModule(t, `module m {
+	struct S {
+		string s;
+	};
+}`), "m::S")
+	require.NoError(t, err)
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{}, "record m.S decode field s error:invalid varint"},
+		{[]byte{0x06, 'a'}, "record m.S decode field s error:length 3 exceeds data len 1"},
+		{[]byte{0x02, 'a', 'b'}, "1 trailing bytes"},
+	}
+	for _, test := range tests {
+		_, err := c.Decode(test.data)
+		require.EqualError(t, err, test.err)
+	}
+}
+
+func TestTypedefArrays(t *testing.T) {
+	module := parseModule(t, `module m {
+	typedef double double__2[2];
+	struct S {
+		int8 level;
+		double__2 pos;
+		string<4> name;
+	};
+}`)
+	c, err := NewCodec(module, "m::S")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "type": "record",
+  "name": "S",
+  "namespace": "m",
+  "fields": [
+    {"name": "level", "type": "int"},
+    {"name": "pos", "type": {"type": "array", "items": "double"}},
+    {"name": "name", "type": "string"}
+  ]
+}`, string(c.Schema()))
+
+	conv, err := converter.NewIDLConverterFromModule(module, "m::S")
+	require.NoError(t, err)
+	payload, err := conv.Encode(map[string]interface{}{"level": -3, "pos": []interface{}{0.1, -2}, "name": "base"})
+	require.NoError(t, err)
+	decoded, err := conv.Decode(payload)
+	require.NoError(t, err)
+	record, err := c.Encode(decoded)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x05,
+		0x04, 0x9A, 0x99, 0x99, 0x99, 0x99, 0x99, 0xB9, 0x3F, 0, 0, 0, 0, 0, 0, 0, 0xC0, 0x00,
+		0x08, 'b', 'a', 's', 'e',
+	}, record)
+	got, err := c.Decode(record)
+	require.NoError(t, err)
+	require.Equal(t, decoded, got)
+}
@@ -0,0 +1,349 @@
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Encode encodes v, the output of converter.Decode in either number mode,
+// as one Avro binary record. Integers may be given as any Go integer type,
+// as an integral float64 or as a json.Number, enums as enumerator names and
+// sequence<octet> as []byte or a slice of integers.
+func (c *Codec) Encode(v map[string]interface{}) ([]byte, error) {
+	return c.root.encode(nil, v)
+}
+
+func (n *node) encode(b []byte, v interface{}) ([]byte, error) {
+	switch n.kind {
+	case kindInt, kindLong, kindUnsigned:
+		i, err := integerOf(v)
+		if err != nil {
+			return nil, err
+		}
+		if n.kind == kindInt && (i < math.MinInt32 || i > math.MaxInt32) {
+			return nil, fmt.Errorf("%v out of int range", i)
+		}
+		return appendLong(b, i), nil
+	case kindFloat:
+		f, err := floatOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+	case kindDouble:
+		f, err := floatOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case kindBoolean:
+		t, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expect bool got %T", v)
+		}
+		if t {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case kindString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expect string got %T", v)
+		}
+		return append(appendLong(b, int64(len(s))), s...), nil
+	case kindBytes:
+		raw, err := bytesOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(appendLong(b, int64(len(raw))), raw...), nil
+	case kindArray:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect sequence got %T", v)
+		}
+		var err error
+		if len(list) > 0 {
+			b = appendLong(b, int64(len(list)))
+			for i, e := range list {
+				if b, err = n.elem.encode(b, e); err != nil {
+					return nil, fmt.Errorf("element %v: %v", i, err)
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+	case kindEnum:
+		name, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expect enumerator name got %T", v)
+		}
+		for i, symbol := range n.symbols {
+			if symbol == name {
+				return appendLong(b, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a member of enum %v", name, n.name)
+	case kindRecord:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect map got %T", v)
+		}
+		return n.encodeRecord(b, m)
+	}
+	return nil, fmt.Errorf("unsupported kind:%v", n.kind)
+}
+
+func (n *node) encodeRecord(b []byte, m map[string]interface{}) ([]byte, error) {
+	if len(m) > len(n.fields) {
+		for name := range m {
+			if !n.hasField(name) {
+				return nil, fmt.Errorf("record %v has no field %v", n.name, name)
+			}
+		}
+	}
+	var err error
+	for _, f := range n.fields {
+		v, ok := m[f.name]
+		if !ok {
+			return nil, fmt.Errorf("record %v missing field %v", n.name, f.name)
+		}
+		if b, err = f.node.encode(b, v); err != nil {
+			return nil, fmt.Errorf("record %v encode field %v error:%v", n.name, f.name, err)
+		}
+	}
+	return b, nil
+}
+
+func (n *node) hasField(name string) bool {
+	for _, f := range n.fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLong appends v zig-zag encoded as a variable-length integer.
+func appendLong(b []byte, v int64) []byte {
+	return binary.AppendUvarint(b, uint64(v<<1^v>>63))
+}
+
+func integerOf(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return unsignedOf(uint64(n))
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return unsignedOf(n)
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an int64", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%v is not an int64", n)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("expect integer got %T", v)
+}
+
+func unsignedOf(n uint64) (int64, error) {
+	if n > math.MaxInt64 {
+		return 0, fmt.Errorf("%v out of long range", n)
+	}
+	return int64(n), nil
+}
+
+func floatOf(v interface{}) (float64, error) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), nil
+	case float64:
+		return f, nil
+	case json.Number:
+		return f.Float64()
+	}
+	i, err := integerOf(v)
+	if err != nil {
+		return 0, fmt.Errorf("expect float got %T", v)
+	}
+	return float64(i), nil
+}
+
+func bytesOf(v interface{}) ([]byte, error) {
+	switch raw := v.(type) {
+	case []byte:
+		return raw, nil
+	case []interface{}:
+		b := make([]byte, len(raw))
+		for i, e := range raw {
+			octet, err := integerOf(e)
+			if err != nil {
+				return nil, fmt.Errorf("element %v: %v", i, err)
+			}
+			if octet < 0 || octet > math.MaxUint8 {
+				return nil, fmt.Errorf("element %v: %v out of octet range", i, octet)
+			}
+			b[i] = byte(octet)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("expect sequence got %T", v)
+}
+
+// Decode reads one Avro binary record back into the values that
+// converter.Decode returns in NumberModeWide: int64 for integers except
+// unsigned long long, which is uint64, float64 for floats and a slice of
+// int64 for sequence<octet>.
+func (c *Codec) Decode(data []byte) (map[string]interface{}, error) {
+	v, remained, err := c.root.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(remained) > 0 {
+		return nil, fmt.Errorf("%v trailing bytes", len(remained))
+	}
+	return v.(map[string]interface{}), nil
+}
+
+var errTruncated = errors.New("data truncated")
+
+func (n *node) decode(data []byte) (interface{}, []byte, error) {
+	switch n.kind {
+	case kindInt, kindLong:
+		return readLong(data)
+	case kindUnsigned:
+		v, remained, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v < 0 {
+			return nil, nil, fmt.Errorf("%v out of unsigned range", v)
+		}
+		return uint64(v), remained, nil
+	case kindFloat:
+		if len(data) < 4 {
+			return nil, nil, errTruncated
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), data[4:], nil
+	case kindDouble:
+		if len(data) < 8 {
+			return nil, nil, errTruncated
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+	case kindBoolean:
+		if len(data) < 1 {
+			return nil, nil, errTruncated
+		}
+		return data[0] != 0, data[1:], nil
+	case kindString, kindBytes:
+		raw, remained, err := readBytes(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n.kind == kindString {
+			return string(raw), remained, nil
+		}
+		list := make([]interface{}, len(raw))
+		for i, octet := range raw {
+			list[i] = int64(octet)
+		}
+		return list, remained, nil
+	case kindArray:
+		return n.decodeArray(data)
+	case kindEnum:
+		i, remained, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < 0 || i >= int64(len(n.symbols)) {
+			return nil, nil, fmt.Errorf("enum %v index %v out of range", n.name, i)
+		}
+		return n.symbols[i], remained, nil
+	case kindRecord:
+		m := make(map[string]interface{}, len(n.fields))
+		var v interface{}
+		var err error
+		for _, f := range n.fields {
+			if v, data, err = f.node.decode(data); err != nil {
+				return nil, nil, fmt.Errorf("record %v decode field %v error:%v", n.name, f.name, err)
+			}
+			m[f.name] = v
+		}
+		return m, data, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported kind:%v", n.kind)
+}
+
+// decodeArray reads the blocks of an array up to the empty block. A block
+// with a negative count is followed by its size in bytes.
+func (n *node) decodeArray(data []byte) (interface{}, []byte, error) {
+	list := []interface{}{}
+	for {
+		count, remained, err := readLong(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = remained
+		if count == 0 {
+			return list, data, nil
+		}
+		if count < 0 {
+			count = -count
+			if _, data, err = readLong(data); err != nil {
+				return nil, nil, err
+			}
+		}
+		if count > int64(len(data)) {
+			return nil, nil, fmt.Errorf("block of %v elements exceeds data len %v", count, len(data))
+		}
+		var v interface{}
+		for i := int64(0); i < count; i++ {
+			if v, data, err = n.elem.decode(data); err != nil {
+				return nil, nil, fmt.Errorf("element %v: %v", len(list), err)
+			}
+			list = append(list, v)
+		}
+	}
+}
+
+func readLong(data []byte) (int64, []byte, error) {
+	u, size := binary.Uvarint(data)
+	if size <= 0 {
+		return 0, nil, errors.New("invalid varint")
+	}
+	return int64(u>>1) ^ -int64(u&1), data[size:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	size, remained, err := readLong(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size < 0 || size > int64(len(remained)) {
+		return nil, nil, fmt.Errorf("length %v exceeds data len %v", size, len(remained))
+	}
+	return remained[:size], remained[size:], nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/yisaer/idl-parser/converter"
+	"github.com/yisaer/idl-parser/rosidl"
+)
+
+type converterFlags struct {
+	typeName     *string
+	hex          *bool
+	lengthPrefix *int
+	cdr          *bool
+	include      *string
+}
+
+func addConverterFlags(fs *flag.FlagSet) converterFlags {
+	return converterFlags{
+		typeName:     fs.String("type", "", "scoped name of the target struct, e.g. spi::CANFrame"),
+		hex:          fs.Bool("hex", false, "payloads are hex text instead of raw bytes"),
+		lengthPrefix: fs.Int("length-prefix", 0, "default length prefix size of strings and sequences (1, 2, 4 or 8)"),
+		cdr:          fs.Bool("cdr", false, "payloads are CDR encoded, as published by DDS and ROS 2"),
+		include:      fs.String("I", "", "directories searched for #include files, separated by "+string(filepath.ListSeparator)),
+	}
+}
+
+// converter returns the converter of the schema argument. A schema with
+// #include directives is loaded together with the files it includes.
+func (f converterFlags) converter(fs *flag.FlagSet) (*converter.IDLConverter, error) {
+	if *f.typeName == "" {
+		return nil, usageErrorf(fs, "-type is required")
+	}
+	c := &converter.IDLConverter{
+		SchemaPath:   fs.Arg(0),
+		TypeName:     *f.typeName,
+		LengthPrefix: *f.lengthPrefix,
+	}
+	if *f.cdr {
+		c.Encoding = converter.EncodingCDR
+	}
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(src, []byte("#include")) {
+		module, err := rosidl.Load(fs.Arg(0), filepath.SplitList(*f.include)...)
+		if err != nil {
+			return nil, err
+		}
+		c.SchemaPath, c.Module = "", module
+	}
+	return c, nil
+}
+
+// openInput opens the optional second argument, defaulting to stdin.
+func openInput(e *env, fs *flag.FlagSet) (io.ReadCloser, error) {
+	if fs.NArg() < 2 || fs.Arg(1) == "-" {
+		return io.NopCloser(e.stdin), nil
+	}
+	return os.Open(fs.Arg(1))
+}
+
+func runDecode(e *env, args []string) error {
+	fs := newFlagSet(e, "decode", "-type name [flags] schema.idl [data]")
+	cf := addConverterFlags(fs)
+	stream := fs.Bool("stream", false, "decode back-to-back records until the input ends")
+	native := fs.Bool("native", false, "decode numbers into their native width")
+	strict := fs.Bool("strict", false, "reject trailing bytes after the record")
+	if err := parseFlags(fs, args, 1, 2); err != nil {
+		return err
+	}
+	c, err := cf.converter(fs)
+	if err != nil {
+		return err
+	}
+	if *native {
+		c.NumberMode = converter.NumberModeNative
+	}
+	c.Strict = *strict
+	if err := c.Init(); err != nil {
+		return err
+	}
+	in, err := openInput(e, fs)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var r io.Reader = in
+	if *cf.hex {
+		data, err := readHex(in)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	out := json.NewEncoder(e.stdout)
+	if !*stream {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		m, err := c.Decode(data)
+		if err != nil {
+			return err
+		}
+		return out.Encode(m)
+	}
+	d := converter.NewDecoder(r, c)
+	for {
+		m, _, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record at offset %v: %w", d.Offset(), err)
+		}
+		if err := out.Encode(m); err != nil {
+			return err
+		}
+	}
+}
+
+func runEncode(e *env, args []string) error {
+	fs := newFlagSet(e, "encode", "-type name [flags] schema.idl [data.json]")
+	cf := addConverterFlags(fs)
+	if err := parseFlags(fs, args, 1, 2); err != nil {
+		return err
+	}
+	c, err := cf.converter(fs)
+	if err != nil {
+		return err
+	}
+	if err := c.Init(); err != nil {
+		return err
+	}
+	in, err := openInput(e, fs)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dec := json.NewDecoder(in)
+	dec.UseNumber()
+	for i := 0; ; i++ {
+		var m map[string]interface{}
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("value %v: %w", i, err)
+		}
+		data, err := c.Encode(m)
+		if err != nil {
+			return fmt.Errorf("value %v: %w", i, err)
+		}
+		if *cf.hex {
+			data = append([]byte(hex.EncodeToString(data)), '\n')
+		}
+		if _, err := e.stdout.Write(data); err != nil {
+			return err
+		}
+	}
+}
+
+// readHex reads hex text, ignoring whitespace.
+func readHex(r io.Reader) ([]byte, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, string(text))
+	return hex.DecodeString(digits)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/compat"
+	"github.com/yisaer/idl-parser/converter"
+)
+
+func runDiff(e *env, args []string) error {
+	fs := newFlagSet(e, "diff", "-type name [-cdr] [-extensibility kind] old.idl new.idl")
+	typeName := fs.String("type", "", "scoped name of the compared struct, e.g. spi::CANFrame")
+	cdr := fs.Bool("cdr", false, "payloads are CDR encoded, as published by DDS and ROS 2")
+	extensibility := fs.String("extensibility", "final", "extensibility with -cdr of the structs without an annotation: final, appendable or mutable")
+	if err := parseFlags(fs, args, 2, 2); err != nil {
+		return err
+	}
+	if *typeName == "" {
+		return usageErrorf(fs, "-type is required")
+	}
+	opts := compat.Options{}
+	var err error
+	if opts.Extensibility, err = typ.ParseExtensibility(*extensibility); err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+	if *cdr {
+		opts.Encoding = converter.EncodingCDR
+	}
+	oldModule, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newModule, err := loadSchema(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	changes, err := compat.Compare(oldModule, newModule, *typeName, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		fmt.Fprintln(e.stdout, c)
+	}
+	if compat.Breaking(changes) {
+		return errFailed
+	}
+	return nil
+}
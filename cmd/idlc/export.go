@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/avro"
+	"github.com/yisaer/idl-parser/jsonschema"
+	"github.com/yisaer/idl-parser/protogen"
+)
+
+func runExport(e *env, args []string) error {
+	fs := newFlagSet(e, "export", "-format jsonschema|avro|proto [flags] schema.idl")
+	format := fs.String("format", "jsonschema", "output format: jsonschema, avro or proto")
+	typeName := fs.String("type", "", "scoped name of the exported struct, e.g. spi::CANFrame (jsonschema, avro)")
+	out := fs.String("out", "", "directory of the generated .proto files, stdout if empty (proto)")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	switch *format {
+	case "jsonschema", "avro":
+		if *typeName == "" {
+			return usageErrorf(fs, "-type is required")
+		}
+	case "proto":
+	default:
+		return usageErrorf(fs, "unknown format %q", *format)
+	}
+	module, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *format == "proto" {
+		return exportProto(e, module, filepath.Base(fs.Arg(0)), *out)
+	}
+	var schema []byte
+	if *format == "avro" {
+		var codec *avro.Codec
+		if codec, err = avro.NewCodec(module, *typeName); err == nil {
+			schema = codec.Schema()
+		}
+	} else {
+		schema, err = jsonschema.Generate(module, *typeName)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.stdout.Write(append(schema, '\n'))
+	return err
+}
+
+// exportProto writes the generated files below dir, or to stdout when dir
+// is empty and there is a single file, and reports the lossy mappings on
+// stderr.
+func exportProto(e *env, module ast.Module, source, dir string) error {
+	files, losses, err := protogen.Generate(module, protogen.Config{Source: source})
+	if err != nil {
+		return err
+	}
+	for _, loss := range losses {
+		fmt.Fprintf(e.stderr, "lossy: %v\n", loss)
+	}
+	if dir == "" {
+		if len(files) != 1 {
+			return fmt.Errorf("schema generates %v files, set -out", len(files))
+		}
+		_, err := e.stdout.Write(files[0].Content)
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yisaer/idl-parser/printer"
+)
+
+func runFmt(e *env, args []string) error {
+	fs := newFlagSet(e, "fmt", "[-w] [-l] [schema.idl...]")
+	write := fs.Bool("w", false, "write the result back to the source file")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	if err := parseFlags(fs, args, 0, -1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		if *write {
+			return usageErrorf(fs, "-w needs file arguments")
+		}
+		src, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return err
+		}
+		out, err := format("<stdin>", src)
+		if err != nil {
+			return err
+		}
+		_, err = e.stdout.Write(out)
+		return err
+	}
+	failed := false
+	for _, path := range fs.Args() {
+		if err := formatFile(e, path, *write, *list); err != nil {
+			fmt.Fprintln(e.stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+func formatFile(e *env, path string, write, list bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := format(path, src)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(src, out)
+	if list && changed {
+		fmt.Fprintln(e.stdout, path)
+	}
+	if write {
+		if !changed {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, out, info.Mode().Perm())
+	}
+	if !list {
+		_, err = e.stdout.Write(out)
+	}
+	return err
+}
+
+// format reports parse errors with their position before formatting.
+func format(path string, src []byte) ([]byte, error) {
+	if _, err := parseSchema(path, string(src)); err != nil {
+		return nil, err
+	}
+	return printer.Format(src)
+}
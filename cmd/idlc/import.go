@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/printer"
+	"github.com/yisaer/idl-parser/protogen"
+	"github.com/yisaer/idl-parser/protoimport"
+	"github.com/yisaer/idl-parser/rosidl"
+)
+
+func runImport(e *env, args []string) error {
+	fs := newFlagSet(e, "import", "[-package name] schema.proto|Name.msg|Name.srv")
+	pkg := fs.String("package", "", "ROS package of a .msg or .srv file, by default the directory above msg/ or srv/")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var module ast.Module
+	switch ext := filepath.Ext(path); ext {
+	case ".msg", ".srv":
+		name := strings.TrimSuffix(filepath.Base(path), ext)
+		if *pkg == "" {
+			*pkg = filepath.Base(filepath.Dir(filepath.Dir(path)))
+		}
+		if ext == ".msg" {
+			module, err = rosidl.ParseMsg(*pkg, name, string(src))
+		} else {
+			module, err = rosidl.ParseSrv(*pkg, name, string(src))
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+	default:
+		var losses []protogen.Loss
+		module, losses, err = protoimport.Parse(string(src))
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		for _, loss := range losses {
+			fmt.Fprintf(e.stderr, "lossy: %v\n", loss)
+		}
+	}
+	_, err = e.stdout.Write(printer.Print(module))
+	return err
+}
@@ -0,0 +1,163 @@
+// Command idlc parses, validates and dumps IDL schemas, and converts
+// payloads between the binary wire format and JSON.
+//
+//	idlc parse [-format json|yaml] schema.idl
+//	idlc check schema.idl...
+//	idlc decode -type spi::CANFrame [-hex] [-cdr] [-stream] schema.idl [data]
+//	idlc encode -type spi::CANFrame [-hex] [-cdr] schema.idl [data.json]
+//	idlc fmt [-w] [-l] [schema.idl...]
+//	idlc diff -type spi::CANFrame [-cdr] [-extensibility kind] old.idl new.idl
+//	idlc export -format jsonschema|avro -type spi::CANFrame schema.idl
+//	idlc export -format proto [-out dir] schema.idl
+//	idlc import schema.proto
+//	idlc import [-package name] Name.msg|Name.srv
+//
+// The exit code is 0 on success, 1 when the input is invalid and 2 on a
+// usage error.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/oleiade/gomme"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+var (
+	errUsage = errors.New("usage error")
+	// errFailed reports a failure whose diagnostics were already printed.
+	errFailed = errors.New("failed")
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+var commands = []command{
+	{"parse", "dump the AST of a schema as JSON or YAML", runParse},
+	{"check", "validate schemas and print diagnostics", runCheck},
+	{"decode", "decode binary payloads to JSON", runDecode},
+	{"encode", "encode JSON values to binary payloads", runEncode},
+	{"fmt", "reformat schemas canonically", runFmt},
+	{"export", "export a schema to another schema language", runExport},
+	{"diff", "report compatible and breaking changes between two schema versions", runDiff},
+	{"import", "convert a proto3 file or a ROS 2 .msg or .srv file to IDL", runImport},
+}
+
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+func run(args []string, e *env) int {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return exitUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(e, args[1:])
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, errUsage):
+			return exitUsage
+		case errors.Is(err, errFailed):
+			return exitInvalid
+		}
+		fmt.Fprintf(e.stderr, "idlc %v: %v\n", cmd.name, err)
+		return exitInvalid
+	}
+	fmt.Fprintf(e.stderr, "idlc: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: idlc <command> [flags] [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// loadSchema parses the schema at path. Parse errors carry the line and
+// column where parsing stopped.
+func loadSchema(path string) (ast.Module, error) {
+	v, err := os.ReadFile(path)
+	if err != nil {
+		return ast.Module{}, err
+	}
+	return parseSchema(path, string(v))
+}
+
+func parseSchema(path, code string) (ast.Module, error) {
+	module, err := ast.ParseSchema(code)
+	if err != nil {
+		var perr *gomme.Error[string]
+		rest := code
+		if errors.As(err, &perr) {
+			rest = perr.Input
+		}
+		return ast.Module{}, fmt.Errorf("%v: %v", position(path, code, rest), err)
+	}
+	return module, nil
+}
+
+// position formats the location of rest, a suffix of code, as path:line:col.
+func position(path, code, rest string) string {
+	consumed := code[:len(code)-len(rest)]
+	line := strings.Count(consumed, "\n") + 1
+	col := len(consumed) - strings.LastIndex(consumed, "\n")
+	return fmt.Sprintf("%v:%v:%v", path, line, col)
+}
+
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: idlc %v %v\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and checks that between minArgs and maxArgs
+// positional arguments remain; maxArgs < 0 means no upper bound.
+func parseFlags(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < minArgs || maxArgs >= 0 && fs.NArg() > maxArgs {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func usageErrorf(fs *flag.FlagSet, format string, args ...any) error {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fs.Usage()
+	return errUsage
+}
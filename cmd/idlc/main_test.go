@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runIDLC(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+const frameHex = "2a abc3 00000001 00000002 0102"
+
+func TestParse(t *testing.T) {
+	code, out, _ := runIDLC("", "parse", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	var module map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &module))
+	require.Equal(t, "spi", module["name"])
+
+	code, out, _ = runIDLC("", "parse", "-format", "yaml", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	require.True(t, strings.HasPrefix(out, "name: spi\ncontent:\n  - name: Status\n"))
+
+	code, _, _ = runIDLC("", "parse", "-format", "xml", "testdata/frame.idl")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestCheck(t *testing.T) {
+	code, out, _ := runIDLC("", "check", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	require.Empty(t, out)
+
+	code, out, _ = runIDLC("", "check", "testdata/frame.idl", "testdata/invalid.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Equal(t, []string{
+		"testdata/invalid.idl:2:21: spi::A.a: type Missing not found",
+		"testdata/invalid.idl:3:9: spi::A is defined more than once",
+	}, strings.Split(strings.TrimSpace(out), "\n"))
+
+	code, _, _ = runIDLC("", "check")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestLoadSchemaPosition(t *testing.T) {
+	path := t.TempDir() + "/bad.idl"
+	require.NoError(t, os.WriteFile(path, []byte("module m {\n\tstruct A { octet ; };\n}"), 0o644))
+	_, err := loadSchema(path)
+	require.ErrorContains(t, err, path+":2:2: ")
+
+	require.NoError(t, os.WriteFile(path, []byte("module m {}\n// done\njunk"), 0o644))
+	_, err = loadSchema(path)
+	require.ErrorContains(t, err, path+":3:1: unexpected content")
+}
+
+func TestReopenedModule(t *testing.T) {
+	path := t.TempDir() + "/r.idl"
+	src := "module a { struct X { long v; }; };\nmodule a { struct Y { X x; }; };\n"
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+
+	code, out, stderr := runIDLC("", "check", path)
+	require.Equal(t, exitOK, code, stderr)
+	require.Empty(t, out)
+
+	code, out, stderr = runIDLC("00000007", "decode", "-type", "a::Y", "-hex", path)
+	require.Equal(t, exitOK, code, stderr)
+	require.JSONEq(t, `{"x":{"v":7}}`, out)
+
+	code, out, stderr = runIDLC("", "fmt", path)
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "module a {\n\tstruct X {\n\t\tlong v;\n\t};\n}\n\nmodule a {\n\tstruct Y {\n\t\tX x;\n\t};\n}\n", out)
+
+	require.NoError(t, os.WriteFile(path, []byte(src+"junk"), 0o644))
+	code, out, _ = runIDLC("", "check", path)
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, out, path+":3:1: unexpected content after module a")
+}
+
+func TestDecodeEncode(t *testing.T) {
+	code, out, stderr := runIDLC(frameHex, "decode", "-type", "spi::CANFrame", "-hex", "testdata/frame.idl")
+	require.Equal(t, exitOK, code, stderr)
+	require.JSONEq(t, `{"header":42,"id":{"bid":3,"cid":2748},"status":"FAILED","payload":[1,2]}`, out)
+
+	code, hexOut, stderr := runIDLC(out, "encode", "-type", "spi::CANFrame", "-hex", "testdata/frame.idl")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, strings.ReplaceAll(frameHex, " ", "")+"\n", hexOut)
+
+	code, out, stderr = runIDLC(frameHex+frameHex, "decode", "-type", "::spi::CANFrame", "-hex", "-stream", "-native", "testdata/frame.idl")
+	require.Equal(t, exitOK, code, stderr)
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	code, _, stderr = runIDLC(frameHex+"00", "decode", "-type", "spi::CANFrame", "-hex", "-strict", "testdata/frame.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, "trailing bytes")
+
+	code, _, stderr = runIDLC(`{"header":256}`, "encode", "-type", "spi::CANFrame", "testdata/frame.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, "value 0")
+
+	code, _, _ = runIDLC("", "decode", "testdata/frame.idl")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestDiff(t *testing.T) {
+	code, out, _ := runIDLC("", "diff", "-type", "spi::CANFrame", "testdata/frame.idl", "testdata/frame_v2.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Equal(t, []string{
+		"compatible: spi::Status.RETRY: enum value added",
+		"breaking: spi::CANFrame.payload: bound narrowed from unbounded to 64",
+		"breaking: spi::CANFrame.crc: field added",
+	}, strings.Split(strings.TrimSpace(out), "\n"))
+
+	code, out, _ = runIDLC("", "diff", "-type", "spi::CANFrame", "-cdr", "-extensibility", "appendable", "testdata/frame.idl", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	require.Empty(t, out)
+
+	code, _, _ = runIDLC("", "diff", "-type", "spi::CANFrame", "-extensibility", "open", "testdata/frame.idl", "testdata/frame_v2.idl")
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runIDLC("", "diff", "testdata/frame.idl", "testdata/frame_v2.idl")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, stderr := runIDLC("", "frobnicate")
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr, "unknown command")
+}
+
+func TestFmt(t *testing.T) {
+	path := t.TempDir() + "/frame.idl"
+	require.NoError(t, os.WriteFile(path, []byte("module m { struct A { octet a; // first\n long b; }; }"), 0o644))
+
+	code, out, _ := runIDLC("", "fmt", "-l", path)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, path+"\n", out)
+
+	code, _, _ = runIDLC("", "fmt", "-w", path)
+	require.Equal(t, exitOK, code)
+	formatted, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "module m {\n\tstruct A {\n\t\toctet a; // first\n\t\tlong b;\n\t};\n}\n", string(formatted))
+
+	code, out, _ = runIDLC("", "fmt", "-l", path)
+	require.Equal(t, exitOK, code)
+	require.Empty(t, out)
+
+	code, out, _ = runIDLC("module m{}", "fmt")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "module m {\n}\n", out)
+
+	code, _, stderr := runIDLC("", "fmt", "testdata/invalid.idl", "testdata/missing.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, "missing.idl")
+}
+
+func TestExport(t *testing.T) {
+	code, out, _ := runIDLC("", "export", "-type", "spi::CANFrame", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &schema))
+	require.Equal(t, "spi::CANFrame", schema["title"])
+	require.Contains(t, schema["$defs"], "spi.IdBits")
+
+	code, _, _ = runIDLC("", "export", "testdata/frame.idl")
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runIDLC("", "export", "-format", "xsd", "-type", "spi::CANFrame", "testdata/frame.idl")
+	require.Equal(t, exitUsage, code)
+	code, _, _ = runIDLC("", "export", "-type", "spi::Status", "testdata/frame.idl")
+	require.Equal(t, exitInvalid, code)
+}
+
+func TestExportAvro(t *testing.T) {
+	code, out, _ := runIDLC("", "export", "-format", "avro", "-type", "spi::CANFrame", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &schema))
+	require.Equal(t, "record", schema["type"])
+	require.Equal(t, "CANFrame", schema["name"])
+	require.Equal(t, "spi", schema["namespace"])
+
+	code, _, _ = runIDLC("", "export", "-format", "avro", "testdata/frame.idl")
+	require.Equal(t, exitUsage, code)
+}
+
+func TestExportProto(t *testing.T) {
+	code, out, stderr := runIDLC("", "export", "-format", "proto", "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, out, "package spi;\n")
+	require.Contains(t, out, "message CANFrame {\n")
+	require.Contains(t, stderr, "lossy: spi::CANFrame.header: octet widened to uint32\n")
+
+	dir := t.TempDir()
+	code, _, _ = runIDLC("", "export", "-format", "proto", "-out", dir, "testdata/frame.idl")
+	require.Equal(t, exitOK, code)
+	written, err := os.ReadFile(dir + "/spi.proto")
+	require.NoError(t, err)
+	require.Equal(t, out, string(written))
+}
+
+func TestImport(t *testing.T) {
+	code, out, stderr := runIDLC("", "import", "testdata/frame.proto")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "module spi {\n\tstruct CANFrame {\n\t\t@id(1) unsigned long header;\n\t\t@id(2) sequence<double> samples;\n\t};\n}\n", out)
+	require.Equal(t, "", stderr)
+
+	code, _, stderr = runIDLC("", "import", "testdata/frame.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, "testdata/frame.idl: line 1: expect syntax")
+}
+
+func TestImportMsg(t *testing.T) {
+	code, out, stderr := runIDLC("", "import", "testdata/demo_msgs/msg/Point.msg")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "module demo_msgs {\n\tmodule msg {\n\t\t@verbatim(language=comment, text=\"A point in 2D space\") struct Point {\n\t\t\tdouble x;\n\t\t\t@default(1.5) double y;\n\t\t};\n\t};\n}\n", out)
+
+	code, out, _ = runIDLC("", "import", "-package", "geometry_msgs", "testdata/demo_msgs/msg/Point.msg")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, out, "module geometry_msgs {")
+}
+
+func TestDecodeCDR(t *testing.T) {
+	const payload = "00010000" + "fb000000" + "00000000" + "000000000000f03f" + "000000000000f8bf"
+	code, out, stderr := runIDLC(payload, "decode", "-type", "demo_msgs::msg::Stamped", "-hex", "-cdr", "-I", "testdata", "testdata/demo_msgs/msg/Stamped.idl")
+	require.Equal(t, exitOK, code, stderr)
+	require.JSONEq(t, `{"seq":-5,"point":{"x":1,"y":-1.5}}`, out)
+
+	code, hexOut, stderr := runIDLC(out, "encode", "-type", "demo_msgs::msg::Stamped", "-hex", "-cdr", "-I", "testdata", "testdata/demo_msgs/msg/Stamped.idl")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, payload, strings.TrimSpace(hexOut))
+
+	code, _, stderr = runIDLC(payload, "decode", "-type", "demo_msgs::msg::Stamped", "-hex", "testdata/demo_msgs/msg/Stamped.idl")
+	require.Equal(t, exitInvalid, code)
+	require.Contains(t, stderr, `include "demo_msgs/msg/Point.idl" not found`)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yisaer/idl-parser/converter"
+	"github.com/yisaer/idl-parser/sema"
+)
+
+func runParse(e *env, args []string) error {
+	fs := newFlagSet(e, "parse", "[-format json|yaml] schema.idl")
+	format := fs.String("format", "json", "output format, json or yaml")
+	if err := parseFlags(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "yaml" {
+		return usageErrorf(fs, "unknown format %q", *format)
+	}
+	module, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(module, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		_, err = e.stdout.Write(append(out, '\n'))
+		return err
+	}
+	// Going through a yaml.Node keeps the field order and names of the JSON
+	// encoding.
+	var node yaml.Node
+	if err := yaml.Unmarshal(out, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(e.stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the flow and quoting styles inherited from JSON; the
+// encoder still quotes strings that would otherwise read as another type.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+func runCheck(e *env, args []string) error {
+	fs := newFlagSet(e, "check", "schema.idl...")
+	if err := parseFlags(fs, args, 1, -1); err != nil {
+		return err
+	}
+	failed := false
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(e.stdout, err)
+			failed = true
+			continue
+		}
+		module, err := parseSchema(path, string(src))
+		if err != nil {
+			fmt.Fprintln(e.stdout, err)
+			failed = true
+			continue
+		}
+		// Semantic errors carry positions; the converter's own checks only
+		// run on schemas that pass them, to avoid reporting a problem twice.
+		if _, errs := sema.Check(module, string(src)); len(errs) > 0 {
+			for _, err := range errs {
+				if err.Pos.IsValid() {
+					fmt.Fprintf(e.stdout, "%v:%v\n", path, err)
+				} else {
+					fmt.Fprintf(e.stdout, "%v: %v\n", path, err)
+				}
+			}
+			failed = true
+			continue
+		}
+		for _, err := range converter.Validate(module) {
+			fmt.Fprintf(e.stdout, "%v: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Command idlgen generates Go types with MarshalIDL/UnmarshalIDL methods from
+// an IDL schema. It is meant to be driven by go generate:
+//
+//	//go:generate go run github.com/yisaer/idl-parser/cmd/idlgen -schema spi.idl -out spi_idl.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/gogen"
+)
+
+func main() {
+	schema := flag.String("schema", "", "path of the IDL schema")
+	out := flag.String("out", "", "path of the generated Go file, stdout if empty")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	flag.Parse()
+
+	if err := run(*schema, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "idlgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schema, out, pkg string) error {
+	if schema == "" {
+		return fmt.Errorf("-schema is required")
+	}
+	v, err := os.ReadFile(schema)
+	if err != nil {
+		return err
+	}
+	module, err := ast.ParseSchema(string(v))
+	if err != nil {
+		return err
+	}
+	src, err := gogen.Generate(module, gogen.Config{Package: pkg, Source: filepath.Base(schema)})
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
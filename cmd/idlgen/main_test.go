@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReopenedModule(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "r.idl")
+	out := filepath.Join(dir, "r_idl.go")
+	require.NoError(t, os.WriteFile(schema, []byte("module a { struct X { long v; }; };\nmodule a { struct Y { X x; }; };\n"), 0o644))
+	require.NoError(t, run(schema, out, "r"))
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Contains(t, string(src), "type X struct")
+	require.Contains(t, string(src), "type Y struct")
+
+	require.NoError(t, os.WriteFile(schema, []byte("module a { struct X { long v; }; };\njunk"), 0o644))
+	require.ErrorContains(t, run(schema, out, "r"), "unexpected content after module a")
+}
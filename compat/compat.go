@@ -0,0 +1,494 @@
+// Package compat compares two versions of an IDL struct and classifies every
+// difference as compatible or breaking, depending on whether payloads written
+// with the old version still decode to the same values with the new one.
+package compat
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+	"github.com/yisaer/idl-parser/converter"
+)
+
+type Options struct {
+	// Encoding is the wire format of the payloads.
+	Encoding converter.Encoding
+	// Extensibility applies with EncodingCDR to every struct without an
+	// @extensibility annotation of its own. Packed payloads carry neither
+	// delimiters nor member ids, so their structs are always final.
+	Extensibility typ.Extensibility
+}
+
+func (o Options) extensibility(st struct_type.Struct) typ.Extensibility {
+	if o.Encoding != converter.EncodingCDR {
+		return typ.FinalExtensibility
+	}
+	if ext, ok, err := st.Annotations.Extensibility(); ok && err == nil {
+		return ext
+	}
+	return o.Extensibility
+}
+
+// ChangeKind is the kind of a difference between two versions.
+type ChangeKind int
+
+const (
+	FieldAdded ChangeKind = iota
+	FieldRemoved
+	FieldRetyped
+	FieldReordered
+	MemberIDChanged
+	LengthEncodingChanged
+	BoundNarrowed
+	BoundWidened
+	EnumValueAdded
+	EnumValueRemoved
+	EnumValueRenumbered
+	ExtensibilityChanged
+)
+
+var changeKindNames = []string{
+	FieldAdded:            "field added",
+	FieldRemoved:          "field removed",
+	FieldRetyped:          "field retyped",
+	FieldReordered:        "field reordered",
+	MemberIDChanged:       "member id changed",
+	LengthEncodingChanged: "length encoding changed",
+	BoundNarrowed:         "bound narrowed",
+	BoundWidened:          "bound widened",
+	EnumValueAdded:        "enum value added",
+	EnumValueRemoved:      "enum value removed",
+	EnumValueRenumbered:   "enum value renumbered",
+	ExtensibilityChanged:  "extensibility changed",
+}
+
+func (k ChangeKind) String() string {
+	if k < 0 || int(k) >= len(changeKindNames) {
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return changeKindNames[k]
+}
+
+// Change is one difference between the old and the new version.
+type Change struct {
+	Kind ChangeKind
+	// Path names the member or enum value changed, e.g. spi::CANFrame.id;
+	// members of nested structs are named after the nested struct.
+	Path string
+	// Old and New describe the value before and after for retyped fields,
+	// bounds, member ids, length encodings and enum values, e.g. long and
+	// short.
+	Old, New string
+	Breaking bool
+}
+
+func (c Change) String() string {
+	status := "compatible"
+	if c.Breaking {
+		status = "breaking"
+	}
+	s := status + ": " + c.Path + ": " + c.Kind.String()
+	if c.Old != "" || c.New != "" {
+		s += " from " + c.Old + " to " + c.New
+	}
+	return s
+}
+
+// Breaking reports whether any of changes is breaking.
+func Breaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare compares the struct typeName, a scoped name such as
+// "spi::CANFrame", in the old and the new module, together with every struct
+// and enum it uses, and returns the differences in the order the new version
+// declares them. Members are matched by name and types structurally, through
+// typedefs: a renamed struct with the same members is no change.
+func Compare(oldModule, newModule ast.Module, typeName string, opts Options) ([]Change, error) {
+	o, err := target(oldModule, typeName)
+	if err != nil {
+		return nil, fmt.Errorf("old: %v", err)
+	}
+	n, err := target(newModule, typeName)
+	if err != nil {
+		return nil, fmt.Errorf("new: %v", err)
+	}
+	c := &comparer{opts: opts, seen: make(map[string]bool)}
+	if err := c.structs(o, n); err != nil {
+		return nil, err
+	}
+	return c.changes, nil
+}
+
+func target(module ast.Module, typeName string) (shape, error) {
+	def, owner, err := ast.NewGlobalScope(module).Resolve(typeName)
+	if err != nil {
+		return shape{}, err
+	}
+	st, ok := def.(struct_type.Struct)
+	if !ok {
+		return shape{}, fmt.Errorf("%v is not a struct", typeName)
+	}
+	return shape{kind: kindStruct, name: owner.Qualify(st.Name), def: st, scope: owner}, nil
+}
+
+type shapeKind int
+
+const (
+	kindPrimitive shapeKind = iota
+	kindString
+	kindSequence
+	kindArray
+	kindStruct
+	kindEnum
+	// kindOther is a bitset or union, compared as a whole.
+	kindOther
+)
+
+// shape is a type with typedefs resolved.
+type shape struct {
+	kind shapeKind
+	// name is the primitive type name or the scoped name of a definition.
+	name  string
+	bound int
+	dims  []int
+	elem  *shape
+	def   ast.ModuleContent
+	scope *ast.Scope
+}
+
+func (s shape) String() string {
+	switch s.kind {
+	case kindString:
+		if s.bound > 0 {
+			return fmt.Sprintf("string<%d>", s.bound)
+		}
+		return "string"
+	case kindSequence:
+		if s.bound > 0 {
+			return fmt.Sprintf("sequence<%v, %d>", s.elem, s.bound)
+		}
+		return fmt.Sprintf("sequence<%v>", s.elem)
+	case kindArray:
+		var b strings.Builder
+		b.WriteString(s.elem.String())
+		for _, d := range s.dims {
+			fmt.Fprintf(&b, "[%d]", d)
+		}
+		return b.String()
+	}
+	return s.name
+}
+
+func resolve(t typeref.TypeRef, s *ast.Scope) (shape, error) {
+	switch t := t.(type) {
+	case typeref.Sequence:
+		elem, err := resolve(t.InnerType, s)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: kindSequence, bound: t.Bound, elem: &elem}, nil
+	case typeref.StringType:
+		return shape{kind: kindString, bound: t.Bound}, nil
+	case typeref.TypeName:
+		def, owner, err := s.Resolve(t.Name)
+		if err != nil {
+			return shape{}, err
+		}
+		name := owner.Qualify(def.GetName())
+		switch def := def.(type) {
+		case typedef_type.Typedef:
+			aliased, err := resolve(def.Aliased, owner)
+			if err != nil || len(def.Dims) == 0 {
+				return aliased, err
+			}
+			return shape{kind: kindArray, dims: def.Dims, elem: &aliased}, nil
+		case struct_type.Struct:
+			return shape{kind: kindStruct, name: name, def: def, scope: owner}, nil
+		case enum_type.Enum:
+			return shape{kind: kindEnum, name: name, def: def}, nil
+		case bitset.BitSet:
+			def.Name = ""
+			return shape{kind: kindOther, name: name, def: def}, nil
+		case union_type.Union:
+			def.Name = ""
+			return shape{kind: kindOther, name: name, def: def}, nil
+		}
+		return shape{}, fmt.Errorf("%v is not a type", t.Name)
+	}
+	return shape{kind: kindPrimitive, name: t.TypeName()}, nil
+}
+
+// sameType reports whether values of o decode as values of n, leaving
+// bounds and the members of structs and enums to be compared separately.
+func sameType(o, n shape) bool {
+	if o.kind != n.kind {
+		return false
+	}
+	switch o.kind {
+	case kindPrimitive:
+		return o.name == n.name
+	case kindSequence:
+		return sameType(*o.elem, *n.elem)
+	case kindArray:
+		return reflect.DeepEqual(o.dims, n.dims) && sameType(*o.elem, *n.elem)
+	case kindOther:
+		return reflect.DeepEqual(o.def, n.def)
+	}
+	return true
+}
+
+type comparer struct {
+	opts    Options
+	changes []Change
+	// seen holds the pairs of structs and enums compared so far, so that
+	// each is reported once and recursive types terminate.
+	seen map[string]bool
+}
+
+func (c *comparer) add(kind ChangeKind, path, from, to string, breaking bool) {
+	c.changes = append(c.changes, Change{Kind: kind, Path: path, Old: from, New: to, Breaking: breaking})
+}
+
+func (c *comparer) visit(o, n shape) bool {
+	key := o.name + " " + n.name
+	if c.seen[key] {
+		return false
+	}
+	c.seen[key] = true
+	return true
+}
+
+func (c *comparer) structs(o, n shape) error {
+	if !c.visit(o, n) {
+		return nil
+	}
+	oldMembers, err := o.scope.Members(o.def.(struct_type.Struct))
+	if err != nil {
+		return err
+	}
+	newMembers, err := n.scope.Members(n.def.(struct_type.Struct))
+	if err != nil {
+		return err
+	}
+	// The layout follows the extensibility of the new version; a change of
+	// it changes the layout of every member.
+	ext := c.opts.extensibility(n.def.(struct_type.Struct))
+	if prev := c.opts.extensibility(o.def.(struct_type.Struct)); prev != ext {
+		c.add(ExtensibilityChanged, n.name, prev.String(), ext.String(), true)
+	}
+	oldIndex := index(oldMembers)
+	newIndex := index(newMembers)
+	oldIDs, newIDs := memberIDs(oldMembers), memberIDs(newMembers)
+
+	// An appendable struct tolerates members missing or left over at the
+	// end; a mutable one anywhere.
+	for i, m := range oldMembers {
+		if _, ok := newIndex[m.Name]; !ok {
+			c.add(FieldRemoved, n.name+"."+m.Name, "", "", !(ext == typ.MutableExtensibility || ext == typ.AppendableExtensibility && i >= len(newMembers)))
+		}
+	}
+	moved := reordered(oldMembers, newMembers, oldIndex)
+	for i, m := range newMembers {
+		path := n.name + "." + m.Name
+		j, ok := oldIndex[m.Name]
+		if !ok {
+			c.add(FieldAdded, path, "", "", !(ext == typ.MutableExtensibility || ext == typ.AppendableExtensibility && i >= len(oldMembers)))
+			continue
+		}
+		switch {
+		case ext == typ.MutableExtensibility && oldIDs[j] != newIDs[i]:
+			c.add(MemberIDChanged, path, strconv.Itoa(oldIDs[j]), strconv.Itoa(newIDs[i]), true)
+		case moved[m.Name]:
+			c.add(FieldReordered, path, "", "", ext != typ.MutableExtensibility)
+		}
+		if err := c.member(path, oldMembers[j], m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *comparer) member(path string, o, n ast.Member) error {
+	ot, err := resolve(o.Type, o.Scope)
+	if err != nil {
+		return fmt.Errorf("old: %v: %v", path, err)
+	}
+	nt, err := resolve(n.Type, n.Scope)
+	if err != nil {
+		return fmt.Errorf("new: %v: %v", path, err)
+	}
+	if !sameType(ot, nt) {
+		c.add(FieldRetyped, path, ot.String(), nt.String(), true)
+		return nil
+	}
+	if c.opts.Encoding != converter.EncodingCDR {
+		if ol, nl := lengthEncoding(o.Field), lengthEncoding(n.Field); ol != nl {
+			c.add(LengthEncodingChanged, path, ol, nl, true)
+		}
+	}
+	return c.types(path, ot, nt)
+}
+
+// types compares the bounds and nested definitions of two types of the same
+// kind.
+func (c *comparer) types(path string, o, n shape) error {
+	switch o.kind {
+	case kindString, kindSequence:
+		switch {
+		case n.bound > 0 && (o.bound == 0 || n.bound < o.bound):
+			c.add(BoundNarrowed, path, bound(o.bound), bound(n.bound), true)
+		case o.bound > 0 && (n.bound == 0 || n.bound > o.bound):
+			c.add(BoundWidened, path, bound(o.bound), bound(n.bound), false)
+		}
+		if o.kind == kindSequence {
+			return c.types(path, *o.elem, *n.elem)
+		}
+	case kindArray:
+		return c.types(path, *o.elem, *n.elem)
+	case kindStruct:
+		return c.structs(o, n)
+	case kindEnum:
+		c.enums(o, n)
+	}
+	return nil
+}
+
+// enums compares the values of two enums, which are encoded by their
+// ordinal: a value added is compatible, since old payloads never hold it,
+// but a value removed or moved to another ordinal is not.
+func (c *comparer) enums(o, n shape) {
+	if !c.visit(o, n) {
+		return
+	}
+	oldMembers := o.def.(enum_type.Enum).Members
+	newMembers := n.def.(enum_type.Enum).Members
+	ordinals := make(map[string]int, len(newMembers))
+	for i, m := range newMembers {
+		ordinals[m] = i
+	}
+	for i, m := range oldMembers {
+		j, ok := ordinals[m]
+		switch {
+		case !ok:
+			c.add(EnumValueRemoved, n.name+"."+m, "", "", true)
+		case i != j:
+			c.add(EnumValueRenumbered, n.name+"."+m, strconv.Itoa(i), strconv.Itoa(j), true)
+		}
+	}
+	for _, m := range newMembers {
+		if !contains(oldMembers, m) {
+			c.add(EnumValueAdded, n.name+"."+m, "", "", false)
+		}
+	}
+}
+
+func index(members []ast.Member) map[string]int {
+	m := make(map[string]int, len(members))
+	for i, member := range members {
+		m[member.Name] = i
+	}
+	return m
+}
+
+// memberIDs returns the member ids of members: the value of @id, or one
+// more than the previous member's id, starting at 0.
+func memberIDs(members []ast.Member) []int {
+	ids := make([]int, len(members))
+	next := 0
+	for i, m := range members {
+		if anno, ok := m.Annotations.Get("id"); ok {
+			if v, err := strconv.Atoi(anno.Values["value"]); err == nil {
+				next = v
+			}
+		}
+		ids[i] = next
+		next++
+	}
+	return ids
+}
+
+// reordered returns the members kept in the new version that changed their
+// order relative to the others: those outside a longest run of kept members
+// whose order is unchanged.
+func reordered(oldMembers, newMembers []ast.Member, oldIndex map[string]int) map[string]bool {
+	var kept []ast.Member
+	var positions []int
+	for _, m := range newMembers {
+		if j, ok := oldIndex[m.Name]; ok {
+			kept = append(kept, m)
+			positions = append(positions, j)
+		}
+	}
+	// Longest increasing subsequence of the old positions, in the new order.
+	length := make([]int, len(positions))
+	prev := make([]int, len(positions))
+	best := -1
+	for i := range positions {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if positions[j] < positions[i] && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if best < 0 || length[i] > length[best] {
+			best = i
+		}
+	}
+	inOrder := make(map[int]bool)
+	for i := best; i >= 0; i = prev[i] {
+		inOrder[i] = true
+	}
+	moved := make(map[string]bool)
+	for i, m := range kept {
+		if !inOrder[i] {
+			moved[m.Name] = true
+		}
+	}
+	return moved
+}
+
+// lengthEncoding describes the length annotation of a member of a packed
+// struct.
+func lengthEncoding(field struct_type.Field) string {
+	for _, anno := range field.Annotations {
+		switch anno.Name {
+		case "length_prefix", "length_from":
+			return "@" + anno.Name + "(" + anno.Values["value"] + ")"
+		case "length_to_end":
+			return "@" + anno.Name
+		}
+	}
+	return "default"
+}
+
+func bound(n int) string {
+	if n == 0 {
+		return "unbounded"
+	}
+	return strconv.Itoa(n)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package compat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/converter"
+)
+
+func parse(t *testing.T, code string) ast.Module {
+	result := ast.Parse(code)
+	require.Nil(t, result.Err)
+	return result.Output
+}
+
+func compare(t *testing.T, oldCode, newCode string, opts Options) []string {
+	changes, err := Compare(parse(t, oldCode), parse(t, newCode), "m::S", opts)
+	require.NoError(t, err)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	return got
+}
+
+func TestCompare(t *testing.T) {
+	cdr := func(e typ.Extensibility) Options {
+		return Options{Encoding: converter.EncodingCDR, Extensibility: e}
+	}
+	tests := []struct {
+		name     string
+		old, new string
+		opts     Options
+		expected []string
+	}{
+		{
+			name: "unchanged through typedefs",
+			old:  `module m { struct S { long a; sequence<octet, 4> b; }; }`,
+			new:  `module m { typedef long L; typedef sequence<octet, 4> B; struct S { L a; B b; }; }`,
+		},
+		{
+			name: "field added at the end of a final struct",
+			old:  `module m { struct S { long a; }; }`,
+			new:  `module m { struct S { long a; short b; }; }`,
+			expected: []string{
+				"breaking: m::S.b: field added",
+			},
+		},
+		{
+			name: "field removed at the end of an appendable struct",
+			old:  `module m { struct S { long a; long b; }; }`,
+			new:  `module m { struct S { long a; }; }`,
+			opts: cdr(typ.AppendableExtensibility),
+			expected: []string{
+				"compatible: m::S.b: field removed",
+			},
+		},
+		{
+			name: "field replaced in an appendable struct",
+			old:  `module m { struct S { long a; long b; }; }`,
+			new:  `module m { struct S { long a; long c; short d; }; }`,
+			opts: cdr(typ.AppendableExtensibility),
+			expected: []string{
+				"breaking: m::S.b: field removed",
+				"breaking: m::S.c: field added",
+				"compatible: m::S.d: field added",
+			},
+		},
+		{
+			name: "appendable is final in the packed format",
+			old:  `module m { struct S { long a; }; }`,
+			new:  `module m { struct S { long a; short b; }; }`,
+			opts: Options{Extensibility: typ.AppendableExtensibility},
+			expected: []string{
+				"breaking: m::S.b: field added",
+			},
+		},
+		{
+			name: "retyped and reordered",
+			old:  `module m { struct S { long a; short b; string c; octet d; }; }`,
+			new:  `module m { struct S { short b; long a; string<8> c; long d; }; }`,
+			expected: []string{
+				"breaking: m::S.a: field reordered",
+				"breaking: m::S.c: bound narrowed from unbounded to 8",
+				"breaking: m::S.d: field retyped from octet to long",
+			},
+		},
+		{
+			name: "mutable members matched by id",
+			old:  `module m { struct S { @id(1) long a; @id(2) short b; long c; }; }`,
+			new:  `module m { struct S { @id(2) short b; @id(1) long a; @id(5) long e; long c; }; }`,
+			opts: cdr(typ.MutableExtensibility),
+			expected: []string{
+				"compatible: m::S.a: field reordered",
+				"compatible: m::S.e: field added",
+				"breaking: m::S.c: member id changed from 3 to 6",
+			},
+		},
+		{
+			name: "annotation overrides the default extensibility",
+			old:  `module m { @mutable struct S { @id(1) long a; @id(2) short b; }; }`,
+			new:  `module m { @mutable struct S { @id(2) short b; @id(1) long a; }; }`,
+			opts: cdr(typ.AppendableExtensibility),
+			expected: []string{
+				"compatible: m::S.a: field reordered",
+			},
+		},
+		{
+			name: "extensibility changed",
+			old:  `module m { struct S { long a; }; }`,
+			new:  `module m { @extensibility(APPENDABLE) struct S { long a; short b; }; }`,
+			opts: cdr(typ.FinalExtensibility),
+			expected: []string{
+				"breaking: m::S: extensibility changed from FINAL to APPENDABLE",
+				"compatible: m::S.b: field added",
+			},
+		},
+		{
+			name: "sequence bounds and elements",
+			old:  `module m { struct S { sequence<long, 8> a; sequence<long> b; sequence<sequence<short, 2>> c; sequence<long> d; }; }`,
+			new:  `module m { struct S { sequence<long, 4> a; sequence<long, 16> b; sequence<sequence<short>> c; sequence<short> d; }; }`,
+			expected: []string{
+				"breaking: m::S.a: bound narrowed from 8 to 4",
+				"breaking: m::S.b: bound narrowed from unbounded to 16",
+				"compatible: m::S.c: bound widened from 2 to unbounded",
+				"breaking: m::S.d: field retyped from sequence<long> to sequence<short>",
+			},
+		},
+		{
+			name: "nested structs and enums",
+			old: `module m {
+				enum Status { OK, FAILED, UNKNOWN };
+				struct Inner { octet x; Status s; };
+				struct S { Inner i; sequence<Inner> more; Status s; };
+			}`,
+			new: `module m {
+				enum State { OK, RETRY, UNKNOWN, LATER };
+				struct Inner2 { octet x; State s; octet y; };
+				struct S { Inner2 i; sequence<Inner2> more; State s; };
+			}`,
+			expected: []string{
+				"breaking: m::State.FAILED: enum value removed",
+				"compatible: m::State.RETRY: enum value added",
+				"compatible: m::State.LATER: enum value added",
+				"breaking: m::Inner2.y: field added",
+			},
+		},
+		{
+			name: "enum values renumbered",
+			old:  `module m { enum E { A, B }; struct S { E e; }; }`,
+			new:  `module m { enum E { Z, A, B }; struct S { E e; }; }`,
+			expected: []string{
+				"breaking: m::E.A: enum value renumbered from 0 to 1",
+				"breaking: m::E.B: enum value renumbered from 1 to 2",
+				"compatible: m::E.Z: enum value added",
+			},
+		},
+		{
+			name: "length encoding of the packed format",
+			old:  `module m { struct S { octet n; @length_from(n) sequence<octet> a; string b; }; }`,
+			new:  `module m { struct S { octet n; sequence<octet> a; @length_prefix(2) string b; }; }`,
+			expected: []string{
+				"breaking: m::S.a: length encoding changed from @length_from(n) to default",
+				"breaking: m::S.b: length encoding changed from default to @length_prefix(2)",
+			},
+		},
+		{
+			name: "inherited fields and arrays",
+			old:  `module m { typedef long Grid[2][2]; struct B { Grid g; }; struct S : B { long a; }; }`,
+			new:  `module m { typedef long Grid[4]; struct S { Grid g; long a; }; }`,
+			expected: []string{
+				"breaking: m::S.g: field retyped from long[2][2] to long[4]",
+			},
+		},
+		{
+			name: "recursive struct",
+			old:  `module m { struct S; struct S { long v; sequence<S> children; }; }`,
+			new:  `module m { struct S; struct S { long v; sequence<S, 4> children; }; }`,
+			expected: []string{
+				"breaking: m::S.children: bound narrowed from unbounded to 4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, compare(t, tt.old, tt.new, tt.opts))
+		})
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	tests := []struct {
+		old, new string
+		err      string
+	}{
+		{`module m { struct T { long a; }; }`, `module m { struct S { long a; }; }`, "old: type m::S not found"},
+		{`module m { struct S { long a; }; }`, `module m { enum S { A }; }`, "new: m::S is not a struct"},
+		{`module m { struct S { long a; }; }`, `module m { struct S { Missing a; }; }`, "new: m::S.a: type Missing not found"},
+	}
+	for _, tt := range tests {
+		_, err := Compare(parse(t, tt.old), parse(t, tt.new), "m::S", Options{})
+		require.EqualError(t, err, tt.err)
+	}
+}
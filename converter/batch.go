@@ -0,0 +1,269 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DataType is the Arrow type of a column.
+type DataType uint8
+
+const (
+	TypeInt8 DataType = iota
+	TypeUint8
+	TypeInt16
+	TypeUint16
+	TypeInt32
+	TypeUint32
+	TypeInt64
+	TypeUint64
+	TypeBool
+	TypeFloat32
+	TypeFloat64
+	TypeUtf8
+	TypeList
+	TypeStruct
+	TypeDictionary
+)
+
+var dataTypeNames = [...]string{
+	TypeInt8:       "int8",
+	TypeUint8:      "uint8",
+	TypeInt16:      "int16",
+	TypeUint16:     "uint16",
+	TypeInt32:      "int32",
+	TypeUint32:     "uint32",
+	TypeInt64:      "int64",
+	TypeUint64:     "uint64",
+	TypeBool:       "bool",
+	TypeFloat32:    "float32",
+	TypeFloat64:    "float64",
+	TypeUtf8:       "utf8",
+	TypeList:       "list",
+	TypeStruct:     "struct",
+	TypeDictionary: "dictionary",
+}
+
+// String returns the Arrow name of the type.
+func (t DataType) String() string {
+	return dataTypeNames[t]
+}
+
+var opDataTypes = [...]DataType{
+	opInt8:             TypeInt8,
+	opOctet:            TypeUint8,
+	opShort:            TypeInt16,
+	opUnsignedShort:    TypeUint16,
+	opLong:             TypeInt32,
+	opUnsignedLong:     TypeUint32,
+	opLongLong:         TypeInt64,
+	opUnsignedLongLong: TypeUint64,
+	opBoolean:          TypeBool,
+	opFloat:            TypeFloat32,
+	opDouble:           TypeFloat64,
+	opString:           TypeUtf8,
+	opSequence:         TypeList,
+	opStruct:           TypeStruct,
+	opEnum:             TypeDictionary,
+	opBitSet:           TypeStruct,
+}
+
+// Field describes a column the way an Arrow schema field does. No field is
+// nullable.
+type Field struct {
+	Name string
+	Type DataType
+	// Children holds the element of a list, named "item", or the members of
+	// a struct.
+	Children []Field
+	// Dictionary holds the enumerators of a dictionary column, whose indices
+	// are uint32.
+	Dictionary []string
+}
+
+// Column holds the values of one field for every record of a batch, in the
+// buffers of the matching Arrow layout.
+type Column struct {
+	Field Field
+	Len   int
+	// Values holds the values of a primitive column, or the indices of a
+	// dictionary column, as a slice of the Go type of the same width, e.g.
+	// []int16 or []float32.
+	Values any
+	// Offsets holds Len+1 offsets into Data for a utf8 column and into the
+	// element column for a list column.
+	Offsets []int32
+	Data    []byte
+	// Children holds the element column of a list or the member columns of
+	// a struct.
+	Children []*Column
+}
+
+// Batch is a columnar view of many records of the target struct, laid out
+// like an Arrow record batch: sequences become list columns, nested structs
+// and bitsets struct columns and enums dictionary columns.
+type Batch struct {
+	Len     int
+	Columns []*Column
+}
+
+// Schema returns the fields of the columns.
+func (b *Batch) Schema() []Field {
+	fields := make([]Field, len(b.Columns))
+	for i, col := range b.Columns {
+		fields[i] = col.Field
+	}
+	return fields
+}
+
+func (b *Batch) Column(name string) (*Column, bool) {
+	for _, col := range b.Columns {
+		if col.Field.Name == name {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeBatch decodes payloads, each one record of the target struct, into
+// a Batch. It fails on the first payload that does not decode.
+func (c *IDLConverter) DecodeBatch(payloads [][]byte) (*Batch, error) {
+	if c.plan == nil {
+		return nil, errors.New("converter is not initialized")
+	}
+	if err := c.checkPacked(); err != nil {
+		return nil, err
+	}
+	if c.plan.recursive {
+		return nil, fmt.Errorf("recursive struct %v is not supported", c.plan.name)
+	}
+	b := &Batch{Columns: make([]*Column, len(c.plan.instrs))}
+	for i := range c.plan.instrs {
+		b.Columns[i] = newColumn(&c.plan.instrs[i])
+	}
+	var rec Record
+	for i, payload := range payloads {
+		if err := c.DecodeRecord(payload, &rec); err != nil {
+			return nil, fmt.Errorf("payload %v: %v", i, err)
+		}
+		for j, col := range b.Columns {
+			col.append(rec.root.elems[j])
+		}
+		b.Len++
+	}
+	return b, nil
+}
+
+func newColumn(in *instruction) *Column {
+	col := &Column{Field: Field{Name: in.name}}
+	switch in.op {
+	case opSequence:
+		elem := newColumn(in.elem)
+		elem.Field.Name = "item"
+		col.Children = []*Column{elem}
+		col.Offsets = []int32{0}
+	case opString:
+		col.Offsets = []int32{0}
+	case opStruct, opBitSet:
+		for i := range in.plan.instrs {
+			col.Children = append(col.Children, newColumn(&in.plan.instrs[i]))
+		}
+	case opEnum:
+		col.Field.Dictionary = in.members
+	}
+	col.Field.Type = dataTypeOf(in)
+	for _, child := range col.Children {
+		col.Field.Children = append(col.Field.Children, child.Field)
+	}
+	col.Values = newValues(col.Field.Type)
+	return col
+}
+
+// dataTypeOf returns the column type of in; a bitfield gets the smallest
+// unsigned type holding its width.
+func dataTypeOf(in *instruction) DataType {
+	if in.op != opBitField {
+		return opDataTypes[in.op]
+	}
+	switch bitSetSize(int(in.width)) {
+	case 1:
+		return TypeUint8
+	case 2:
+		return TypeUint16
+	case 4:
+		return TypeUint32
+	}
+	return TypeUint64
+}
+
+func newValues(t DataType) any {
+	switch t {
+	case TypeInt8:
+		return []int8{}
+	case TypeUint8:
+		return []uint8{}
+	case TypeInt16:
+		return []int16{}
+	case TypeUint16:
+		return []uint16{}
+	case TypeInt32:
+		return []int32{}
+	case TypeUint32, TypeDictionary:
+		return []uint32{}
+	case TypeInt64:
+		return []int64{}
+	case TypeUint64:
+		return []uint64{}
+	case TypeBool:
+		return []bool{}
+	case TypeFloat32:
+		return []float32{}
+	case TypeFloat64:
+		return []float64{}
+	}
+	return nil
+}
+
+func (col *Column) append(v Value) {
+	col.Len++
+	switch values := col.Values.(type) {
+	case []int8:
+		col.Values = append(values, int8(v.bits))
+	case []uint8:
+		col.Values = append(values, uint8(v.bits))
+	case []int16:
+		col.Values = append(values, int16(v.bits))
+	case []uint16:
+		col.Values = append(values, uint16(v.bits))
+	case []int32:
+		col.Values = append(values, int32(v.bits))
+	case []uint32:
+		col.Values = append(values, uint32(v.bits))
+	case []int64:
+		col.Values = append(values, int64(v.bits))
+	case []uint64:
+		col.Values = append(values, v.bits)
+	case []bool:
+		col.Values = append(values, v.bits != 0)
+	case []float32:
+		col.Values = append(values, math.Float32frombits(uint32(v.bits)))
+	case []float64:
+		col.Values = append(values, math.Float64frombits(v.bits))
+	}
+	switch col.Field.Type {
+	case TypeUtf8:
+		col.Data = append(col.Data, v.raw...)
+		col.Offsets = append(col.Offsets, int32(len(col.Data)))
+	case TypeList:
+		elem := col.Children[0]
+		for _, e := range v.elems {
+			elem.append(e)
+		}
+		col.Offsets = append(col.Offsets, int32(elem.Len))
+	case TypeStruct:
+		for i, child := range col.Children {
+			child.append(v.elems[i])
+		}
+	}
+}
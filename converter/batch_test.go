@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const batchSchema = `module tm {
+	enum Mode { OFF, ON };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<10> code;
+	};
+	struct Point {
+		short x;
+		short y;
+	};
+	struct Reading {
+		octet id;
+		Mode mode;
+		Flags flags;
+		Point at;
+		string name;
+		sequence<Point> track;
+		float gain;
+	};
+}`
+
+func TestDecodeBatch(t *testing.T) {
+	c, err := NewIDLConverterFromString(batchSchema, "tm::Reading")
+	require.NoError(t, err)
+	b, err := c.DecodeBatch([][]byte{
+		{
+			0x01,
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x0A,
+			0x00, 0x01, 0xFF, 0xFE,
+			0x00, 0x00, 0x00, 0x02, 'a', 'b',
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x03, 0x00, 0x04,
+			0x3F, 0x80, 0x00, 0x00,
+		},
+		{
+			0x02,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x0B,
+			0x00, 0x05, 0x00, 0x06,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x07, 0x00, 0x08, 0x00, 0x09, 0x00, 0x0A,
+			0x40, 0x00, 0x00, 0x00,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, b.Len)
+
+	point := []Field{{Name: "x", Type: TypeInt16}, {Name: "y", Type: TypeInt16}}
+	require.Equal(t, []Field{
+		{Name: "id", Type: TypeUint8},
+		{Name: "mode", Type: TypeDictionary, Dictionary: []string{"OFF", "ON"}},
+		{Name: "flags", Type: TypeStruct, Children: []Field{{Name: "level", Type: TypeUint8}, {Name: "code", Type: TypeUint16}}},
+		{Name: "at", Type: TypeStruct, Children: point},
+		{Name: "name", Type: TypeUtf8},
+		{Name: "track", Type: TypeList, Children: []Field{{Name: "item", Type: TypeStruct, Children: point}}},
+		{Name: "gain", Type: TypeFloat32},
+	}, b.Schema())
+
+	col := func(name string) *Column {
+		c, ok := b.Column(name)
+		require.True(t, ok, name)
+		return c
+	}
+	require.Equal(t, []uint8{1, 2}, col("id").Values)
+	require.Equal(t, []uint32{1, 0}, col("mode").Values)
+	require.Equal(t, []uint8{2, 3}, col("flags").Children[0].Values)
+	require.Equal(t, []uint16{1, 1}, col("flags").Children[1].Values)
+	require.Equal(t, []int16{1, 5}, col("at").Children[0].Values)
+	require.Equal(t, []int16{-2, 6}, col("at").Children[1].Values)
+	require.Equal(t, []int32{0, 2, 2}, col("name").Offsets)
+	require.Equal(t, []byte("ab"), col("name").Data)
+	track := col("track")
+	require.Equal(t, []int32{0, 1, 3}, track.Offsets)
+	require.Equal(t, 3, track.Children[0].Len)
+	require.Equal(t, []int16{3, 7, 9}, track.Children[0].Children[0].Values)
+	require.Equal(t, []int16{4, 8, 10}, track.Children[0].Children[1].Values)
+	require.Equal(t, []float32{1, 2}, col("gain").Values)
+	require.Equal(t, "dictionary", col("mode").Field.Type.String())
+
+	_, err = c.DecodeBatch([][]byte{{0x01}})
+	require.ErrorContains(t, err, "payload 0: ")
+	empty, err := c.DecodeBatch(nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, empty.Len)
+	require.Len(t, empty.Columns, 7)
+}
@@ -0,0 +1,322 @@
+package converter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+)
+
+const cdrHeaderSize = 4
+
+// Encapsulation identifiers, the first two bytes of a payload: plain CDR
+// and the XCDR2 forms for a final, an appendable and a mutable top-level
+// type. The lowest bit selects little-endian.
+const (
+	cdrBigEndian    = 0x0000
+	cdrLittleEndian = 0x0001
+	cdr2BigEndian   = 0x0006
+	cdr2LittleEnd   = 0x0007
+	dCDR2BigEndian  = 0x0008
+	dCDR2LittleEnd  = 0x0009
+	plCDR2BigEndian = 0x000a
+	plCDR2LittleEnd = 0x000b
+)
+
+// Fields of an XCDR2 EMHEADER, which precedes each member of a mutable
+// struct: the must-understand flag, a length code and the member id.
+const (
+	emMustUnderstand = 1 << 31
+	emLengthShift    = 28
+	emIDMask         = 1<<28 - 1
+)
+
+// cdrDecoder walks a CDR body. Positions are relative to the end of the
+// encapsulation header, which is where alignment is counted from.
+type cdrDecoder struct {
+	decoder
+	littleEndian bool
+	// version2 is set for XCDR2, which aligns to at most 4 bytes and
+	// delimits appendable and mutable structs and collections of
+	// non-primitive elements with a DHEADER holding their size.
+	version2 bool
+	data     []byte
+	pos      int
+}
+
+// decodeCDR decodes a CDR payload and returns the number of bytes left
+// unconsumed, not counting the trailing padding announced in the low two
+// bits of the encapsulation options.
+func decodeCDR(p *plan, data []byte, dec decoder) (map[string]interface{}, int, error) {
+	if len(data) < cdrHeaderSize {
+		return nil, 0, fmt.Errorf("expect CDR encapsulation header got len %v", len(data))
+	}
+	d := &cdrDecoder{decoder: dec, data: data[cdrHeaderSize:]}
+	switch id := binary.BigEndian.Uint16(data); id {
+	case cdrBigEndian, cdrLittleEndian:
+	case cdr2BigEndian, cdr2LittleEnd, dCDR2BigEndian, dCDR2LittleEnd, plCDR2BigEndian, plCDR2LittleEnd:
+		d.version2 = true
+	default:
+		return nil, 0, fmt.Errorf("unsupported CDR encapsulation %#04x", id)
+	}
+	d.littleEndian = data[1]&1 == 1
+	m, err := d.decodeStruct(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	unconsumed := len(d.data) - d.pos
+	if padding := int(data[3] & 3); padding <= unconsumed {
+		unconsumed -= padding
+	}
+	return m, unconsumed, nil
+}
+
+func (d *cdrDecoder) decodeStruct(p *plan) (map[string]interface{}, error) {
+	if d.depth <= 0 {
+		return nil, p.depthError()
+	}
+	d.depth--
+	defer func() { d.depth++ }()
+	m := make(map[string]interface{}, len(p.instrs))
+	if p.extensibility == typ.FinalExtensibility || !d.version2 && p.extensibility == typ.AppendableExtensibility {
+		for i := range p.instrs {
+			if err := d.member(p, &p.instrs[i], m); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	if !d.version2 {
+		return nil, fmt.Errorf("mutable struct %v requires XCDR2 encoding", p.name)
+	}
+	end, err := d.delimiter()
+	if err != nil {
+		return nil, fmt.Errorf("struct %v: %v", p.name, err)
+	}
+	return m, d.within(end, func() error {
+		if p.extensibility == typ.MutableExtensibility {
+			return d.mutableMembers(p, m)
+		}
+		// Members missing at the end, written by an older version of the
+		// type, are left out; extra ones, written by a newer version, are
+		// skipped.
+		for i := range p.instrs {
+			if d.pos >= end {
+				break
+			}
+			if err := d.member(p, &p.instrs[i], m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *cdrDecoder) member(p *plan, in *instruction, m map[string]interface{}) error {
+	v, err := d.decode(in)
+	if err != nil {
+		return p.fieldError(in, err)
+	}
+	m[in.name] = v
+	return nil
+}
+
+// mutableMembers decodes the members of a mutable struct up to the end of
+// the data, each preceded by an EMHEADER naming it by member id. Members
+// may come in any order; unknown ones are skipped unless flagged as must
+// understand.
+func (d *cdrDecoder) mutableMembers(p *plan, m map[string]interface{}) error {
+	for d.pos < len(d.data) {
+		header, err := d.uint32()
+		if err != nil {
+			return fmt.Errorf("struct %v: %v", p.name, err)
+		}
+		id := header & emIDMask
+		var size int64
+		switch lc := header >> emLengthShift & 7; {
+		case lc < 4:
+			size = 1 << lc
+		case lc == 4:
+			if size, err = d.length(); err != nil {
+				return fmt.Errorf("struct %v member id %v: %v", p.name, id, err)
+			}
+		default:
+			// The NEXTINT is the start of the member itself, such as the
+			// length of a sequence, and gives its size.
+			n, err := d.length()
+			if err != nil {
+				return fmt.Errorf("struct %v member id %v: %v", p.name, id, err)
+			}
+			d.pos -= 4
+			size = 4 + n*[...]int64{1, 4, 8}[lc-5]
+		}
+		if size > int64(len(d.data)-d.pos) {
+			return fmt.Errorf("struct %v member id %v: expect data len %v got len %v", p.name, id, size, len(d.data)-d.pos)
+		}
+		end := d.pos + int(size)
+		i, ok := p.ids[id]
+		if !ok {
+			if header&emMustUnderstand != 0 {
+				return fmt.Errorf("struct %v has no member with id %v, which must be understood", p.name, id)
+			}
+			d.pos = end
+			continue
+		}
+		if err := d.within(end, func() error { return d.member(p, &p.instrs[i], m) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delimiter reads a DHEADER and returns the end of the data it delimits.
+func (d *cdrDecoder) delimiter() (int, error) {
+	n, err := d.length()
+	if err != nil {
+		return 0, err
+	}
+	if n > int64(len(d.data)-d.pos) {
+		return 0, fmt.Errorf("delimited data of %v bytes got len %v", n, len(d.data)-d.pos)
+	}
+	return d.pos + int(n), nil
+}
+
+// within runs decode on the data up to end and then moves past end.
+func (d *cdrDecoder) within(end int, decode func() error) error {
+	data := d.data
+	d.data = d.data[:end]
+	err := decode()
+	d.data = data
+	d.pos = end
+	return err
+}
+
+func (d *cdrDecoder) decode(in *instruction) (interface{}, error) {
+	switch in.op {
+	case opStruct:
+		return d.decodeStruct(in.plan)
+	case opString:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return "", nil
+		}
+		if err := in.checkBound(n - 1); err != nil {
+			return nil, err
+		}
+		b, err := d.take(1, n)
+		if err != nil {
+			return nil, errors.New("data truncated, insufficient bytes for string")
+		}
+		if b[n-1] != 0 {
+			return nil, errors.New("string is not NUL-terminated")
+		}
+		return string(b[:n-1]), nil
+	case opSequence:
+		if d.version2 && in.delimited() {
+			end, err := d.delimiter()
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			err = d.within(end, func() error {
+				var err error
+				v, err = d.sequence(in)
+				return err
+			})
+			return v, err
+		}
+		return d.sequence(in)
+	}
+	b, err := d.take(in.size, int64(in.size))
+	if err != nil {
+		return nil, err
+	}
+	if d.littleEndian {
+		var scratch [8]byte
+		b = scratch[:copy(scratch[:], b)]
+		reverse(b)
+	}
+	return d.fixed(in, b)
+}
+
+func (d *cdrDecoder) sequence(in *instruction) (interface{}, error) {
+	n := int64(in.length.count)
+	if in.length.kind != lengthFixed {
+		var err error
+		if n, err = d.length(); err != nil {
+			return nil, err
+		}
+	}
+	if err := in.checkBound(n); err != nil {
+		return nil, err
+	}
+	if err := checkListLen(in.elem, n, d.data[d.pos:]); err != nil {
+		return nil, err
+	}
+	list := make([]interface{}, n)
+	var err error
+	for i := range list {
+		if list[i], err = d.decode(in.elem); err != nil {
+			return nil, fmt.Errorf("parse sequence %v error:%v", in.elem.op, err.Error())
+		}
+	}
+	return list, nil
+}
+
+// length reads the 4-byte length of a string or sequence.
+func (d *cdrDecoder) length() (int64, error) {
+	v, err := d.uint32()
+	return int64(v), err
+}
+
+func (d *cdrDecoder) uint32() (uint32, error) {
+	b, err := d.take(4, 4)
+	if err != nil {
+		return 0, err
+	}
+	if d.littleEndian {
+		return binary.LittleEndian.Uint32(b), nil
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// take skips the padding up to a multiple of align bytes, at most 4 with
+// XCDR2, and returns the next n bytes.
+func (d *cdrDecoder) take(align int, n int64) ([]byte, error) {
+	if d.version2 && align > 4 {
+		align = 4
+	}
+	pos := (d.pos + align - 1) / align * align
+	if int64(len(d.data)-pos) < n {
+		return nil, fmt.Errorf("expect data len %v got len %v", n, max(len(d.data)-pos, 0))
+	}
+	d.pos = pos + int(n)
+	return d.data[pos:d.pos], nil
+}
+
+func encodeCDR(p *plan, m map[string]interface{}, depth int) ([]byte, error) {
+	e := encoder{cdr: true, version2: p.extensible, depth: depth}
+	id := cdrLittleEndian
+	if e.version2 {
+		id = [...]int{cdr2LittleEnd, dCDR2LittleEnd, plCDR2LittleEnd}[p.extensibility]
+	}
+	b := []byte{0x00, byte(id), 0x00, 0x00}
+	b, err := e.encodeStruct(b, p, m)
+	if err != nil {
+		return nil, err
+	}
+	padding := (4 - (len(b)-cdrHeaderSize)%4) % 4
+	b = append(b, make([]byte, padding)...)
+	b[3] = byte(padding)
+	return b, nil
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,274 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cdrSchema = `module m {
+	typedef float float__2[2];
+	struct Inner {
+		double d;
+	};
+	struct S {
+		octet a;
+		double b;
+		string c;
+		sequence<short> d;
+		float__2 e;
+		Inner f;
+		int8 g;
+	};
+}`
+
+var cdrData = []byte{
+	0x00, 0x01, 0x00, 0x03,
+	0x07, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0xF8, 0x3F,
+	0x03, 0, 0, 0, 'h', 'i', 0x00, 0,
+	0x02, 0, 0, 0, 0x01, 0x00, 0xFF, 0xFF,
+	0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0x40,
+	0, 0, 0, 0, 0, 0, 0x00, 0xC0,
+	0xFB, 0, 0, 0,
+}
+
+var cdrValue = map[string]interface{}{
+	"a": int64(7),
+	"b": 1.5,
+	"c": "hi",
+	"d": []interface{}{int64(1), int64(-1)},
+	"e": []interface{}{1.0, 2.0},
+	"f": map[string]interface{}{"d": -2.0},
+	"g": int64(-5),
+}
+
+func newCDRConverter(t *testing.T, schema, typeName string) *IDLConverter {
+	c := &IDLConverter{Schema: schema, TypeName: typeName, Encoding: EncodingCDR, Strict: true}
+	require.NoError(t, c.Init())
+	return c
+}
+
+func TestCDRDecode(t *testing.T) {
+	c := newCDRConverter(t, cdrSchema, "m::S")
+	m, err := c.Decode(cdrData)
+	require.NoError(t, err)
+	require.Equal(t, cdrValue, m)
+
+	got, err := c.Encode(cdrValue)
+	require.NoError(t, err)
+	require.Equal(t, cdrData, got)
+
+	var v struct {
+		A uint8
+		B float64
+		C string
+		D []int16
+		E [2]float32
+		G int8
+	}
+	require.NoError(t, c.DecodeInto(cdrData, &v))
+	require.Equal(t, [2]float32{1, 2}, v.E)
+	require.Equal(t, int8(-5), v.G)
+	require.Equal(t, []int16{1, -1}, v.D)
+}
+
+func TestCDRBigEndian(t *testing.T) {
+	c := newCDRConverter(t, `module m { struct S { octet a; long b; string s; }; }`, "m::S")
+	m, err := c.Decode([]byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x01, 0, 0, 0, 0x00, 0x00, 0x00, 0x2A,
+		0x00, 0x00, 0x00, 0x01, 0x00,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": int64(1), "b": int64(42), "s": ""}, m)
+}
+
+func TestCDRTrailingBytes(t *testing.T) {
+	c := newCDRConverter(t, `module m { struct S { octet a; }; }`, "m::S")
+	_, err := c.Decode([]byte{0x00, 0x01, 0x00, 0x03, 0x01, 0, 0, 0})
+	require.NoError(t, err)
+	_, err = c.Decode([]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0, 0, 0})
+	require.ErrorIs(t, err, ErrTrailingBytes)
+	m, unconsumed, err := c.DecodePartial([]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, 3, unconsumed)
+	require.Equal(t, int64(1), m["a"])
+}
+
+func TestCDRErrors(t *testing.T) {
+	c := newCDRConverter(t, `module m { struct S { string<3> s; double d; }; }`, "m::S")
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{0x00, 0x01}, "expect CDR encapsulation header got len 2"},
+		{[]byte{0x00, 0x03, 0x00, 0x00}, "unsupported CDR encapsulation 0x0003"},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x02, 0, 0, 0, 'a', 'b'}, "struct S parse field s error:string is not NUL-terminated"},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x05, 0, 0, 0, 'a', 'b', 'c', 'd', 0}, "struct S parse field s error:string of 4 bytes exceeds bound 3"},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x03, 0, 0, 0, 'a', 0}, "struct S parse field s error:data truncated, insufficient bytes for string"},
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0, 1, 2}, "struct S parse field d error:expect data len 8 got len 2"},
+	}
+	for _, test := range tests {
+		_, err := c.Decode(test.data)
+		require.EqualError(t, err, test.err)
+	}
+
+	var r Record
+	require.EqualError(t, c.DecodeRecord([]byte{0x00, 0x01, 0x00, 0x00}, &r), "not supported with CDR encoding")
+	_, err := c.DecodeBatch(nil)
+	require.EqualError(t, err, "not supported with CDR encoding")
+
+	_, err = c.Encode(map[string]interface{}{"s": "abcd", "d": 1})
+	require.EqualError(t, err, "struct S encode field s error:string of 4 bytes exceeds bound 3")
+
+	for schema, msg := range map[string]string{
+		`module m { struct S { @length_prefix(2) string s; }; }`: "st S field s: @length_prefix is not supported with CDR encoding",
+		`module m { struct S { @length_to_end string s; }; }`:    "st S field s: @length_to_end is not supported with CDR encoding",
+	} {
+		err := (&IDLConverter{Schema: schema, TypeName: "m::S", Encoding: EncodingCDR}).Init()
+		require.EqualError(t, err, msg)
+	}
+	err = (&IDLConverter{Schema: `module m { struct S { long a; }; }`, TypeName: "m::S", Encoding: EncodingCDR, LengthPrefix: 2}).Init()
+	require.EqualError(t, err, "length prefix 2 is not supported with CDR encoding")
+}
+
+func TestCDRExtensibility(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   []byte
+		value  map[string]interface{}
+	}{
+		{
+			name:   "XCDR2 aligns to 4 bytes",
+			schema: `module m { struct S { long a; double d; }; }`,
+			data: []byte{
+				0x00, 0x07, 0x00, 0x00,
+				0x01, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0xF8, 0x3F,
+			},
+			value: map[string]interface{}{"a": int64(1), "d": 1.5},
+		},
+		{
+			name:   "appendable with an extra trailing member",
+			schema: `module m { @appendable struct S { octet a; long b; }; }`,
+			data: []byte{
+				0x00, 0x09, 0x00, 0x00,
+				0x0C, 0, 0, 0,
+				0x01, 0, 0, 0,
+				0x2A, 0, 0, 0,
+				0xFF, 0xFF, 0xFF, 0xFF,
+			},
+			value: map[string]interface{}{"a": int64(1), "b": int64(42)},
+		},
+		{
+			name:   "appendable with a missing trailing member",
+			schema: `module m { @appendable struct S { octet a; long b; }; }`,
+			data: []byte{
+				0x00, 0x09, 0x00, 0x03,
+				0x01, 0, 0, 0,
+				0x01, 0, 0, 0,
+			},
+			value: map[string]interface{}{"a": int64(1)},
+		},
+		{
+			name:   "mutable members out of order",
+			schema: `module m { @mutable struct S { @id(1) long a; @id(5) string s; double d; }; }`,
+			data: []byte{
+				0x00, 0x0B, 0x00, 0x00,
+				0x28, 0, 0, 0,
+				0x05, 0, 0, 0x50, 0x03, 0, 0, 0, 'h', 'i', 0, 0,
+				0x01, 0, 0, 0x20, 0x2A, 0, 0, 0,
+				0x09, 0, 0, 0x00, 0xFF, 0, 0, 0,
+				0x06, 0, 0, 0x30, 0, 0, 0, 0, 0, 0, 0xF8, 0x3F,
+			},
+			value: map[string]interface{}{"a": int64(42), "s": "hi", "d": 1.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newCDRConverter(t, tt.schema, "m::S").Decode(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.value, m)
+		})
+	}
+}
+
+func TestCDRExtensibilityEncode(t *testing.T) {
+	tests := []struct {
+		schema string
+		value  map[string]interface{}
+		data   []byte
+	}{
+		{
+			schema: `module m { @mutable struct S { @id(1) long a; @id(5) string s; double d; }; }`,
+			value:  map[string]interface{}{"a": int64(42), "s": "hi"},
+			data: []byte{
+				0x00, 0x0B, 0x00, 0x01,
+				0x1B, 0, 0, 0,
+				0x01, 0, 0, 0x40, 0x04, 0, 0, 0, 0x2A, 0, 0, 0,
+				0x05, 0, 0, 0x40, 0x07, 0, 0, 0, 0x03, 0, 0, 0, 'h', 'i', 0, 0,
+			},
+		},
+		{
+			schema: `module m { @mutable struct S { @key @id(2) short k; long v; }; }`,
+			value:  map[string]interface{}{"k": int64(7), "v": int64(9)},
+			data: []byte{
+				0x00, 0x0B, 0x00, 0x00,
+				0x18, 0, 0, 0,
+				0x02, 0, 0, 0xC0, 0x02, 0, 0, 0, 0x07, 0, 0, 0,
+				0x03, 0, 0, 0x40, 0x04, 0, 0, 0, 0x09, 0, 0, 0,
+			},
+		},
+		{
+			schema: `module m { @appendable struct P { short x; }; struct S { sequence<P> ps; octet o; }; }`,
+			value: map[string]interface{}{
+				"ps": []interface{}{map[string]interface{}{"x": int64(1)}, map[string]interface{}{"x": int64(2)}},
+				"o":  int64(3),
+			},
+			data: []byte{
+				0x00, 0x07, 0x00, 0x01,
+				0x12, 0, 0, 0,
+				0x02, 0, 0, 0,
+				0x02, 0, 0, 0, 0x01, 0, 0, 0,
+				0x02, 0, 0, 0, 0x02, 0,
+				0x03, 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		c := newCDRConverter(t, tt.schema, "m::S")
+		got, err := c.Encode(tt.value)
+		require.NoError(t, err)
+		require.Equal(t, tt.data, got)
+		m, err := c.Decode(got)
+		require.NoError(t, err)
+		require.Equal(t, tt.value, m)
+	}
+}
+
+func TestCDRExtensibilityErrors(t *testing.T) {
+	c := newCDRConverter(t, `module m { @mutable struct S { @id(1) long a; }; }`, "m::S")
+	tests := []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{0x00, 0x01, 0x00, 0x00, 0x2A, 0, 0, 0}, "mutable struct S requires XCDR2 encoding"},
+		{[]byte{0x00, 0x0B, 0x00, 0x00, 0x10, 0, 0, 0}, "struct S: delimited data of 16 bytes got len 0"},
+		{[]byte{0x00, 0x0B, 0x00, 0x00, 0x08, 0, 0, 0, 0x09, 0, 0, 0xA0, 0, 0, 0, 0}, "struct S has no member with id 9, which must be understood"},
+		{[]byte{0x00, 0x0B, 0x00, 0x00, 0x08, 0, 0, 0, 0x01, 0, 0, 0x30, 0, 0, 0, 0}, "struct S member id 1: expect data len 8 got len 4"},
+	}
+	for _, tt := range tests {
+		_, err := c.Decode(tt.data)
+		require.EqualError(t, err, tt.err)
+	}
+
+	for schema, msg := range map[string]string{
+		`module m { @mutable struct S { @id(1) long a; @id(1) long b; }; }`: "st S field b: member id 1 is already used by a",
+		`module m { @mutable struct S { @id(268435456) long a; }; }`:        `st S field a: @id expects a member id below 2^28, got "268435456"`,
+	} {
+		err := (&IDLConverter{Schema: schema, TypeName: "m::S", Encoding: EncodingCDR}).Init()
+		require.EqualError(t, err, msg)
+	}
+}
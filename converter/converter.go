@@ -1,350 +1,589 @@
 package converter
 
 import (
-	"container/list"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/forward_type"
 	"github.com/yisaer/idl-parser/ast/struct_type"
-	"github.com/yisaer/idl-parser/ast/typ"
 	"github.com/yisaer/idl-parser/ast/typeref"
 )
 
+// NumberMode selects the Go types used for decoded numeric fields.
+type NumberMode int
+
+const (
+	// NumberModeWide decodes every signed or narrow integer as int64,
+	// unsigned long long as uint64 and float and double as float64.
+	NumberModeWide NumberMode = iota
+	// NumberModeNative decodes each field into the Go type of the same width,
+	// e.g. short as int16, unsigned long as uint32 and float as float32.
+	NumberModeNative
+)
+
+// Encoding selects the wire format of the target struct.
+type Encoding int
+
+const (
+	// EncodingPacked is the default format: big-endian values without
+	// padding and length prefixes as configured.
+	EncodingPacked Encoding = iota
+	// EncodingCDR is the OMG CDR format used by DDS and ROS 2: a 4-byte
+	// encapsulation header selecting the byte order, values aligned to
+	// their size and 4-byte lengths, with strings NUL-terminated.
+	EncodingCDR
+)
+
+var ErrTrailingBytes = errors.New("trailing bytes after struct")
+
+const defaultLengthPrefix = 4
+
+const defaultMaxDepth = 100
+
+// IDLConverter decodes binary payloads of one target struct. The schema is
+// taken from SchemaPath, Schema or an already parsed Module, in that order,
+// and TypeName is the scoped name of the target, e.g. "spi::CANFrame".
 type IDLConverter struct {
 	SchemaID   string
 	SchemaPath string
-	Module     ast.Module
-	list       *list.List
-	tarStruct  struct_type.Struct
+	Schema     string
+	TypeName   string
+	NumberMode NumberMode
+	// LengthPrefix is the size in bytes (1, 2, 4 or 8) of the inline length
+	// prefix of strings and sequences without a length annotation. Zero
+	// means 4.
+	LengthPrefix int
+	// Strict makes Decode, DecodeInto and DecodeRecord reject data that is
+	// longer than the target struct.
+	Strict bool
+	// Encoding is the wire format. DecodeRecord, DecodeBatch and Decoder
+	// support only EncodingPacked.
+	Encoding Encoding
+	// MaxDepth limits how deeply structs nest in a payload, the target
+	// struct being at depth 1, which bounds the work spent on recursive
+	// types such as trees. Zero means 100.
+	MaxDepth  int
+	Module    ast.Module
+	tarStruct struct_type.Struct
+	tarScope  *ast.Scope
+	plan      *plan
+	bindings  sync.Map
+}
+
+func NewIDLConverterFromFile(path, typeName string) (*IDLConverter, error) {
+	c := &IDLConverter{SchemaPath: path, TypeName: typeName}
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func NewIDLConverterFromString(schema, typeName string) (*IDLConverter, error) {
+	c := &IDLConverter{Schema: schema, TypeName: typeName}
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func NewIDLConverterFromModule(module ast.Module, typeName string) (*IDLConverter, error) {
+	c := &IDLConverter{Module: module, TypeName: typeName}
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *IDLConverter) Init() error {
-	v, err := os.ReadFile(c.SchemaPath)
-	if err != nil {
+	if err := c.loadModule(); err != nil {
 		return err
 	}
-	res := ast.Parse(string(v))
-	if res.Err != nil {
-		return res.Err
-	}
-	c.Module = res.Output
-	c.list = list.New()
 	if err := c.travelModule(); err != nil {
 		return err
 	}
-	if err := c.verifyModule(c.Module); err != nil {
+	if err := verifyStruct(ast.NewGlobalScope(c.Module)); err != nil {
 		return err
 	}
-	if err := c.verifyStruct(c.Module); err != nil {
+	return c.bindTarget(c.tarStruct, c.tarScope)
+}
+
+func (c *IDLConverter) bindTarget(st struct_type.Struct, s *ast.Scope) error {
+	lengthPrefix := c.LengthPrefix
+	if lengthPrefix == 0 {
+		lengthPrefix = defaultLengthPrefix
+	}
+	if !validLengthPrefix(lengthPrefix) {
+		return fmt.Errorf("length prefix %v, expect 1, 2, 4 or 8", c.LengthPrefix)
+	}
+	cp := newCompiler(lengthPrefix)
+	switch c.Encoding {
+	case EncodingPacked:
+	case EncodingCDR:
+		if lengthPrefix != defaultLengthPrefix {
+			return fmt.Errorf("length prefix %v is not supported with CDR encoding", c.LengthPrefix)
+		}
+		cp.cdr = true
+	default:
+		return fmt.Errorf("unknown encoding %v", c.Encoding)
+	}
+	p, err := cp.compileStruct(st, s)
+	if err != nil {
 		return err
 	}
+	c.tarStruct = st
+	c.tarScope = s
+	c.plan = p
+	c.bindings.Clear()
 	return nil
 }
 
-func (c *IDLConverter) verifyModuleSeq() error {
-	for _, con := range c.Module.Content {
-		st, ok := con.(struct_type.Struct)
-		if !ok {
-			continue
-		}
-		for index, field := range st.Fields {
-			if field.Type.TypeRefType() == typ.SequenceType {
-				if index == 0 {
-					return fmt.Errorf("len should defined before sequence filed:%v in struct:%v", field.Name, st.Name)
-				}
-				if st.Fields[index-1].Name != "len" {
-					return fmt.Errorf("len should defined before sequence filed:%v in struct:%v", field.Name, st.Name)
-				}
-			}
+func (c *IDLConverter) loadModule() error {
+	schema := c.Schema
+	switch {
+	case c.SchemaPath != "":
+		v, err := os.ReadFile(c.SchemaPath)
+		if err != nil {
+			return err
 		}
+		schema = string(v)
+	case c.Schema != "":
+	case c.Module.Name != "" || len(c.Module.Content) > 0:
+		return nil
+	default:
+		return errors.New("no schema source: set SchemaPath, Schema or Module")
+	}
+	module, err := ast.ParseSchema(schema)
+	if err != nil {
+		return err
 	}
+	c.Module = module
 	return nil
 }
 
 func (c *IDLConverter) travelModule() error {
-	nodes := strings.Split(c.SchemaPath, ".")
-	for _, node := range nodes {
-		c.list.PushFront(node)
-	}
-	return c.travel(c.list.Front(), c.Module)
-}
-
-func (c *IDLConverter) travel(curr *list.Element, currModule ast.Module) error {
-	node := curr.Value.(string)
-	if curr.Next() == nil {
-		for _, con := range currModule.Content {
-			if con.GetName() == node {
-				st, ok := con.(struct_type.Struct)
-				if !ok {
-					return fmt.Errorf("travel node %v not struct", node)
-				}
-				c.tarStruct = st
-				return nil
-			}
-		}
+	if c.TypeName == "" {
+		return errors.New("target type name is required")
+	}
+	nodes := strings.Split(strings.TrimPrefix(c.TypeName, "::"), "::")
+	return c.travel(nodes, ast.NewGlobalScope(c.Module))
+}
+
+func (c *IDLConverter) travel(nodes []string, curr *ast.Scope) error {
+	node := nodes[0]
+	con, ok := curr.Find(node)
+	if !ok {
 		return fmt.Errorf("travel node %v not found", node)
 	}
-	for _, con := range currModule.Content {
-		if con.GetName() == node {
-			module, ok := con.(ast.Module)
-			if !ok {
-				return fmt.Errorf("travel node %v not module", node)
-			}
-			return c.travel(curr.Next(), module)
+	if len(nodes) == 1 {
+		st, ok := con.(struct_type.Struct)
+		if !ok {
+			return fmt.Errorf("travel node %v not struct", node)
 		}
+		c.tarStruct = st
+		c.tarScope = curr
+		return nil
+	}
+	module, ok := con.(ast.Module)
+	if !ok {
+		return fmt.Errorf("travel node %v not module", node)
 	}
-	return fmt.Errorf("travel node %v not found", node)
+	return c.travel(nodes[1:], curr.Child(module))
 }
 
-func (c *IDLConverter) verifyModule(module ast.Module) error {
-	moduleCount := 0
-	for _, con := range module.Content {
-		subModule, ok := con.(ast.Module)
-		if ok {
-			moduleCount++
-			err := c.verifyModule(subModule)
-			if err != nil {
-				return err
-			}
-			continue
-		}
-	}
-	if moduleCount > 0 && moduleCount < len(module.Content) {
-		return fmt.Errorf("module %v has both sub module and others", module.Name)
+// verifyStruct compiles every struct of the schema so that unsupported
+// fields and unresolved type names are reported up front.
+func verifyStruct(s *ast.Scope) error {
+	if errs := validate(s); len(errs) > 0 {
+		return errs[0]
 	}
 	return nil
 }
 
-func (c *IDLConverter) verifyStruct(module ast.Module) error {
-	for _, con := range module.Content {
-		subModule, ok := con.(ast.Module)
-		if ok {
-			err := c.verifyStruct(subModule)
-			if err != nil {
-				return err
-			}
-			continue
+// Validate checks every definition of module and returns one error per
+// problem found: definitions sharing a name within a module and structs
+// that cannot be compiled, e.g. because of an unresolved type name.
+func Validate(module ast.Module) []error {
+	return validate(ast.NewGlobalScope(module))
+}
+
+func validate(s *ast.Scope) []error {
+	var errs []error
+	modules := make(map[string]bool)
+	defined := make(map[string]bool)
+	for _, con := range s.Module().Content {
+		if _, ok := con.(ast.Module); ok {
+			modules[con.GetName()] = true
 		}
-		subSt, ok := con.(struct_type.Struct)
-		if ok {
-			if err := c.verifyStructField(subSt); err != nil {
-				return err
-			}
+	}
+	for _, con := range s.Module().Content {
+		switch def := con.(type) {
+		case ast.Module:
+			errs = append(errs, validate(s.Child(def))...)
 			continue
+		case forward_type.Forward:
+			continue
+		case struct_type.Struct:
+			if _, err := compilePlan(def, s); err != nil {
+				errs = append(errs, err)
+			}
 		}
+		name := s.Qualify(con.GetName())
+		if defined[con.GetName()] || modules[con.GetName()] {
+			errs = append(errs, fmt.Errorf("%v is defined more than once", name))
+		}
+		defined[con.GetName()] = true
 	}
-	return nil
+	return errs
 }
 
-func (c *IDLConverter) verifyStructField(st struct_type.Struct) error {
-	for _, field := range st.Fields {
-		if !isSupportedTyp(field.Type.TypeRefType()) {
-			return fmt.Errorf("st %v has unsupported field %v", st.Name, field.Name)
-		}
+func (c *IDLConverter) Decode(data []byte) (map[string]interface{}, error) {
+	m, unconsumed, err := c.DecodePartial(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkTrailing(unconsumed); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DecodePartial decodes the target struct from the start of data regardless
+// of Strict and also returns how many bytes were left unconsumed.
+func (c *IDLConverter) DecodePartial(data []byte) (map[string]interface{}, int, error) {
+	if c.plan == nil {
+		return nil, 0, errors.New("converter is not initialized")
+	}
+	return c.decodeStruct(data, c.NumberMode)
+}
+
+// decodeStruct decodes the target struct in the configured encoding and
+// returns the number of bytes left unconsumed.
+func (c *IDLConverter) decodeStruct(data []byte, numberMode NumberMode) (map[string]interface{}, int, error) {
+	d := decoder{numberMode: numberMode, depth: c.maxDepth()}
+	if c.Encoding == EncodingCDR {
+		return decodeCDR(c.plan, data, d)
+	}
+	m, remained, err := d.decodeStruct(c.plan, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return m, len(remained), nil
+}
+
+func (c *IDLConverter) maxDepth() int {
+	if c.MaxDepth > 0 {
+		return c.MaxDepth
+	}
+	return defaultMaxDepth
+}
+
+func (c *IDLConverter) checkPacked() error {
+	if c.Encoding != EncodingPacked {
+		return errors.New("not supported with CDR encoding")
 	}
 	return nil
 }
 
-var (
-	supportedFieldType = []typ.FieldRefType{
-		typ.OctetType,
-		typ.ShortType,
-		typ.UnsignedShortType,
-		typ.LongType,
-		typ.UnsignedLongType,
-		typ.LongLongType,
-		typ.UnsignedLongLongType,
-		typ.BooleanType,
-		typ.FloatType,
-		typ.SequenceType,
-		typ.StringType,
+func (c *IDLConverter) checkTrailing(unconsumed int) error {
+	if c.Strict && unconsumed > 0 {
+		return fmt.Errorf("%w: struct %v left %v bytes", ErrTrailingBytes, c.plan.name, unconsumed)
 	}
-)
+	return nil
+}
 
-func isSupportedTyp(tar typ.FieldRefType) bool {
-	for _, v := range supportedFieldType {
-		if v == tar {
-			return true
-		}
+type decoder struct {
+	numberMode NumberMode
+	// depth is the number of struct levels that may still be entered.
+	depth int
+}
+
+func parseDataByType(data []byte, t typeref.TypeRef) (interface{}, []byte, error) {
+	in, err := compileType(t)
+	if err != nil {
+		return nil, nil, err
 	}
-	return false
+	return decoder{depth: defaultMaxDepth}.decode(&in, data)
 }
 
-func (c *IDLConverter) Decode(data []byte) (map[string]interface{}, error) {
-	m := make(map[string]any, len(c.tarStruct.Fields))
+func (d decoder) decodeStruct(p *plan, data []byte) (map[string]interface{}, []byte, error) {
+	if d.depth <= 0 {
+		return nil, nil, p.depthError()
+	}
+	d.depth--
+	if err := p.checkPrefix(data); err != nil {
+		return nil, nil, err
+	}
+	m := make(map[string]any, len(p.instrs))
 	var v interface{}
 	var err error
-	var remained []byte
-	remained = data
-	for _, field := range c.tarStruct.Fields {
-		v, remained, err = parseDataByType(remained, field.Type)
+	remained := data[p.prefix:]
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		if in.inPrefix {
+			if m[in.name], err = d.fixed(in, data[in.offset:]); err != nil {
+				return nil, nil, p.fieldError(in, err)
+			}
+			continue
+		}
+		var count int64
+		if in.length.kind == lengthFrom {
+			count = countOf(m[p.instrs[in.length.from].name])
+		}
+		v, remained, err = d.decodeField(in, remained, count)
 		if err != nil {
-			return nil, fmt.Errorf("struct %v parse field %v error:%v", c.tarStruct.Name, field.Name, err.Error())
+			return nil, nil, p.fieldError(in, err)
 		}
-		m[field.Name] = v
+		m[in.name] = v
 	}
-	return m, nil
+	return m, remained, nil
 }
 
-func parseDataByType(data []byte, t typeref.TypeRef) (interface{}, []byte, error) {
-	switch t.TypeRefType() {
-	case typ.OctetType:
-		return parseBytesToInt64(data, 1)
-	case typ.ShortType:
-		return parseBytesToInt16(data)
-	case typ.UnsignedShortType:
-		return parseBytesToUint16(data)
-	case typ.LongType:
-		return parseBytesToInt32(data)
-	case typ.UnsignedLongType:
-		return parseBytesToUint32(data)
-	case typ.LongLongType:
-		return parseBytesToInt64(data, 8)
-	case typ.UnsignedLongLongType:
-		return parseBytesToUint64(data)
-	case typ.BooleanType:
-		return parseBytesToBoolean(data)
-	case typ.FloatType:
-		return parseBytesToFloat64(data, 4)
-	case typ.SequenceType:
-		seq := t.(typeref.Sequence)
-		return parseBytesToList(data, seq)
-	case typ.StringType:
-		return parseBytesToString(data)
-
-	}
-	return nil, nil, fmt.Errorf("unsupported type:%v", t.TypeName())
-}
-
-func parseBytesToString(data []byte) (value string, remained []byte, err error) {
-	if len(data) <= 4 {
-		return "", nil, fmt.Errorf("expect data len larger than %v got len %v", 4, len(data))
-	}
-	strLen, remained, err := parseBytesToInt64(data, 4)
-	if err != nil {
-		return "", nil, fmt.Errorf("parse sequence len error:%v", err.Error())
-	}
-	if int64(len(data)) < 4+strLen {
-		return "", nil, errors.New("data truncated, insufficient bytes for string")
+// countOf converts the decoded value of a length field to a count. Values
+// that do not fit an int64 become -1 and are rejected by readLength.
+func countOf(v interface{}) int64 {
+	switch n := v.(type) {
+	case int8:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case int64:
+		return n
+	case uint64:
+		if n > math.MaxInt64 {
+			return -1
+		}
+		return int64(n)
 	}
-	return string(remained[:strLen]), remained[strLen:], nil
+	return -1
 }
 
-func parseBytesToInt64(data []byte, expLen int) (int64, []byte, error) {
-	if len(data) < expLen {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", expLen, len(data))
-	}
-	parseData, remainData := data[:expLen], data[expLen:]
-	got, err := bytesToInt64(parseData)
-	return got, remainData, err
+func (d decoder) decode(in *instruction, data []byte) (interface{}, []byte, error) {
+	return d.decodeField(in, data, 0)
 }
 
-func parseBytesToInt16(data []byte) (int64, []byte, error) {
-	if len(data) < 2 {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", 2, len(data))
+// decodeField decodes one value. count is the element count of a string or
+// sequence whose length is taken from another field.
+func (d decoder) decodeField(in *instruction, data []byte, count int64) (interface{}, []byte, error) {
+	if in.size > 0 {
+		if len(data) < in.size {
+			return nil, nil, fmt.Errorf("expect data len %v got len %v", in.size, len(data))
+		}
+		v, err := d.fixed(in, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return v, data[in.size:], nil
+	}
+	if in.op == opStruct {
+		return d.decodeStruct(in.plan, data)
+	}
+	n, remained, err := in.readLength(data, count)
+	if err != nil {
+		return nil, nil, err
 	}
-	parseData, remainData := data[:2], data[2:]
-	value := int16(binary.BigEndian.Uint16(parseData))
-	return int64(value), remainData, nil
+	if err := in.checkBound(n); err != nil {
+		return nil, nil, err
+	}
+	switch in.op {
+	case opString:
+		if int64(len(remained)) < n {
+			return nil, nil, errors.New("data truncated, insufficient bytes for string")
+		}
+		return string(remained[:n]), remained[n:], nil
+	case opSequence:
+		return d.decodeList(in, n, remained)
+	}
+	return nil, nil, fmt.Errorf("unsupported op:%v", in.op)
 }
 
-func parseBytesToUint16(data []byte) (int64, []byte, error) {
-	if len(data) < 2 {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", 2, len(data))
+// readLength returns the element count of a string or sequence and the data
+// following its length prefix, if any. A count of -1 means the sequence
+// runs to the end of the data.
+func (in *instruction) readLength(data []byte, count int64) (int64, []byte, error) {
+	switch in.length.kind {
+	case lengthFixed:
+		return int64(in.length.count), data, nil
+	case lengthFrom:
+		if count < 0 {
+			return 0, nil, fmt.Errorf("length %v out of range", count)
+		}
+		return count, data, nil
+	case lengthToEnd:
+		if in.op == opString {
+			return int64(len(data)), data, nil
+		}
+		if size := in.elem.size; size > 0 {
+			if len(data)%size != 0 {
+				return 0, nil, fmt.Errorf("remaining data len %v is not a multiple of element size %v", len(data), size)
+			}
+			return int64(len(data) / size), data, nil
+		}
+		return -1, data, nil
+	}
+	size := in.length.prefix
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("expect data len larger than %v got len %v", size, len(data))
+	}
+	n := int64(readUint(data[:size]))
+	if n < 0 {
+		return 0, nil, fmt.Errorf("length %v out of range", uint64(n))
 	}
-	parseData, remainData := data[:2], data[2:]
-	value := binary.BigEndian.Uint16(parseData)
-	return int64(value), remainData, nil
+	return n, data[size:], nil
 }
 
-func parseBytesToInt32(data []byte) (int64, []byte, error) {
-	if len(data) < 4 {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", 4, len(data))
+func (d decoder) fixed(in *instruction, b []byte) (interface{}, error) {
+	native := d.numberMode == NumberModeNative
+	switch in.op {
+	case opInt8:
+		return widen(native, int8(b[0])), nil
+	case opOctet:
+		return widen(native, b[0]), nil
+	case opShort:
+		return widen(native, int16(binary.BigEndian.Uint16(b))), nil
+	case opUnsignedShort:
+		return widen(native, binary.BigEndian.Uint16(b)), nil
+	case opLong:
+		return widen(native, int32(binary.BigEndian.Uint32(b))), nil
+	case opUnsignedLong:
+		return widen(native, binary.BigEndian.Uint32(b)), nil
+	case opLongLong:
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case opUnsignedLongLong:
+		return binary.BigEndian.Uint64(b), nil
+	case opBoolean:
+		return b[0] != 0x00, nil
+	case opFloat:
+		v := math.Float32frombits(binary.BigEndian.Uint32(b))
+		if native {
+			return v, nil
+		}
+		return float64(v), nil
+	case opDouble:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case opEnum:
+		return in.enumName(binary.BigEndian.Uint32(b))
+	case opBitSet:
+		raw := readUint(b[:in.size])
+		m := make(map[string]interface{}, len(in.plan.instrs))
+		for i := range in.plan.instrs {
+			field := &in.plan.instrs[i]
+			m[field.name] = field.bitFieldValue(raw, native)
+		}
+		return m, nil
+	case opStruct:
+		m := make(map[string]interface{}, len(in.plan.instrs))
+		for i := range in.plan.instrs {
+			field := &in.plan.instrs[i]
+			v, err := d.fixed(field, b[field.offset:])
+			if err != nil {
+				return nil, in.plan.fieldError(field, err)
+			}
+			m[field.name] = v
+		}
+		return m, nil
 	}
-	parseData, remainData := data[:4], data[4:]
-	value := int32(binary.BigEndian.Uint32(parseData))
-	return int64(value), remainData, nil
+	return nil, fmt.Errorf("unsupported op:%v", in.op)
 }
 
-func parseBytesToUint32(data []byte) (int64, []byte, error) {
-	if len(data) < 4 {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", 4, len(data))
+func (in *instruction) enumName(v uint32) (string, error) {
+	if int64(v) >= int64(len(in.members)) {
+		return "", fmt.Errorf("enum value %v out of range, %v members", v, len(in.members))
 	}
-	parseData, remainData := data[:4], data[4:]
-	value := binary.BigEndian.Uint32(parseData)
-	return int64(value), remainData, nil
+	return in.members[v], nil
+}
+
+func (in *instruction) bitFieldBits(raw uint64) uint64 {
+	return raw >> in.shift & (1<<in.width - 1)
 }
 
-func parseBytesToUint64(data []byte) (int64, []byte, error) {
-	if len(data) < 8 {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", 8, len(data))
+func (in *instruction) bitFieldValue(raw uint64, native bool) interface{} {
+	v := in.bitFieldBits(raw)
+	if !native {
+		if in.width == 64 {
+			return v
+		}
+		return int64(v)
 	}
-	parseData, remainData := data[:8], data[8:]
-	value := binary.BigEndian.Uint64(parseData)
-	return int64(value), remainData, nil
+	switch bitSetSize(int(in.width)) {
+	case 1:
+		return uint8(v)
+	case 2:
+		return uint16(v)
+	case 4:
+		return uint32(v)
+	}
+	return v
 }
 
-func bytesToInt64(b []byte) (int64, error) {
+func readUint(b []byte) uint64 {
 	switch len(b) {
 	case 1:
-		return int64(b[0]), nil
+		return uint64(b[0])
 	case 2:
-		return int64(binary.BigEndian.Uint16(b)), nil
+		return uint64(binary.BigEndian.Uint16(b))
 	case 4:
-		return int64(binary.BigEndian.Uint32(b)), nil
-	case 8:
-		return int64(binary.BigEndian.Uint64(b)), nil
-	default:
-		return 0, fmt.Errorf("unexpect data len:%v", len(b))
+		return uint64(binary.BigEndian.Uint32(b))
 	}
+	return binary.BigEndian.Uint64(b)
 }
 
-func parseBytesToBoolean(data []byte) (bool, []byte, error) {
-	if len(data) < 1 {
-		return false, nil, fmt.Errorf("expect data len %v got len %v", 1, len(data))
-	}
-	return data[0] != 0x00, data[1:], nil
+type integer interface {
+	int8 | uint8 | int16 | uint16 | int32 | uint32
 }
 
-func parseBytesToFloat64(data []byte, expLen int) (float64, []byte, error) {
-	if len(data) < expLen {
-		return 0, nil, fmt.Errorf("expect data len %v got len %v", expLen, len(data))
+func widen[T integer](native bool, v T) interface{} {
+	if native {
+		return v
 	}
-	if expLen == 4 {
-		parseData := data[:4]
-		remainData := data[4:]
-		value := math.Float32frombits(binary.BigEndian.Uint32(parseData))
-		return float64(value), remainData, nil
-	} else if expLen == 8 {
-		parseData := data[:8]
-		remainData := data[8:]
-		value := math.Float64frombits(binary.BigEndian.Uint64(parseData))
-		return value, remainData, nil
-	}
-	return 0, nil, fmt.Errorf("expect data len 4/8 got len %v", len(data))
+	return int64(v)
 }
 
-func parseBytesToList(data []byte, seqType typeref.Sequence) ([]interface{}, []byte, error) {
-	if len(data) <= 4 {
-		return nil, nil, fmt.Errorf("expect data len larger than %v got len %v", 4, len(data))
-	}
-	sequenceLen, remained, err := parseBytesToInt64(data, 4)
-	if err != nil {
-		return nil, nil, fmt.Errorf("parse sequence len error:%v", err.Error())
+func (d decoder) decodeList(in *instruction, n int64, remained []byte) ([]interface{}, []byte, error) {
+	elem := in.elem
+	if n >= 0 {
+		if err := checkListLen(elem, n, remained); err != nil {
+			return nil, nil, err
+		}
 	}
-	result := make([]interface{}, 0, sequenceLen)
+	result := make([]interface{}, 0, max(n, 0))
 	var v interface{}
-	for i := 0; i < int(sequenceLen); i++ {
-		v, remained, err = parseDataByType(remained, seqType.InnerType)
+	var err error
+	for i := int64(0); i < n || n < 0 && len(remained) > 0; i++ {
+		if err := in.checkBound(i + 1); err != nil {
+			return nil, nil, err
+		}
+		v, remained, err = d.decode(elem, remained)
 		if err != nil {
-			return nil, nil, fmt.Errorf("parse sequence %v error:%v", seqType.InnerType, err.Error())
+			return nil, nil, fmt.Errorf("parse sequence %v error:%v", elem.op, err.Error())
 		}
 		result = append(result, v)
 	}
 	return result, remained, nil
 }
+
+// checkListLen rejects a sequence whose declared length cannot fit in the
+// remaining data, before anything is allocated for it. Elements that may be
+// empty are counted as one byte so that a corrupt length cannot make the
+// decoder allocate without bound.
+func checkListLen(elem *instruction, n int64, remained []byte) error {
+	size := int64(max(elem.minSize(), 1))
+	// Divide rather than multiply: n may be close to the largest int64.
+	if n > int64(len(remained))/size {
+		return fmt.Errorf("sequence of %v elements of at least %v bytes each got len %v", n, size, len(remained))
+	}
+	return nil
+}
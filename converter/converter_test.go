@@ -1,11 +1,15 @@
 package converter
 
 import (
+	"bytes"
 	"encoding/binary"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/yisaer/idl-parser/ast"
 	"github.com/yisaer/idl-parser/ast/typ"
 	"github.com/yisaer/idl-parser/ast/typeref"
 )
@@ -400,7 +404,7 @@ func TestParseDataByType_UnsignedLongLong(t *testing.T) {
 	tests := []struct {
 		name           string
 		data           []byte
-		expected       int64
+		expected       uint64
 		expectedRemain []byte
 		expectError    bool
 	}{
@@ -411,6 +415,13 @@ func TestParseDataByType_UnsignedLongLong(t *testing.T) {
 			expectedRemain: []byte{1, 2, 3},
 			expectError:    false,
 		},
+		{
+			name:           "parse unsigned long long value 18446744073709551615 successfully",
+			data:           []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 1, 2, 3},
+			expected:       18446744073709551615,
+			expectedRemain: []byte{1, 2, 3},
+			expectError:    false,
+		},
 		{
 			name:           "parse unsigned long long value 0 successfully",
 			data:           []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 10, 20},
@@ -800,3 +811,581 @@ func TestParseDataByType_SequenceOfString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDataByType_NativeNumberMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		typ      typeref.TypeRef
+		expected interface{}
+	}{
+		{"octet as uint8", []byte{0xFF}, typeref.NewOctetType(), uint8(255)},
+		{"short as int16", []byte{0xCF, 0xC7}, typeref.NewShortType(), int16(-12345)},
+		{"unsigned short as uint16", []byte{0xFF, 0xFF}, typeref.NewUnsignedShortType(), uint16(65535)},
+		{"long as int32", []byte{0xB6, 0x69, 0xFD, 0x2E}, typeref.NewLongType(), int32(-1234567890)},
+		{"unsigned long as uint32", []byte{0xFF, 0xFF, 0xFF, 0xFF}, typeref.NewUnsignedLong(), uint32(4294967295)},
+		{"long long as int64", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, typeref.NewLongLongType(), int64(-1)},
+		{"unsigned long long as uint64", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, typeref.NewUnsignedLongLong(), uint64(18446744073709551615)},
+		{"float as float32", []byte{0xC0, 0x20, 0x00, 0x00}, typeref.NewFloatType(), float32(-2.5)},
+		{"sequence of short", []byte{0, 0, 0, 1, 0x12, 0x34}, typeref.NewSequence(typeref.NewShortType()), []interface{}{int16(0x1234)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := compileType(tt.typ)
+			require.NoError(t, err)
+			d := decoder{numberMode: NumberModeNative}
+			result, remain, err := d.decode(&in, tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+			require.Empty(t, remain)
+		})
+	}
+}
+
+const testSchema = `module spi {
+	module can {
+		struct Frame {
+			octet header;
+			string source;
+		};
+	};
+}`
+
+func TestNewIDLConverter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spi.idl")
+	require.NoError(t, os.WriteFile(path, []byte(testSchema), 0o644))
+	res := ast.Parse(testSchema)
+	require.Nil(t, res.Err)
+
+	fromFile, err := NewIDLConverterFromFile(path, "spi::can::Frame")
+	require.NoError(t, err)
+	fromString, err := NewIDLConverterFromString(testSchema, "::spi::can::Frame")
+	require.NoError(t, err)
+	fromModule, err := NewIDLConverterFromModule(res.Output, "spi::can::Frame")
+	require.NoError(t, err)
+
+	data := []byte{7, 0, 0, 0, 2, 'c', '0'}
+	for _, c := range []*IDLConverter{fromFile, fromString, fromModule} {
+		m, err := c.Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"header": int64(7), "source": "c0"}, m)
+	}
+}
+
+func TestNewIDLConverterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeName string
+		errMsg   string
+	}{
+		{"missing type name", "", "target type name is required"},
+		{"wrong root module", "can::Frame", "travel node can not found"},
+		{"missing struct", "spi::can::Missing", "travel node Missing not found"},
+		{"root module", "spi", "travel node spi not struct"},
+		{"module instead of struct", "spi::can", "travel node can not struct"},
+		{"struct used as module", "spi::can::Frame::x", "travel node Frame not module"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIDLConverterFromString(testSchema, tt.typeName)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	err := (&IDLConverter{TypeName: "spi::can::Frame"}).Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no schema source")
+}
+
+func TestValidate(t *testing.T) {
+	res := ast.Parse(`module m {
+		struct A { Missing x; };
+		struct B { octet a; };
+		enum B { X };
+		module inner {
+			struct C { B b; Other o; };
+		};
+		module inner {
+			struct D { octet d; };
+		};
+		struct inner { octet i; };
+	}`)
+	require.Nil(t, res.Err)
+	errs := Validate(res.Output)
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	require.Len(t, msgs, 4)
+	require.Contains(t, msgs[0], "type Missing not found")
+	require.Contains(t, msgs[1], "m::B is defined more than once")
+	require.Contains(t, msgs[2], "type Other not found")
+	require.Contains(t, msgs[3], "m::inner is defined more than once")
+
+	res = ast.Parse(testSchema)
+	require.Nil(t, res.Err)
+	require.Empty(t, Validate(res.Output))
+}
+
+func TestReopenedModule(t *testing.T) {
+	c := &IDLConverter{Schema: `module spi {
+		module can { struct Id { octet bid; }; };
+		module can { struct Frame { Id id; short v; }; };
+	}`, TypeName: "spi::can::Frame"}
+	require.NoError(t, c.Init())
+	m, err := c.Decode([]byte{0x01, 0x00, 0x02})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": map[string]interface{}{"bid": int64(1)}, "v": int64(2)}, m)
+
+	c = &IDLConverter{Schema: `module spi { struct Id { octet bid; }; };
+	module spi { struct Frame { Id id; short v; }; };`, TypeName: "spi::Frame"}
+	require.NoError(t, c.Init())
+	m, err = c.Decode([]byte{0x01, 0x00, 0x02})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": map[string]interface{}{"bid": int64(1)}, "v": int64(2)}, m)
+	c = &IDLConverter{Schema: `module spi { struct Id { octet bid; }; }; trailing`, TypeName: "spi::Id"}
+	require.ErrorContains(t, c.Init(), "unexpected content after module spi")
+
+	res := ast.Parse(`module m {
+		module n { struct A { octet a; }; };
+		module n { struct A { octet b; }; };
+	}`)
+	require.Nil(t, res.Err)
+	errs := Validate(res.Output)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "m::n::A is defined more than once")
+}
+
+const recursiveSchema = `module tree {
+	struct Node;
+	struct Node {
+		long value;
+		sequence<Node> children;
+	};
+}`
+
+func TestRecursiveType(t *testing.T) {
+	data := []byte{
+		0, 0, 0, 1, 0, 0, 0, 2,
+		0, 0, 0, 2, 0, 0, 0, 0,
+		0, 0, 0, 3, 0, 0, 0, 1,
+		0, 0, 0, 4, 0, 0, 0, 0,
+	}
+	value := map[string]interface{}{"value": int64(1), "children": []interface{}{
+		map[string]interface{}{"value": int64(2), "children": []interface{}{}},
+		map[string]interface{}{"value": int64(3), "children": []interface{}{
+			map[string]interface{}{"value": int64(4), "children": []interface{}{}},
+		}},
+	}}
+	c, err := NewIDLConverterFromString(recursiveSchema, "tree::Node")
+	require.NoError(t, err)
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, value, m)
+	got, err := c.Encode(value)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	var rec Record
+	require.NoError(t, c.DecodeRecord(data, &rec))
+	children, ok := rec.Lookup("children")
+	require.True(t, ok)
+	require.Equal(t, 2, children.Len())
+	leaf, ok := children.Index(1).Lookup("children")
+	require.True(t, ok)
+	v, ok := leaf.Index(0).Lookup("value")
+	require.True(t, ok)
+	require.Equal(t, int64(4), v.Int())
+
+	type node struct {
+		Value    int32
+		Children []node
+	}
+	var n node
+	require.NoError(t, c.DecodeInto(data, &n))
+	require.Equal(t, node{Value: 1, Children: []node{{Value: 2, Children: []node{}}, {Value: 3, Children: []node{{Value: 4, Children: []node{}}}}}}, n)
+
+	d := NewDecoder(bytes.NewReader(append(data, data...)), c)
+	for i := 0; i < 2; i++ {
+		m, size, err := d.Next()
+		require.NoError(t, err)
+		require.Equal(t, len(data), size)
+		require.Equal(t, value, m)
+	}
+
+	_, err = c.DecodeBatch([][]byte{data})
+	require.EqualError(t, err, "recursive struct Node is not supported")
+
+	cdr := newCDRConverter(t, recursiveSchema, "tree::Node")
+	b, err := cdr.Encode(value)
+	require.NoError(t, err)
+	m, err = cdr.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, value, m)
+}
+
+func TestRecursiveTypeMaxDepth(t *testing.T) {
+	c := &IDLConverter{Schema: recursiveSchema, TypeName: "tree::Node", MaxDepth: 2}
+	require.NoError(t, c.Init())
+	shallow := []byte{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 0}
+	_, err := c.Decode(shallow)
+	require.NoError(t, err)
+
+	deep := []byte{
+		0, 0, 0, 1, 0, 0, 0, 1,
+		0, 0, 0, 2, 0, 0, 0, 1,
+		0, 0, 0, 3, 0, 0, 0, 0,
+	}
+	_, err = c.Decode(deep)
+	require.ErrorContains(t, err, "struct Node exceeds the maximum nesting depth")
+	var rec Record
+	require.ErrorContains(t, c.DecodeRecord(deep, &rec), "struct Node exceeds the maximum nesting depth")
+	_, _, err = NewDecoder(bytes.NewReader(deep), c).Next()
+	require.ErrorContains(t, err, "struct Node exceeds the maximum nesting depth")
+	value := map[string]interface{}{"value": 1, "children": []interface{}{
+		map[string]interface{}{"value": 2, "children": []interface{}{
+			map[string]interface{}{"value": 3, "children": []interface{}{}},
+		}},
+	}}
+	_, err = c.Encode(value)
+	require.ErrorContains(t, err, "struct Node exceeds the maximum nesting depth")
+}
+
+func TestRecursiveTypeErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		errMsg string
+	}{
+		{`module m { struct A { long v; A next; }; }`, "struct m::A contains itself"},
+		{`module m { struct B; struct A { B b; }; }`, "struct B is declared but not defined"},
+	}
+	for _, tt := range tests {
+		_, err := NewIDLConverterFromString(tt.schema, "m::A")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tt.errMsg)
+	}
+}
+
+func TestInheritance(t *testing.T) {
+	schema := `module m {
+	module base {
+		typedef short Code;
+		struct Header {
+			octet kind;
+			Code code;
+		};
+	};
+	struct Stamped : base::Header {
+		unsigned long stamp;
+	};
+	struct Reading : Stamped {
+		octet n;
+		@length_from(kind) sequence<octet> data;
+	};
+}`
+	c, err := NewIDLConverterFromString(schema, "m::Reading")
+	require.NoError(t, err)
+	data := []byte{2, 0, 7, 0, 0, 1, 0, 9, 0xA, 0xB}
+	value := map[string]interface{}{
+		"kind":  int64(2),
+		"code":  int64(7),
+		"stamp": int64(256),
+		"n":     int64(9),
+		"data":  []interface{}{int64(10), int64(11)},
+	}
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, value, m)
+	got, err := c.Encode(value)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	var rec Record
+	require.NoError(t, c.DecodeRecord(data, &rec))
+	require.Equal(t, []string{"kind", "code", "stamp", "n", "data"}, []string{rec.Name(0), rec.Name(1), rec.Name(2), rec.Name(3), rec.Name(4)})
+
+	cdr := newCDRConverter(t, schema, "m::Stamped")
+	m, err = cdr.Decode([]byte{0, 1, 0, 0, 2, 0, 7, 0, 0, 1, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"kind": int64(2), "code": int64(7), "stamp": int64(256)}, m)
+}
+
+func TestInheritanceErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		errMsg string
+	}{
+		{`module m { struct A : B { long a; }; struct B : A { long b; }; }`, "struct m::A inherits from itself"},
+		{`module m { struct A : Missing { long a; }; }`, "base of struct m::A: type Missing not found"},
+		{`module m { enum E { X }; struct A : E { long a; }; }`, "base E of struct m::A is not a struct"},
+		{`module m { struct B { long a; }; struct A : B { short a; }; }`, "st A field a is declared more than once"},
+	}
+	for _, tt := range tests {
+		_, err := NewIDLConverterFromString(tt.schema, "m::A")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tt.errMsg)
+	}
+}
+
+func TestDecodeTrailingBytes(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	data := append(append([]byte{}, benchData...), 0xAA, 0xBB)
+
+	m, unconsumed, err := c.DecodePartial(data)
+	require.NoError(t, err)
+	require.Equal(t, 2, unconsumed)
+	require.Equal(t, "can0", m["source"])
+
+	_, err = c.Decode(data)
+	require.NoError(t, err)
+
+	c.Strict = true
+	_, err = c.Decode(data)
+	require.ErrorIs(t, err, ErrTrailingBytes)
+	require.ErrorIs(t, c.DecodeRecord(data, &Record{}), ErrTrailingBytes)
+	require.ErrorIs(t, c.DecodeInto(data, &struct{ Header uint8 }{}), ErrTrailingBytes)
+
+	_, err = c.Decode(benchData)
+	require.NoError(t, err)
+	_, unconsumed, err = c.DecodePartial(data)
+	require.NoError(t, err)
+	require.Equal(t, 2, unconsumed)
+}
+
+const lengthSchema = `module m {
+	struct Inner {
+		@length_prefix(1) string tag;
+	};
+	struct Frame {
+		unsigned short count;
+		@length_prefix(2) string name;
+		@length_from(count) sequence<unsigned short> values;
+		@length_prefix(1) sequence<Inner> inners;
+		@length_to_end sequence<octet> rest;
+	};
+}`
+
+var lengthData = []byte{
+	0x00, 0x02,
+	0x00, 0x03, 'a', 'b', 'c',
+	0x00, 0x01, 0x00, 0x02,
+	0x02, 0x01, 'x', 0x00,
+	0x07, 0x08, 0x09,
+}
+
+func TestLengthEncodings(t *testing.T) {
+	c, err := NewIDLConverterFromString(lengthSchema, "m::Frame")
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"count":  int64(2),
+		"name":   "abc",
+		"values": []interface{}{int64(1), int64(2)},
+		"inners": []interface{}{
+			map[string]interface{}{"tag": "x"},
+			map[string]interface{}{"tag": ""},
+		},
+		"rest": []interface{}{int64(7), int64(8), int64(9)},
+	}
+	m, err := c.Decode(lengthData)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+
+	var r Record
+	require.NoError(t, c.DecodeRecord(lengthData, &r))
+	values, _ := r.Lookup("values")
+	require.Equal(t, 2, values.Len())
+	require.Equal(t, uint64(2), values.Index(1).Uint())
+	rest, _ := r.Lookup("rest")
+	require.Equal(t, 3, rest.Len())
+
+	var got struct {
+		Name   string
+		Values []uint16
+		Rest   []byte
+	}
+	require.NoError(t, c.DecodeInto(lengthData, &got))
+	require.Equal(t, []uint16{1, 2}, got.Values)
+	require.Equal(t, []byte{7, 8, 9}, got.Rest)
+
+	m, err = c.Decode(lengthData[:15])
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{}, m["rest"])
+	_, err = c.Decode(lengthData[:9])
+	require.Error(t, err)
+}
+
+func TestDefaultLengthPrefix(t *testing.T) {
+	c := &IDLConverter{Schema: lengthSchema, TypeName: "m::Inner", LengthPrefix: 2}
+	require.NoError(t, c.Init())
+	m, err := c.Decode([]byte{0x01, 'y'})
+	require.NoError(t, err)
+	require.Equal(t, "y", m["tag"])
+
+	c = &IDLConverter{Schema: `module m { struct S { string a; sequence<string> b; }; }`, TypeName: "m::S", LengthPrefix: 1}
+	require.NoError(t, c.Init())
+	m, err = c.Decode([]byte{0x01, 'a', 0x02, 0x01, 'b', 0x00})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": "a", "b": []interface{}{"b", ""}}, m)
+
+	c.LengthPrefix = 3
+	require.Error(t, c.Init())
+}
+
+func TestLengthAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		errMsg string
+	}{
+		{"not a sequence", "@length_prefix(2) long a;", "applies only to strings and sequences"},
+		{"bad prefix size", "@length_prefix(3) string a;", "expects 1, 2, 4 or 8"},
+		{"unknown field", "@length_from(n) string a;", "is not an earlier field"},
+		{"later field", "@length_from(n) string a; long n;", "is not an earlier field"},
+		{"not an integer", "string n; @length_from(n) string a;", "expect an integer"},
+		{"conflicting", "long n; @length_from(n) @length_to_end string a;", "conflicting length annotations"},
+		{"to end not last", "@length_to_end string a; long n;", "must be the last field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIDLConverterFromString("module m { struct S { "+tt.fields+" }; }", "m::S")
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	_, err := NewIDLConverterFromString(`module m {
+		struct Open { @length_to_end string a; };
+		struct S { sequence<Open> items; };
+	}`, "m::S")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "runs to the end of the data")
+}
+
+func TestHugeLength(t *testing.T) {
+	huge := []byte{0x40, 0, 0, 0, 0, 0, 0, 0}
+	tests := []struct {
+		name string
+		c    *IDLConverter
+		data []byte
+	}{
+		{
+			name: "8-byte prefix",
+			c:    &IDLConverter{Schema: `module m { struct S { sequence<long> a; }; }`, TypeName: "m::S", LengthPrefix: 8},
+			data: huge,
+		},
+		{
+			name: "length from an unsigned long long",
+			c:    &IDLConverter{Schema: `module m { struct S { unsigned long long n; @length_from(n) sequence<long> a; }; }`, TypeName: "m::S"},
+			data: huge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.c.Init())
+			_, err := tt.c.Decode(tt.data)
+			require.ErrorContains(t, err, "sequence of 4611686018427387904 elements")
+			require.ErrorContains(t, tt.c.DecodeRecord(tt.data, &Record{}), "sequence of 4611686018427387904 elements")
+		})
+	}
+}
+
+func TestBoundedSequence(t *testing.T) {
+	c, err := NewIDLConverterFromString(`module m {
+		struct S {
+			sequence<octet, 2> a;
+			@length_to_end sequence<string, 1> b;
+		};
+	}`, "m::S")
+	require.NoError(t, err)
+	data := []byte{0, 0, 0, 2, 1, 2, 0, 0, 0, 1, 'x'}
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"x"}, m["b"])
+	var r Record
+	require.NoError(t, c.DecodeRecord(data, &r))
+
+	for _, bad := range [][]byte{
+		{0, 0, 0, 3, 1, 2, 3},
+		append(append([]byte{}, data...), 0, 0, 0, 0),
+	} {
+		_, err = c.Decode(bad)
+		require.ErrorContains(t, err, "exceeds bound")
+		require.ErrorContains(t, c.DecodeRecord(bad, &r), "exceeds bound")
+	}
+	_, err = c.Encode(map[string]interface{}{"a": []byte{1, 2, 3}, "b": []string{}})
+	require.ErrorContains(t, err, "exceeds bound")
+}
+
+func TestTypedefArrays(t *testing.T) {
+	c, err := NewIDLConverterFromString(`module m {
+		typedef long long__2[2];
+		typedef long__2 grid;
+		struct S {
+			int8 a;
+			long__2 b;
+			double c;
+			sequence<grid> d;
+			string<2> s;
+		};
+	}`, "m::S")
+	require.NoError(t, err)
+	data := []byte{
+		0xFF,
+		0, 0, 0, 1, 0xFF, 0xFF, 0xFF, 0xFE,
+		0x3F, 0xF8, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 1, 0, 0, 0, 3, 0, 0, 0, 4,
+		0, 0, 0, 2, 'o', 'k',
+	}
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"a": int64(-1),
+		"b": []interface{}{int64(1), int64(-2)},
+		"c": 1.5,
+		"d": []interface{}{[]interface{}{int64(3), int64(4)}},
+		"s": "ok",
+	}, m)
+	got, err := c.Encode(m)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	var r Record
+	require.NoError(t, c.DecodeRecord(data, &r))
+	b, _ := r.Lookup("b")
+	require.Equal(t, 2, b.Len())
+	cv, _ := r.Lookup("c")
+	require.Equal(t, 1.5, cv.Float())
+	n, need := c.plan.frameLen(data, defaultMaxDepth)
+	require.Zero(t, need)
+	require.Equal(t, len(data), n)
+
+	var v struct {
+		A int8
+		B [2]int32
+		C float64
+	}
+	require.NoError(t, c.DecodeInto(data, &v))
+	require.Equal(t, [2]int32{1, -2}, v.B)
+	var wrong struct{ B [3]int32 }
+	require.ErrorContains(t, c.DecodeInto(data, &wrong), "cannot be decoded into")
+
+	m["b"] = []interface{}{1}
+	_, err = c.Encode(m)
+	require.EqualError(t, err, "struct S encode field b error:array of 1 elements, expect 2")
+	m["b"], m["s"] = []interface{}{1, 2}, "long"
+	_, err = c.Encode(m)
+	require.EqualError(t, err, "struct S encode field s error:string of 4 bytes exceeds bound 2")
+	_, err = c.Decode(append(data[:len(data)-6:len(data)-6], 0, 0, 0, 3, 'b', 'a', 'd'))
+	require.EqualError(t, err, "struct S parse field s error:string of 3 bytes exceeds bound 2")
+
+	for schema, msg := range map[string]string{
+		`module m { typedef octet a__2[2]; struct S { @length_prefix(1) a__2 a; }; }`: "@length_prefix applies only to strings and sequences",
+		`module m { const long N = 2; struct S { N a; }; }`:                           "N is not a type",
+		`module m { typedef Missing a__2[2]; struct S { a__2 a; }; }`:                 "typedef a__2: type Missing not found",
+	} {
+		_, err := NewIDLConverterFromString(schema, "m::S")
+		require.ErrorContains(t, err, msg, schema)
+	}
+}
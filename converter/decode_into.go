@@ -0,0 +1,254 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type fieldBinding struct {
+	index []int
+}
+
+type bindingKey struct {
+	plan *plan
+	typ  reflect.Type
+}
+
+// DecodeInto decodes data into the struct pointed to by v. IDL fields are
+// matched to Go fields by the `idl:"name"` tag or, failing that, by a
+// case-insensitive name match; IDL fields without a Go counterpart are
+// decoded and discarded. Nested structs and bitsets decode into nested Go
+// structs and enums into strings. Type compatibility is checked once per Go
+// type.
+func (c *IDLConverter) DecodeInto(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("decode target must be a non-nil pointer to struct")
+	}
+	if c.plan == nil {
+		return errors.New("converter is not initialized")
+	}
+	target := rv.Elem()
+	bindings, err := c.bindingsFor(c.plan, target.Type())
+	if err != nil {
+		return err
+	}
+	m, unconsumed, err := c.decodeStruct(data, NumberModeNative)
+	if err != nil {
+		return err
+	}
+	for i := range c.plan.instrs {
+		if bindings[i].index == nil {
+			continue
+		}
+		in := &c.plan.instrs[i]
+		v, ok := m[in.name]
+		if !ok {
+			// A member an appendable or mutable struct was sent without.
+			continue
+		}
+		if err := c.assign(target.FieldByIndex(bindings[i].index), in, v); err != nil {
+			return fmt.Errorf("struct %v assign field %v error:%v", c.plan.name, in.name, err.Error())
+		}
+	}
+	return c.checkTrailing(unconsumed)
+}
+
+func (c *IDLConverter) bindingsFor(p *plan, rt reflect.Type) ([]fieldBinding, error) {
+	return c.bind(p, rt, make(map[bindingKey]bool))
+}
+
+// bind matches the fields of p to those of rt. visiting holds the pairs
+// being bound by the callers; a recursive type reaches them again and they
+// are taken as compatible, the outermost call deciding.
+func (c *IDLConverter) bind(p *plan, rt reflect.Type, visiting map[bindingKey]bool) ([]fieldBinding, error) {
+	key := bindingKey{plan: p, typ: rt}
+	if cached, ok := c.bindings.Load(key); ok {
+		return cached.([]fieldBinding), nil
+	}
+	if visiting[key] {
+		return nil, nil
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+	bindings := make([]fieldBinding, len(p.instrs))
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		sf, ok := lookupGoField(rt, in.name)
+		if !ok {
+			continue
+		}
+		if !c.isCompatible(in, sf.Type, visiting) {
+			return nil, fmt.Errorf("field %v of type %v cannot be decoded into %v.%v of type %v",
+				in.name, in.op, rt.Name(), sf.Name, sf.Type)
+		}
+		bindings[i].index = sf.Index
+	}
+	c.bindings.Store(key, bindings)
+	return bindings, nil
+}
+
+func lookupGoField(rt reflect.Type, name string) (reflect.StructField, bool) {
+	var byName reflect.StructField
+	found := false
+	for _, sf := range reflect.VisibleFields(rt) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get("idl")
+		if tag == "-" {
+			continue
+		}
+		if tag == name {
+			return sf, true
+		}
+		if tag == "" && !found && strings.EqualFold(sf.Name, name) {
+			byName, found = sf, true
+		}
+	}
+	return byName, found
+}
+
+func (c *IDLConverter) isCompatible(in *instruction, rt reflect.Type, visiting map[bindingKey]bool) bool {
+	if rt.Kind() == reflect.Interface {
+		return rt.NumMethod() == 0
+	}
+	switch in.op {
+	case opInt8:
+		return fitsInteger(rt, 8, true)
+	case opOctet:
+		return fitsInteger(rt, 8, false)
+	case opShort:
+		return fitsInteger(rt, 16, true)
+	case opUnsignedShort:
+		return fitsInteger(rt, 16, false)
+	case opLong:
+		return fitsInteger(rt, 32, true)
+	case opUnsignedLong:
+		return fitsInteger(rt, 32, false)
+	case opLongLong:
+		return fitsInteger(rt, 64, true)
+	case opUnsignedLongLong:
+		return fitsInteger(rt, 64, false)
+	case opBitField:
+		return fitsInteger(rt, int(in.width), false)
+	case opBoolean:
+		return rt.Kind() == reflect.Bool
+	case opFloat:
+		return rt.Kind() == reflect.Float32 || rt.Kind() == reflect.Float64
+	case opDouble:
+		return rt.Kind() == reflect.Float64
+	case opString, opEnum:
+		return rt.Kind() == reflect.String
+	case opSequence:
+		if rt.Kind() == reflect.Array {
+			return in.length.kind == lengthFixed && rt.Len() == in.length.count && c.isCompatible(in.elem, rt.Elem(), visiting)
+		}
+		return rt.Kind() == reflect.Slice && c.isCompatible(in.elem, rt.Elem(), visiting)
+	case opStruct, opBitSet:
+		if rt.Kind() != reflect.Struct {
+			return false
+		}
+		_, err := c.bind(in.plan, rt, visiting)
+		return err == nil
+	}
+	return false
+}
+
+func fitsInteger(rt reflect.Type, bits int, signed bool) bool {
+	switch rt.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if signed {
+			return rt.Bits() >= bits
+		}
+		return rt.Bits() > bits
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return !signed && rt.Bits() >= bits
+	}
+	return false
+}
+
+func (c *IDLConverter) assign(dst reflect.Value, in *instruction, value interface{}) error {
+	if dst.Kind() == reflect.Interface {
+		dst.Set(reflect.ValueOf(value))
+		return nil
+	}
+	switch in.op {
+	case opStruct, opBitSet:
+		m := value.(map[string]interface{})
+		bindings, err := c.bindingsFor(in.plan, dst.Type())
+		if err != nil {
+			return err
+		}
+		for i := range in.plan.instrs {
+			if bindings[i].index == nil {
+				continue
+			}
+			field := &in.plan.instrs[i]
+			v, ok := m[field.name]
+			if !ok {
+				continue
+			}
+			if err := c.assign(dst.FieldByIndex(bindings[i].index), field, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case opSequence:
+		elems := value.([]interface{})
+		list := dst
+		if dst.Kind() == reflect.Slice {
+			list = reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+		}
+		for i, elem := range elems {
+			if err := c.assign(list.Index(i), in.elem, elem); err != nil {
+				return err
+			}
+		}
+		dst.Set(list)
+		return nil
+	}
+	return assignValue(dst, value)
+}
+
+func assignValue(dst reflect.Value, value interface{}) error {
+	switch v := value.(type) {
+	case int8:
+		setInteger(dst, int64(v), uint64(v))
+	case uint8:
+		setInteger(dst, int64(v), uint64(v))
+	case int16:
+		setInteger(dst, int64(v), uint64(v))
+	case uint16:
+		setInteger(dst, int64(v), uint64(v))
+	case int32:
+		setInteger(dst, int64(v), uint64(v))
+	case uint32:
+		setInteger(dst, int64(v), uint64(v))
+	case int64:
+		setInteger(dst, v, uint64(v))
+	case uint64:
+		setInteger(dst, int64(v), v)
+	case float32:
+		dst.SetFloat(float64(v))
+	case float64:
+		dst.SetFloat(v)
+	case bool:
+		dst.SetBool(v)
+	case string:
+		dst.SetString(v)
+	default:
+		return fmt.Errorf("unsupported value %T", value)
+	}
+	return nil
+}
+
+func setInteger(dst reflect.Value, signed int64, unsigned uint64) {
+	if dst.CanInt() {
+		dst.SetInt(signed)
+		return
+	}
+	dst.SetUint(unsigned)
+}
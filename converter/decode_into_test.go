@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func newTestConverter(t *testing.T, st struct_type.Struct) *IDLConverter {
+	p, err := compilePlan(st, &ast.Scope{})
+	require.NoError(t, err)
+	return &IDLConverter{tarStruct: st, plan: p}
+}
+
+func TestDecodeInto(t *testing.T) {
+	c := newTestConverter(t, struct_type.Struct{
+		Name: "Frame",
+		Fields: []struct_type.Field{
+			{Name: "header", Type: typeref.NewOctetType()},
+			{Name: "counter", Type: typeref.NewShortType()},
+			{Name: "timestamp", Type: typeref.NewUnsignedLongLong()},
+			{Name: "valid", Type: typeref.NewBooleanType()},
+			{Name: "ratio", Type: typeref.NewFloatType()},
+			{Name: "name", Type: typeref.NewStringType()},
+			{Name: "payload", Type: typeref.NewSequence(typeref.NewOctetType())},
+			{Name: "ignored", Type: typeref.NewLongType()},
+		},
+	})
+	type frame struct {
+		Header    uint8
+		Count     int32 `idl:"counter"`
+		Timestamp uint64
+		Valid     bool
+		Ratio     float64
+		Name      string
+		Payload   []byte
+	}
+	data := []byte{
+		0x2A,
+		0xCF, 0xC7,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x01,
+		0xC0, 0x20, 0x00, 0x00,
+		0, 0, 0, 2, 'o', 'k',
+		0, 0, 0, 3, 1, 2, 3,
+		0, 0, 0, 7,
+	}
+	var got frame
+	require.NoError(t, c.DecodeInto(data, &got))
+	require.Equal(t, frame{
+		Header:    42,
+		Count:     -12345,
+		Timestamp: 18446744073709551615,
+		Valid:     true,
+		Ratio:     -2.5,
+		Name:      "ok",
+		Payload:   []byte{1, 2, 3},
+	}, got)
+
+	_, ok := c.bindings.Load(bindingKey{plan: c.plan, typ: reflect.TypeOf(got)})
+	require.True(t, ok)
+}
+
+func TestDecodeIntoIncompatible(t *testing.T) {
+	c := newTestConverter(t, struct_type.Struct{
+		Name: "Frame",
+		Fields: []struct_type.Field{
+			{Name: "value", Type: typeref.NewUnsignedLong()},
+		},
+	})
+	tests := []struct {
+		name   string
+		target any
+	}{
+		{"narrower integer", &struct{ Value uint16 }{}},
+		{"signed of same width", &struct{ Value int32 }{}},
+		{"string", &struct{ Value string }{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.DecodeInto([]byte{0, 0, 0, 1}, tt.target)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "cannot be decoded into")
+		})
+	}
+
+	var wide struct{ Value int64 }
+	require.NoError(t, c.DecodeInto([]byte{0, 0, 0, 1}, &wide))
+	require.Equal(t, int64(1), wide.Value)
+
+	require.Error(t, c.DecodeInto([]byte{0, 0, 0, 1}, wide))
+}
@@ -0,0 +1,479 @@
+package converter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+)
+
+// Encode encodes v, keyed by field name like the output of Decode, into the
+// wire format of the target struct. Integers may be given as any Go integer
+// type, as an integral float64 or as a json.Number; enums as enumerator
+// names, bitsets and nested structs as maps and sequences as slices. A field
+// holding the length of another field may be omitted and is then filled in.
+// With EncodingCDR the payload is little-endian CDR padded to a multiple of
+// 4 bytes, the padding being counted in the encapsulation options as ROS 2
+// does. It is XCDR2 when the struct contains an appendable or mutable
+// struct; members of a mutable struct missing from v are then left out.
+func (c *IDLConverter) Encode(v map[string]interface{}) ([]byte, error) {
+	if c.plan == nil {
+		return nil, errors.New("converter is not initialized")
+	}
+	if c.Encoding == EncodingCDR {
+		return encodeCDR(c.plan, v, c.maxDepth())
+	}
+	return encoder{depth: c.maxDepth()}.encodeStruct(nil, c.plan, v)
+}
+
+// encoder writes values in the packed format or, with cdr set, as CDR
+// following a CDR encapsulation header, little-endian unless bigEndian is
+// set.
+type encoder struct {
+	cdr       bool
+	bigEndian bool
+	// version2 selects XCDR2 over plain CDR.
+	version2 bool
+	// depth is the number of struct levels that may still be entered.
+	depth int
+}
+
+func (e encoder) encodeStruct(b []byte, p *plan, m map[string]interface{}) ([]byte, error) {
+	if e.depth <= 0 {
+		return nil, p.depthError()
+	}
+	e.depth--
+	for name := range m {
+		if _, ok := p.index[name]; !ok {
+			return nil, fmt.Errorf("struct %v has no field %v", p.name, name)
+		}
+	}
+	var counts map[int]int64
+	if p.counted {
+		counts = make(map[int]int64)
+		for i := range p.instrs {
+			in := &p.instrs[i]
+			if in.length.kind != lengthFrom {
+				continue
+			}
+			n, err := lengthOf(m[in.name])
+			if err != nil {
+				return nil, p.encodeError(in, err)
+			}
+			if prev, ok := counts[in.length.from]; ok && prev != n {
+				return nil, p.encodeError(in, fmt.Errorf("has %v elements, another field counted by %v has %v",
+					n, p.instrs[in.length.from].name, prev))
+			}
+			counts[in.length.from] = n
+		}
+	}
+	if e.version2 && p.extensibility != typ.FinalExtensibility {
+		return e.delimit(b, func(b []byte) ([]byte, error) {
+			if p.extensibility == typ.MutableExtensibility {
+				return e.encodeMutable(b, p, m)
+			}
+			return e.encodeMembers(b, p, m, counts)
+		})
+	}
+	return e.encodeMembers(b, p, m, counts)
+}
+
+func (e encoder) encodeMembers(b []byte, p *plan, m map[string]interface{}, counts map[int]int64) ([]byte, error) {
+	var err error
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		v, ok := m[in.name]
+		if n, counted := counts[i]; counted {
+			if !ok {
+				v, ok = n, true
+			} else if got, err := integerOf(v); err != nil || got.neg || got.mag != uint64(n) {
+				return nil, p.encodeError(in, fmt.Errorf("value %v does not match length %v", v, n))
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("struct %v missing field %v", p.name, in.name)
+		}
+		if b, err = e.encodeValue(b, in, v); err != nil {
+			return nil, p.encodeError(in, err)
+		}
+	}
+	return b, nil
+}
+
+// encodeMutable writes the members of a mutable struct present in m, each
+// preceded by an EMHEADER whose NEXTINT holds the member size.
+func (e encoder) encodeMutable(b []byte, p *plan, m map[string]interface{}) ([]byte, error) {
+	var err error
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		v, ok := m[in.name]
+		if !ok {
+			continue
+		}
+		header := 4<<emLengthShift | in.id
+		if slices.Contains(p.keys, i) {
+			// XTypes requires key members to be understood by every reader.
+			header |= emMustUnderstand
+		}
+		b = e.appendUint32(b, header)
+		b, err = e.delimit(b, func(b []byte) ([]byte, error) {
+			return e.encodeValue(b, in, v)
+		})
+		if err != nil {
+			return nil, p.encodeError(in, err)
+		}
+	}
+	return b, nil
+}
+
+// delimit writes the size of what encode appends ahead of it, as a DHEADER
+// or NEXTINT.
+func (e encoder) delimit(b []byte, encode func([]byte) ([]byte, error)) ([]byte, error) {
+	b = e.appendUint32(b, 0)
+	start := len(b)
+	b, err := encode(b)
+	if err != nil {
+		return nil, err
+	}
+	if e.bigEndian {
+		binary.BigEndian.PutUint32(b[start-4:], uint32(len(b)-start))
+	} else {
+		binary.LittleEndian.PutUint32(b[start-4:], uint32(len(b)-start))
+	}
+	return b, nil
+}
+
+func (p *plan) encodeError(in *instruction, err error) error {
+	return fmt.Errorf("struct %v encode field %v error:%v", p.name, in.name, err.Error())
+}
+
+func (e encoder) encodeValue(b []byte, in *instruction, v interface{}) ([]byte, error) {
+	if e.cdr && in.size > 0 && in.op != opStruct {
+		b = e.align(b, in.size)
+		start := len(b)
+		b, err := encodeScalar(b, in, v)
+		if err != nil {
+			return nil, err
+		}
+		if !e.bigEndian {
+			reverse(b[start:])
+		}
+		return b, nil
+	}
+	switch in.op {
+	case opString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expect string got %T", v)
+		}
+		if err := in.checkBound(int64(len(s))); err != nil {
+			return nil, err
+		}
+		if e.cdr {
+			b = e.appendUint32(b, uint32(len(s)+1))
+			return append(append(b, s...), 0), nil
+		}
+		b, err := in.appendLength(b, int64(len(s)))
+		if err != nil {
+			return nil, err
+		}
+		return append(b, s...), nil
+	case opSequence:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("expect sequence got %T", v)
+		}
+		if err := in.checkBound(int64(rv.Len())); err != nil {
+			return nil, err
+		}
+		if e.version2 && in.delimited() {
+			return e.delimit(b, func(b []byte) ([]byte, error) {
+				return e.encodeSequence(b, in, rv)
+			})
+		}
+		return e.encodeSequence(b, in, rv)
+	case opStruct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect struct %v got %T", in.plan.name, v)
+		}
+		return e.encodeStruct(b, in.plan, m)
+	}
+	return encodeScalar(b, in, v)
+}
+
+func (e encoder) encodeSequence(b []byte, in *instruction, rv reflect.Value) ([]byte, error) {
+	b, err := e.appendLength(b, in, int64(rv.Len()))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if b, err = e.encodeValue(b, in.elem, rv.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("sequence element %v: %v", i, err)
+		}
+	}
+	return b, nil
+}
+
+// encodeScalar writes a fixed-size value other than a struct big-endian.
+func encodeScalar(b []byte, in *instruction, v interface{}) ([]byte, error) {
+	switch in.op {
+	case opInt8, opOctet, opShort, opUnsignedShort, opLong, opUnsignedLong, opLongLong, opUnsignedLongLong:
+		n, err := integerOf(v)
+		if err != nil {
+			return nil, err
+		}
+		signed := in.op == opInt8 || in.op == opShort || in.op == opLong || in.op == opLongLong
+		if !n.fits(in.size*8, signed) {
+			return nil, fmt.Errorf("value %v out of range for %v", v, in.op)
+		}
+		return appendUint(b, n.bits(), in.size), nil
+	case opBoolean:
+		bv, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expect boolean got %T", v)
+		}
+		if bv {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case opFloat:
+		f, err := floatOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+	case opDouble:
+		f, err := floatOf(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case opEnum:
+		return encodeEnum(b, in, v)
+	case opBitSet:
+		return encodeBitSet(b, in, v)
+	}
+	return nil, fmt.Errorf("unsupported op:%v", in.op)
+}
+
+func encodeEnum(b []byte, in *instruction, v interface{}) ([]byte, error) {
+	if name, ok := v.(string); ok {
+		for i, member := range in.members {
+			if member == name {
+				return binary.BigEndian.AppendUint32(b, uint32(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an enumerator", name)
+	}
+	n, err := integerOf(v)
+	if err != nil {
+		return nil, err
+	}
+	if n.neg || n.mag >= uint64(len(in.members)) {
+		return nil, fmt.Errorf("enum value %v out of range, %v members", v, len(in.members))
+	}
+	return binary.BigEndian.AppendUint32(b, uint32(n.mag)), nil
+}
+
+func encodeBitSet(b []byte, in *instruction, v interface{}) ([]byte, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expect bitset %v got %T", in.plan.name, v)
+	}
+	for name := range m {
+		if _, ok := in.plan.index[name]; !ok {
+			return nil, fmt.Errorf("bitset %v has no field %v", in.plan.name, name)
+		}
+	}
+	var raw uint64
+	for i := range in.plan.instrs {
+		field := &in.plan.instrs[i]
+		fv, ok := m[field.name]
+		if !ok {
+			return nil, fmt.Errorf("bitset %v missing field %v", in.plan.name, field.name)
+		}
+		n, err := integerOf(fv)
+		if err != nil {
+			return nil, fmt.Errorf("bitfield %v: %v", field.name, err)
+		}
+		if !n.fits(int(field.width), false) {
+			return nil, fmt.Errorf("bitfield %v value %v does not fit %v bits", field.name, fv, field.width)
+		}
+		raw |= n.mag << field.shift
+	}
+	return appendUint(b, raw, in.size), nil
+}
+
+// appendLength writes the element count of a sequence, which arrays only
+// check.
+func (e encoder) appendLength(b []byte, in *instruction, n int64) ([]byte, error) {
+	if in.length.kind == lengthFixed {
+		if n != int64(in.length.count) {
+			return nil, fmt.Errorf("array of %v elements, expect %v", n, in.length.count)
+		}
+		return b, nil
+	}
+	if e.cdr {
+		return e.appendUint32(b, uint32(n)), nil
+	}
+	return in.appendLength(b, n)
+}
+
+// align pads b with zeros to a multiple of size bytes, at most 4 with
+// XCDR2, after the CDR encapsulation header.
+func (e encoder) align(b []byte, size int) []byte {
+	if e.version2 && size > 4 {
+		size = 4
+	}
+	for (len(b)-cdrHeaderSize)%size != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// appendUint32 writes a CDR length or header aligned to 4 bytes.
+func (e encoder) appendUint32(b []byte, v uint32) []byte {
+	if e.bigEndian {
+		return binary.BigEndian.AppendUint32(e.align(b, 4), v)
+	}
+	return binary.LittleEndian.AppendUint32(e.align(b, 4), v)
+}
+
+// appendLength writes the inline length prefix of a string or sequence.
+// Lengths taken from another field or running to the end are not written.
+func (in *instruction) appendLength(b []byte, n int64) ([]byte, error) {
+	if in.length.kind != lengthPrefix {
+		return b, nil
+	}
+	if size := in.length.prefix; size < 8 && n >= 1<<(size*8) {
+		return nil, fmt.Errorf("length %v does not fit a %v-byte prefix", n, size)
+	}
+	return appendUint(b, uint64(n), in.length.prefix), nil
+}
+
+func appendUint(b []byte, v uint64, size int) []byte {
+	switch size {
+	case 1:
+		return append(b, uint8(v))
+	case 2:
+		return binary.BigEndian.AppendUint16(b, uint16(v))
+	case 4:
+		return binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func lengthOf(v interface{}) (int64, error) {
+	if s, ok := v.(string); ok {
+		return int64(len(s)), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("expect string or sequence got %T", v)
+	}
+	return int64(rv.Len()), nil
+}
+
+// intValue is a sign and magnitude, so that every int64 and uint64 value can
+// be range checked against the width of a field.
+type intValue struct {
+	neg bool
+	mag uint64
+}
+
+func (n intValue) fits(bits int, signed bool) bool {
+	switch {
+	case signed && n.neg:
+		return n.mag <= 1<<(bits-1)
+	case signed:
+		return n.mag <= 1<<(bits-1)-1
+	case n.neg:
+		return false
+	case bits == 64:
+		return true
+	}
+	return n.mag < 1<<bits
+}
+
+// bits returns the two's complement representation of n.
+func (n intValue) bits() uint64 {
+	if n.neg {
+		return -n.mag
+	}
+	return n.mag
+}
+
+func signedInteger(v int64) intValue {
+	if v < 0 {
+		return intValue{neg: true, mag: uint64(-(v + 1)) + 1}
+	}
+	return intValue{mag: uint64(v)}
+}
+
+func integerOf(v interface{}) (intValue, error) {
+	switch n := v.(type) {
+	case int:
+		return signedInteger(int64(n)), nil
+	case int8:
+		return signedInteger(int64(n)), nil
+	case int16:
+		return signedInteger(int64(n)), nil
+	case int32:
+		return signedInteger(int64(n)), nil
+	case int64:
+		return signedInteger(n), nil
+	case uint:
+		return intValue{mag: uint64(n)}, nil
+	case uint8:
+		return intValue{mag: uint64(n)}, nil
+	case uint16:
+		return intValue{mag: uint64(n)}, nil
+	case uint32:
+		return intValue{mag: uint64(n)}, nil
+	case uint64:
+		return intValue{mag: n}, nil
+	case float64:
+		if n != math.Trunc(n) || math.Abs(n) >= 1<<64 {
+			return intValue{}, fmt.Errorf("%v is not an integer", n)
+		}
+		if n < 0 {
+			return intValue{neg: true, mag: uint64(-n)}, nil
+		}
+		return intValue{mag: uint64(n)}, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return signedInteger(i), nil
+		}
+		if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+			return intValue{mag: u}, nil
+		}
+		return intValue{}, fmt.Errorf("%v is not an integer", n)
+	}
+	return intValue{}, fmt.Errorf("expect integer got %T", v)
+}
+
+func floatOf(v interface{}) (float64, error) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), nil
+	case float64:
+		return f, nil
+	case json.Number:
+		return f.Float64()
+	}
+	n, err := integerOf(v)
+	if err != nil {
+		return 0, fmt.Errorf("expect float got %T", v)
+	}
+	if n.neg {
+		return -float64(n.mag), nil
+	}
+	return float64(n.mag), nil
+}
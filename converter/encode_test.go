@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *IDLConverter
+		data []byte
+	}{
+		{"primitives", newTestConverter(t, benchStruct), benchData},
+		{"scoped types", &IDLConverter{Schema: scopedSchema, TypeName: "spi::can::Frame"}, scopedFrameData},
+		{"native numbers", &IDLConverter{Schema: scopedSchema, TypeName: "spi::can::Frame", NumberMode: NumberModeNative}, scopedFrameData},
+		{"length encodings", &IDLConverter{Schema: lengthSchema, TypeName: "m::Frame"}, lengthData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.c.plan == nil {
+				require.NoError(t, tt.c.Init())
+			}
+			m, err := tt.c.Decode(tt.data)
+			require.NoError(t, err)
+			got, err := tt.c.Encode(m)
+			require.NoError(t, err)
+			require.Equal(t, tt.data, got)
+		})
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	c, err := NewIDLConverterFromString(lengthSchema, "m::Frame")
+	require.NoError(t, err)
+	dec := json.NewDecoder(strings.NewReader(`{
+		"name": "abc",
+		"values": [1, 2],
+		"inners": [{"tag": "x"}, {"tag": ""}],
+		"rest": [7, 8, 9]
+	}`))
+	dec.UseNumber()
+	var m map[string]interface{}
+	require.NoError(t, dec.Decode(&m))
+	got, err := c.Encode(m)
+	require.NoError(t, err)
+	require.Equal(t, lengthData, got)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	c, err := NewIDLConverterFromString(`module e {
+	enum State { IDLE, RUNNING };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<5> code;
+	};
+	struct S {
+		octet o;
+		short s;
+		unsigned long long u;
+		State state;
+		Flags flags;
+		octet n;
+		@length_from(n) sequence<octet> a;
+		@length_prefix(1) string b;
+	};
+}`, "e::S")
+	require.NoError(t, err)
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"o":     255,
+			"s":     -32768,
+			"u":     uint64(18446744073709551615),
+			"state": "IDLE",
+			"flags": map[string]interface{}{"level": 7, "code": 31},
+			"a":     []byte{1, 2},
+			"b":     "",
+		}
+	}
+	data, err := c.Encode(valid())
+	require.NoError(t, err)
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), m["n"])
+
+	tests := []struct {
+		name   string
+		field  string
+		value  interface{}
+		errMsg string
+	}{
+		{"octet overflow", "o", 256, "out of range"},
+		{"short underflow", "s", -32769, "out of range"},
+		{"negative unsigned", "u", -1, "out of range"},
+		{"fraction", "o", 1.5, "is not an integer"},
+		{"unknown enumerator", "state", "PAUSED", "is not an enumerator"},
+		{"bitfield overflow", "flags", map[string]interface{}{"level": 8, "code": 0}, "does not fit 3 bits"},
+		{"missing bitfield", "flags", map[string]interface{}{"level": 1}, "missing field code"},
+		{"wrong count", "n", 3, "does not match length 2"},
+		{"prefix overflow", "b", string(make([]byte, 256)), "does not fit a 1-byte prefix"},
+		{"unknown field", "x", 1, "has no field x"},
+		{"wrong type", "b", 1, "expect string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			m[tt.field] = tt.value
+			_, err := c.Encode(m)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+
+	m = valid()
+	delete(m, "b")
+	_, err = c.Encode(m)
+	require.ErrorContains(t, err, "missing field b")
+}
@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+)
+
+const keyHashSize = 16
+
+// InstanceKey identifies a DDS instance by the @key members of a sample.
+type InstanceKey struct {
+	// Fields holds the key members by name. A struct member holds its own
+	// key members, or all of its members when it has none.
+	Fields map[string]interface{}
+	// Hash is the key members serialized as big-endian CDR, zero-padded
+	// when no key of the struct can exceed 16 bytes and hashed with MD5
+	// otherwise.
+	Hash [keyHashSize]byte
+}
+
+// Key extracts the instance key of v, keyed by field name like the input of
+// Encode and the output of Decode.
+func (c *IDLConverter) Key(v map[string]interface{}) (InstanceKey, error) {
+	if c.plan == nil {
+		return InstanceKey{}, errors.New("converter is not initialized")
+	}
+	if len(c.plan.keys) == 0 {
+		return InstanceKey{}, fmt.Errorf("struct %v has no @key member", c.plan.name)
+	}
+	fields, err := keyFields(c.plan, v, c.maxDepth())
+	if err != nil {
+		return InstanceKey{}, err
+	}
+	// Alignment counts from the start of the key, as if it followed an
+	// encapsulation header.
+	e := encoder{cdr: true, bigEndian: true, depth: c.maxDepth()}
+	b, err := e.encodeKey(make([]byte, cdrHeaderSize), c.plan, v)
+	if err != nil {
+		return InstanceKey{}, err
+	}
+	key := InstanceKey{Fields: fields}
+	if end, ok := keyEnd(c.plan, 0, c.maxDepth()); ok && end <= keyHashSize {
+		copy(key.Hash[:], b[cdrHeaderSize:])
+	} else {
+		key.Hash = md5.Sum(b[cdrHeaderSize:])
+	}
+	return key, nil
+}
+
+// DecodeKey decodes data and extracts the instance key of the sample.
+func (c *IDLConverter) DecodeKey(data []byte) (InstanceKey, error) {
+	m, err := c.Decode(data)
+	if err != nil {
+		return InstanceKey{}, err
+	}
+	return c.Key(m)
+}
+
+// keyMembers returns the indices of the key members of p, or of all its
+// members when it has none.
+func (p *plan) keyMembers() []int {
+	if len(p.keys) > 0 {
+		return p.keys
+	}
+	return allMembers(p)
+}
+
+func keyFields(p *plan, m map[string]interface{}, depth int) (map[string]interface{}, error) {
+	if depth <= 0 {
+		return nil, p.depthError()
+	}
+	fields := make(map[string]interface{}, len(p.keys))
+	for _, i := range p.keyMembers() {
+		in := &p.instrs[i]
+		v, ok := m[in.name]
+		if !ok {
+			return nil, fmt.Errorf("struct %v missing field %v", p.name, in.name)
+		}
+		if in.op == opStruct {
+			nested, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, p.encodeError(in, fmt.Errorf("expect struct %v got %T", in.plan.name, v))
+			}
+			var err error
+			if v, err = keyFields(in.plan, nested, depth-1); err != nil {
+				return nil, p.encodeError(in, err)
+			}
+		}
+		fields[in.name] = v
+	}
+	return fields, nil
+}
+
+// encodeKey writes the key members of p in m, a struct member being
+// written as its own key.
+func (e encoder) encodeKey(b []byte, p *plan, m map[string]interface{}) ([]byte, error) {
+	if e.depth <= 0 {
+		return nil, p.depthError()
+	}
+	e.depth--
+	for _, i := range p.keyMembers() {
+		in := &p.instrs[i]
+		v, ok := m[in.name]
+		if !ok {
+			return nil, fmt.Errorf("struct %v missing field %v", p.name, in.name)
+		}
+		var err error
+		if nested, isMap := v.(map[string]interface{}); isMap && in.op == opStruct {
+			b, err = e.encodeKey(b, in.plan, nested)
+		} else {
+			b, err = e.encodeValue(b, in, v)
+		}
+		if err != nil {
+			return nil, p.encodeError(in, err)
+		}
+	}
+	return b, nil
+}
+
+// keyEnd returns the end of the largest key of p written from pos, or false
+// when it may exceed the key hash.
+func keyEnd(p *plan, pos, depth int) (int, bool) {
+	return membersEnd(p, p.keyMembers(), pos, depth, true)
+}
+
+func membersEnd(p *plan, members []int, pos, depth int, key bool) (int, bool) {
+	if depth <= 0 {
+		return 0, false
+	}
+	ok := true
+	for _, i := range members {
+		in := &p.instrs[i]
+		if in.op == opStruct && key {
+			pos, ok = keyEnd(in.plan, pos, depth-1)
+		} else {
+			pos, ok = valueEnd(in, pos, depth)
+		}
+		if !ok || pos > keyHashSize {
+			return 0, false
+		}
+	}
+	return pos, true
+}
+
+// valueEnd returns the end of the largest value of in written from pos.
+func valueEnd(in *instruction, pos, depth int) (int, bool) {
+	switch {
+	case in.op == opStruct:
+		return membersEnd(in.plan, allMembers(in.plan), pos, depth-1, false)
+	case in.size > 0:
+		return alignTo(pos, in.size) + in.size, true
+	case in.op == opString:
+		return alignTo(pos, 4) + 4 + in.bound + 1, in.bound > 0
+	}
+	n := in.length.count
+	if in.length.kind != lengthFixed {
+		if in.bound == 0 {
+			return 0, false
+		}
+		n, pos = in.bound, alignTo(pos, 4)+4
+	}
+	for i := 0; i < n; i++ {
+		var ok bool
+		if pos, ok = valueEnd(in.elem, pos, depth); !ok || pos > keyHashSize {
+			return 0, false
+		}
+	}
+	return pos, true
+}
+
+func allMembers(p *plan) []int {
+	all := make([]int, len(p.instrs))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+func alignTo(pos, size int) int {
+	return (pos + size - 1) / size * size
+}
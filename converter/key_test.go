@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const keySchema = `module m {
+	struct Loc {
+		@key short zone;
+		long spot;
+	};
+	struct Reading {
+		@key long sensor;
+		@key Loc loc;
+		@key(FALSE) double value;
+	};
+	struct Named {
+		@key string name;
+		long v;
+	};
+	struct Point {
+		long x;
+		long y;
+	};
+	struct Track {
+		@key Point at;
+		@key string<8> label;
+	};
+	struct Plain {
+		long v;
+	};
+}`
+
+func TestKey(t *testing.T) {
+	hash := func(s string) [16]byte {
+		var h [16]byte
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		copy(h[:], b)
+		return h
+	}
+	tests := []struct {
+		name     string
+		typeName string
+		sample   map[string]interface{}
+		fields   map[string]interface{}
+		hash     [16]byte
+	}{
+		{
+			name:     "padded key with a nested key",
+			typeName: "m::Reading",
+			sample: map[string]interface{}{
+				"sensor": int64(7),
+				"loc":    map[string]interface{}{"zone": int64(3), "spot": int64(9)},
+				"value":  1.5,
+			},
+			fields: map[string]interface{}{
+				"sensor": int64(7),
+				"loc":    map[string]interface{}{"zone": int64(3)},
+			},
+			hash: hash("000000070003"),
+		},
+		{
+			name:     "unbounded key hashed",
+			typeName: "m::Named",
+			sample:   map[string]interface{}{"name": "hi", "v": int64(1)},
+			fields:   map[string]interface{}{"name": "hi"},
+			hash:     hash("57b690e3f20ec0029f3037cb21599d07"),
+		},
+		{
+			name:     "bounded key that may exceed 16 bytes",
+			typeName: "m::Track",
+			sample: map[string]interface{}{
+				"at":    map[string]interface{}{"x": int64(1), "y": int64(2)},
+				"label": "",
+			},
+			fields: map[string]interface{}{
+				"at":    map[string]interface{}{"x": int64(1), "y": int64(2)},
+				"label": "",
+			},
+			hash: hash("684d8a8af2947c2e387d4b37f6302831"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCDRConverter(t, keySchema, tt.typeName)
+			key, err := c.Key(tt.sample)
+			require.NoError(t, err)
+			require.Equal(t, tt.fields, key.Fields)
+			require.Equal(t, tt.hash, key.Hash)
+
+			data, err := c.Encode(tt.sample)
+			require.NoError(t, err)
+			decoded, err := c.DecodeKey(data)
+			require.NoError(t, err)
+			require.Equal(t, key, decoded)
+		})
+	}
+}
+
+func TestKeyErrors(t *testing.T) {
+	_, err := newCDRConverter(t, keySchema, "m::Plain").Key(map[string]interface{}{"v": 1})
+	require.EqualError(t, err, "struct Plain has no @key member")
+
+	c := newCDRConverter(t, keySchema, "m::Reading")
+	_, err = c.Key(map[string]interface{}{"sensor": 1})
+	require.EqualError(t, err, "struct Reading missing field loc")
+	_, err = c.Key(map[string]interface{}{"sensor": 1, "loc": map[string]interface{}{}})
+	require.EqualError(t, err, "struct Reading encode field loc error:struct Loc missing field zone")
+	_, err = c.Key(map[string]interface{}{"sensor": "a", "loc": map[string]interface{}{"zone": 1}})
+	require.EqualError(t, err, "struct Reading encode field sensor error:expect integer got string")
+
+	err = (&IDLConverter{Schema: `module m { struct S { @key(maybe) long a; }; }`, TypeName: "m::S"}).Init()
+	require.EqualError(t, err, `st S field a: @key expects TRUE or FALSE, got "maybe"`)
+}
@@ -0,0 +1,564 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+)
+
+type opcode uint8
+
+const (
+	opInt8 opcode = iota
+	opOctet
+	opShort
+	opUnsignedShort
+	opLong
+	opUnsignedLong
+	opLongLong
+	opUnsignedLongLong
+	opBoolean
+	opFloat
+	opDouble
+	opString
+	opSequence
+	opStruct
+	opEnum
+	opBitSet
+	opBitField
+)
+
+var opcodeInfos = [...]struct {
+	name    string
+	size    int
+	refType typ.FieldRefType
+}{
+	opInt8:             {"int8", 1, typ.Int8Type},
+	opOctet:            {"octet", 1, typ.OctetType},
+	opShort:            {"short", 2, typ.ShortType},
+	opUnsignedShort:    {"unsigned short", 2, typ.UnsignedShortType},
+	opLong:             {"long", 4, typ.LongType},
+	opUnsignedLong:     {"unsigned long", 4, typ.UnsignedLongType},
+	opLongLong:         {"long long", 8, typ.LongLongType},
+	opUnsignedLongLong: {"unsigned long long", 8, typ.UnsignedLongLongType},
+	opBoolean:          {"boolean", 1, typ.BooleanType},
+	opFloat:            {"float", 4, typ.FloatType},
+	opDouble:           {"double", 8, typ.DoubleType},
+	opString:           {"string", 0, typ.StringType},
+	opSequence:         {"sequence", 0, typ.SequenceType},
+	opStruct:           {"struct", 0, typ.SelfDefinedTypeType},
+	opEnum:             {"enum", 4, typ.SelfDefinedTypeType},
+	opBitSet:           {"bitset", 0, typ.SelfDefinedTypeType},
+	opBitField:         {"bitfield", 0, typ.BitFieldType},
+}
+
+func (op opcode) String() string {
+	return opcodeInfos[op].name
+}
+
+func (op opcode) integer() bool {
+	return op <= opUnsignedLongLong
+}
+
+type lengthKind uint8
+
+const (
+	lengthPrefix lengthKind = iota
+	lengthFrom
+	lengthToEnd
+	lengthFixed
+)
+
+const (
+	lengthPrefixAnnotation = "length_prefix"
+	lengthFromAnnotation   = "length_from"
+	lengthToEndAnnotation  = "length_to_end"
+	idAnnotation           = "id"
+	keyAnnotation          = "key"
+)
+
+// length describes how the element count of a string or sequence is
+// encoded: as an inline big-endian prefix of prefix bytes, as the value of
+// the earlier field from of the same struct, implicitly by running to the
+// end of the data, or not at all for an array of count elements.
+type length struct {
+	kind   lengthKind
+	prefix int
+	from   int
+	count  int
+}
+
+func validLengthPrefix(size int) bool {
+	return size == 1 || size == 2 || size == 4 || size == 8
+}
+
+// instruction decodes one value. Fixed-size values have a non-zero size;
+// fields of the leading run of fixed-size fields are read at a precomputed
+// offset after a single length check for the whole run.
+type instruction struct {
+	op       opcode
+	name     string
+	size     int
+	offset   int
+	inPrefix bool
+	// elem is the element of a sequence or array.
+	elem *instruction
+	// plan holds the members of a struct or the bitfields of a bitset.
+	plan *plan
+	// members are the enumerators of an enum.
+	members []string
+	// width and shift locate a bitfield inside its bitset.
+	width uint8
+	shift uint8
+	// length is the length encoding of a string or sequence.
+	length length
+	// bound is the maximum length of a bounded string or sequence.
+	bound int
+	// id is the member id of a struct member, from @id or one more than
+	// the previous member's.
+	id uint32
+	// inner marks the inner dimensions of a multidimensional array, which
+	// XCDR2 delimits together with the outermost one.
+	inner bool
+}
+
+func (in *instruction) checkBound(n int64) error {
+	if in.bound > 0 && n > int64(in.bound) {
+		if in.op == opString {
+			return fmt.Errorf("string of %v bytes exceeds bound %v", n, in.bound)
+		}
+		return fmt.Errorf("sequence of %v elements exceeds bound %v", n, in.bound)
+	}
+	return nil
+}
+
+func (in *instruction) minSize() int {
+	switch {
+	case in.size > 0:
+		return in.size
+	case in.op == opStruct:
+		return in.plan.min
+	case in.length.kind == lengthFixed:
+		return in.length.count * in.elem.minSize()
+	case in.length.kind == lengthPrefix:
+		return in.length.prefix
+	}
+	return 0
+}
+
+// delimited reports whether XCDR2 precedes the sequence or array with a
+// DHEADER, which it does unless the innermost element is primitive.
+func (in *instruction) delimited() bool {
+	if in.op != opSequence || in.inner {
+		return false
+	}
+	e := in.elem
+	for e.inner {
+		e = e.elem
+	}
+	return e.op == opStruct || e.size == 0
+}
+
+// open reports whether the value runs to the end of the data.
+func (in *instruction) open() bool {
+	if in.op == opStruct {
+		return in.plan.open()
+	}
+	return (in.op == opString || in.op == opSequence) && in.length.kind == lengthToEnd
+}
+
+type plan struct {
+	name   string
+	instrs []instruction
+	prefix int
+	min    int
+	index  map[string]int
+	// counted is set when a field takes its length from another field.
+	counted bool
+	// cdr is set when the plan is compiled for EncodingCDR.
+	cdr bool
+	// recursive is set when the plan contains itself through a sequence,
+	// directly or in a nested struct, or contains such a plan.
+	recursive bool
+	// extensibility is that of the struct with EncodingCDR; packed data has
+	// no room for it and is always final.
+	extensibility typ.Extensibility
+	// extensible is set when the plan or a plan it contains is appendable
+	// or mutable, which only XCDR2 can encode.
+	extensible bool
+	// ids maps the member ids of a mutable plan to its instructions.
+	ids map[uint32]int
+	// keys are the indices of the @key members.
+	keys []int
+}
+
+func (p *plan) fixed() bool {
+	return len(p.instrs) == 0 || p.instrs[len(p.instrs)-1].inPrefix
+}
+
+func (p *plan) open() bool {
+	return len(p.instrs) > 0 && p.instrs[len(p.instrs)-1].open()
+}
+
+func (p *plan) add(in instruction) {
+	if (len(p.instrs) == 0 || p.instrs[len(p.instrs)-1].inPrefix) && in.size > 0 {
+		in.inPrefix = true
+		in.offset = p.prefix
+		p.prefix += in.size
+	}
+	p.min += in.minSize()
+	p.index[in.name] = len(p.instrs)
+	p.instrs = append(p.instrs, in)
+}
+
+func newPlan(name string, n int) *plan {
+	return &plan{
+		name:   name,
+		instrs: make([]instruction, 0, n),
+		index:  make(map[string]int, n),
+	}
+}
+
+// compiler turns struct definitions into plans, resolving type names
+// against the scope each definition was declared in. Strings and sequences
+// without a length annotation get an inline prefix of lengthPrefix bytes.
+type compiler struct {
+	inProgress   map[string]progress
+	stack        []*plan
+	sequences    int
+	lengthPrefix int
+	cdr          bool
+}
+
+// progress is a struct being compiled: its plan so far and the number of
+// sequences enclosing it.
+type progress struct {
+	plan      *plan
+	sequences int
+}
+
+func newCompiler(lengthPrefix int) *compiler {
+	return &compiler{inProgress: make(map[string]progress), lengthPrefix: lengthPrefix}
+}
+
+func compilePlan(st struct_type.Struct, s *ast.Scope) (*plan, error) {
+	return newCompiler(defaultLengthPrefix).compileStruct(st, s)
+}
+
+func compileType(t typeref.TypeRef) (instruction, error) {
+	return newCompiler(defaultLengthPrefix).compileType(t, nil)
+}
+
+func (cp *compiler) compileStruct(st struct_type.Struct, s *ast.Scope) (*plan, error) {
+	key := s.Qualify(st.Name)
+	if prev, ok := cp.inProgress[key]; ok {
+		if prev.sequences == cp.sequences {
+			return nil, fmt.Errorf("struct %v contains itself", key)
+		}
+		// Reached again inside a sequence, which may be empty: the plan
+		// refers to itself and so does every plan enclosing it.
+		for _, p := range cp.stack {
+			p.recursive = true
+		}
+		return prev.plan, nil
+	}
+	members, err := s.Members(st)
+	if err != nil {
+		return nil, err
+	}
+	p := newPlan(st.Name, len(members))
+	p.cdr = cp.cdr
+	if cp.cdr {
+		p.extensibility = st.Extensibility
+	}
+	if p.extensibility != typ.FinalExtensibility {
+		p.extensible = true
+		for _, q := range cp.stack {
+			q.extensible = true
+		}
+	}
+	if p.extensibility == typ.MutableExtensibility {
+		p.ids = make(map[uint32]int, len(members))
+	}
+	cp.inProgress[key] = progress{plan: p, sequences: cp.sequences}
+	cp.stack = append(cp.stack, p)
+	defer func() {
+		delete(cp.inProgress, key)
+		cp.stack = cp.stack[:len(cp.stack)-1]
+	}()
+
+	// Inherited fields come first, so that a derived value starts with its
+	// base.
+	var id uint32
+	for i, m := range members {
+		field := m.Field
+		if _, ok := p.index[field.Name]; ok {
+			return nil, fmt.Errorf("st %v field %v is declared more than once", st.Name, field.Name)
+		}
+		in, err := cp.compileType(field.Type, m.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("st %v has unsupported field %v: %v", st.Name, field.Name, err)
+		}
+		if err := p.applyLength(&in, field); err != nil {
+			return nil, fmt.Errorf("st %v field %v: %v", st.Name, field.Name, err)
+		}
+		if in.open() && i < len(members)-1 {
+			return nil, fmt.Errorf("st %v field %v runs to the end of the data and must be the last field", st.Name, field.Name)
+		}
+		in.name = field.Name
+		if p.cdr {
+			if id, err = memberID(field, id); err != nil {
+				return nil, fmt.Errorf("st %v field %v: %v", st.Name, field.Name, err)
+			}
+			in.id = id
+			id++
+		}
+		key, err := isKey(field)
+		if err != nil {
+			return nil, fmt.Errorf("st %v field %v: %v", st.Name, field.Name, err)
+		}
+		if key {
+			p.keys = append(p.keys, len(p.instrs))
+		}
+		if p.ids != nil {
+			if prev, ok := p.ids[in.id]; ok {
+				return nil, fmt.Errorf("st %v field %v: member id %v is already used by %v", st.Name, field.Name, in.id, p.instrs[prev].name)
+			}
+			p.ids[in.id] = len(p.instrs)
+		}
+		p.add(in)
+	}
+	if p.extensibility != typ.FinalExtensibility {
+		// Only the DHEADER is certain: members may be missing.
+		p.min = 4
+	}
+	return p, nil
+}
+
+// memberID returns the member id of field from its @id annotation, or next.
+func memberID(field struct_type.Field, next uint32) (uint32, error) {
+	anno, ok := field.Annotations.Get(idAnnotation)
+	if !ok {
+		return next, nil
+	}
+	id, err := strconv.ParseUint(anno.Values["value"], 10, 28)
+	if err != nil {
+		return 0, fmt.Errorf("@%v expects a member id below 2^28, got %q", idAnnotation, anno.Values["value"])
+	}
+	return uint32(id), nil
+}
+
+// isKey reports whether field is annotated @key or @key(TRUE).
+func isKey(field struct_type.Field) (bool, error) {
+	anno, ok := field.Annotations.Get(keyAnnotation)
+	if !ok {
+		return false, nil
+	}
+	switch v := anno.Values["value"]; strings.ToUpper(v) {
+	case "", "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	default:
+		return false, fmt.Errorf("@%v expects TRUE or FALSE, got %q", keyAnnotation, v)
+	}
+}
+
+// applyLength sets the length encoding of a string or sequence field from
+// its @length_prefix(n), @length_from(field) or @length_to_end annotation.
+func (p *plan) applyLength(in *instruction, field struct_type.Field) error {
+	var found string
+	for _, anno := range field.Annotations {
+		switch anno.Name {
+		case lengthPrefixAnnotation, lengthFromAnnotation, lengthToEndAnnotation:
+		default:
+			continue
+		}
+		if in.op != opString && in.op != opSequence || in.length.kind == lengthFixed {
+			return fmt.Errorf("@%v applies only to strings and sequences", anno.Name)
+		}
+		if p.cdr {
+			return fmt.Errorf("@%v is not supported with CDR encoding", anno.Name)
+		}
+		if found != "" {
+			return fmt.Errorf("conflicting length annotations @%v and @%v", found, anno.Name)
+		}
+		found = anno.Name
+		switch anno.Name {
+		case lengthPrefixAnnotation:
+			size, err := strconv.Atoi(anno.Values["value"])
+			if err != nil || !validLengthPrefix(size) {
+				return fmt.Errorf("@%v expects 1, 2, 4 or 8, got %q", anno.Name, anno.Values["value"])
+			}
+			in.length = length{kind: lengthPrefix, prefix: size}
+		case lengthFromAnnotation:
+			name := anno.Values["value"]
+			from, ok := p.index[name]
+			if !ok {
+				return fmt.Errorf("@%v field %q is not an earlier field", anno.Name, name)
+			}
+			if !p.instrs[from].op.integer() {
+				return fmt.Errorf("@%v field %v is %v, expect an integer", anno.Name, name, p.instrs[from].op)
+			}
+			in.length = length{kind: lengthFrom, from: from}
+			p.counted = true
+		case lengthToEndAnnotation:
+			in.length = length{kind: lengthToEnd}
+		}
+	}
+	return nil
+}
+
+func (cp *compiler) compileType(t typeref.TypeRef, s *ast.Scope) (instruction, error) {
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		cp.sequences++
+		elem, err := cp.compileType(t.(typeref.Sequence).InnerType, s)
+		cp.sequences--
+		if err != nil {
+			return instruction{}, err
+		}
+		if elem.open() {
+			return instruction{}, fmt.Errorf("sequence element %v runs to the end of the data", t.(typeref.Sequence).InnerType.TypeName())
+		}
+		return instruction{
+			op:     opSequence,
+			elem:   &elem,
+			length: length{prefix: cp.lengthPrefix},
+			bound:  t.(typeref.Sequence).Bound,
+		}, nil
+	case typ.SelfDefinedTypeType:
+		if s == nil {
+			break
+		}
+		return cp.compileTypeName(t.TypeName(), s)
+	}
+	for op, info := range opcodeInfos[:opSequence] {
+		if info.refType == t.TypeRefType() {
+			in := instruction{op: opcode(op), size: info.size}
+			if in.op == opString {
+				in.length.prefix = cp.lengthPrefix
+				in.bound = t.(typeref.StringType).Bound
+			}
+			return in, nil
+		}
+	}
+	return instruction{}, fmt.Errorf("unsupported type:%v", t.TypeName())
+}
+
+func (cp *compiler) compileTypeName(name string, s *ast.Scope) (instruction, error) {
+	def, owner, err := s.Resolve(name)
+	if err != nil {
+		return instruction{}, err
+	}
+	switch d := def.(type) {
+	case struct_type.Struct:
+		p, err := cp.compileStruct(d, owner)
+		if err != nil {
+			return instruction{}, err
+		}
+		in := instruction{op: opStruct, plan: p}
+		if p.fixed() && !p.recursive && p.extensibility == typ.FinalExtensibility {
+			in.size = p.prefix
+		}
+		return in, nil
+	case enum_type.Enum:
+		return instruction{op: opEnum, size: 4, members: d.Members}, nil
+	case bitset.BitSet:
+		return compileBitSet(d)
+	case typedef_type.Typedef:
+		return cp.compileTypedef(d, owner)
+	case union_type.Union:
+		return instruction{}, fmt.Errorf("union %v is not supported", name)
+	case forward_type.Forward:
+		return instruction{}, fmt.Errorf("%v %v is declared but not defined", d.Kind, name)
+	}
+	return instruction{}, fmt.Errorf("%v is not a type", name)
+}
+
+// compileTypedef compiles the aliased type of a typedef in the scope of the
+// typedef, wrapped in an array for each dimension with the first dimension
+// outermost. Arrays have no length on the wire.
+func (cp *compiler) compileTypedef(def typedef_type.Typedef, s *ast.Scope) (instruction, error) {
+	in, err := cp.compileType(def.Aliased, s)
+	if err != nil {
+		return instruction{}, fmt.Errorf("typedef %v: %v", def.Name, err)
+	}
+	for i := len(def.Dims) - 1; i >= 0; i-- {
+		if in.open() {
+			return instruction{}, fmt.Errorf("typedef %v element runs to the end of the data", def.Name)
+		}
+		elem := in
+		elem.inner = i < len(def.Dims)-1
+		in = instruction{
+			op:     opSequence,
+			elem:   &elem,
+			length: length{kind: lengthFixed, count: def.Dims[i]},
+		}
+	}
+	return in, nil
+}
+
+func compileBitSet(bs bitset.BitSet) (instruction, error) {
+	p := newPlan(bs.Name, len(bs.Fields))
+	total := 0
+	for _, field := range bs.Fields {
+		p.index[field.Name] = len(p.instrs)
+		p.instrs = append(p.instrs, instruction{
+			op:    opBitField,
+			name:  field.Name,
+			width: field.Type.Width,
+			shift: uint8(total),
+		})
+		total += int(field.Type.Width)
+	}
+	if total == 0 || total > 64 {
+		return instruction{}, fmt.Errorf("bitset %v has total width %v, expect 1 to 64", bs.Name, total)
+	}
+	return instruction{op: opBitSet, size: bitSetSize(total), plan: p}, nil
+}
+
+// bitSetSize returns the bytes of the smallest unsigned integer holding all
+// bits of a bitset. The first bitfield occupies the least significant bits.
+func bitSetSize(width int) int {
+	switch {
+	case width <= 8:
+		return 1
+	case width <= 16:
+		return 2
+	case width <= 32:
+		return 4
+	}
+	return 8
+}
+
+func (p *plan) checkPrefix(data []byte) error {
+	if len(data) >= p.prefix {
+		return nil
+	}
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		if in.offset+in.size > len(data) {
+			return p.fieldError(in, fmt.Errorf("expect data len %v got len %v", in.size, len(data)-in.offset))
+		}
+	}
+	return nil
+}
+
+func (p *plan) depthError() error {
+	return fmt.Errorf("struct %v exceeds the maximum nesting depth", p.name)
+}
+
+func (p *plan) fieldError(in *instruction, err error) error {
+	return fmt.Errorf("struct %v parse field %v error:%v", p.name, in.name, err.Error())
+}
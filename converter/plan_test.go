@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+var benchStruct = struct_type.Struct{
+	Name: "CANFrame",
+	Fields: []struct_type.Field{
+		{Name: "header", Type: typeref.NewOctetType()},
+		{Name: "id", Type: typeref.NewUnsignedShortType()},
+		{Name: "timestamp", Type: typeref.NewUnsignedLongLong()},
+		{Name: "ratio", Type: typeref.NewFloatType()},
+		{Name: "source", Type: typeref.NewStringType()},
+		{Name: "payload", Type: typeref.NewSequence(typeref.NewOctetType())},
+		{Name: "crc", Type: typeref.NewLongType()},
+	},
+}
+
+var benchData = []byte{
+	0x2A,
+	0x01, 0x02,
+	0, 0, 0, 0, 0, 0, 0x30, 0x39,
+	0x40, 0x48, 0xF5, 0xC3,
+	0, 0, 0, 4, 'c', 'a', 'n', '0',
+	0, 0, 0, 8, 1, 2, 3, 4, 5, 6, 7, 8,
+	0xFF, 0xFF, 0xFF, 0xFE,
+}
+
+func TestCompilePlan(t *testing.T) {
+	p, err := compilePlan(benchStruct, &ast.Scope{})
+	require.NoError(t, err)
+	require.Equal(t, 15, p.prefix)
+	offsets := []int{0, 1, 3, 11}
+	for i, offset := range offsets {
+		require.True(t, p.instrs[i].inPrefix)
+		require.Equal(t, offset, p.instrs[i].offset)
+	}
+	for _, in := range p.instrs[len(offsets):] {
+		require.False(t, in.inPrefix)
+	}
+	require.Equal(t, 6, p.index["crc"])
+
+	_, err = compilePlan(struct_type.Struct{Name: "A", Fields: []struct_type.Field{
+		{Name: "bits", Type: typeref.NewBitField(3)},
+	}}, &ast.Scope{})
+	require.Error(t, err)
+}
+
+func TestDecodeWithPlan(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	m, err := c.Decode(benchData)
+	require.NoError(t, err)
+	require.Equal(t, int64(0x2A), m["header"])
+	require.Equal(t, int64(0x0102), m["id"])
+	require.Equal(t, uint64(12345), m["timestamp"])
+	require.InDelta(t, 3.14, m["ratio"], 0.001)
+	require.Equal(t, "can0", m["source"])
+	require.Equal(t, []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8)}, m["payload"])
+	require.Equal(t, int64(-2), m["crc"])
+
+	_, err = c.Decode(benchData[:5])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parse field timestamp")
+
+	_, err = c.Decode(benchData[:len(benchData)-1])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parse field crc")
+
+	_, err = (&IDLConverter{}).Decode(benchData)
+	require.Error(t, err)
+}
+
+func TestDecodeRecord(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	var rec Record
+	require.NoError(t, c.DecodeRecord(benchData, &rec))
+	require.Equal(t, 7, rec.Len())
+	require.Equal(t, "header", rec.Name(0))
+	require.Equal(t, typ.OctetType, rec.Field(0).Kind())
+	require.Equal(t, uint64(0x2A), rec.Field(0).Uint())
+	require.Equal(t, uint64(12345), rec.Field(2).Uint())
+	require.InDelta(t, 3.14, rec.Field(3).Float(), 0.001)
+	require.Equal(t, "can0", rec.Field(4).String())
+	payload, ok := rec.Lookup("payload")
+	require.True(t, ok)
+	require.Equal(t, typ.SequenceType, payload.Kind())
+	require.Equal(t, 8, payload.Len())
+	require.Equal(t, uint64(8), payload.Index(7).Uint())
+	crc, ok := rec.Lookup("crc")
+	require.True(t, ok)
+	require.Equal(t, int64(-2), crc.Int())
+	_, ok = rec.Lookup("missing")
+	require.False(t, ok)
+
+	require.Error(t, c.DecodeRecord(benchData[:20], &rec))
+}
+
+func TestDecodeRecordDoesNotAllocate(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	var rec Record
+	require.NoError(t, c.DecodeRecord(benchData, &rec))
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := c.DecodeRecord(benchData, &rec); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func BenchmarkDecode(b *testing.B) {
+	p, err := compilePlan(benchStruct, &ast.Scope{})
+	require.NoError(b, err)
+	c := &IDLConverter{tarStruct: benchStruct, plan: p}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Decode(benchData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeRecord(b *testing.B) {
+	p, err := compilePlan(benchStruct, &ast.Scope{})
+	require.NoError(b, err)
+	c := &IDLConverter{tarStruct: benchStruct, plan: p}
+	var rec Record
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		if err := c.DecodeRecord(benchData, &rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package converter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/yisaer/idl-parser/ast/typ"
+)
+
+// Value is a decoded field held without boxing. Strings alias the decoded
+// buffer; sequence elements and struct or bitset members are kept in a
+// slice that is reused by later decodes into the same Record.
+type Value struct {
+	in    *instruction
+	bits  uint64
+	raw   []byte
+	elems []Value
+}
+
+func (v Value) Kind() typ.FieldRefType {
+	return opcodeInfos[v.in.op].refType
+}
+
+func (v Value) Int() int64 {
+	return int64(v.bits)
+}
+
+func (v Value) Uint() uint64 {
+	return v.bits
+}
+
+func (v Value) Float() float64 {
+	if v.in != nil && v.in.op == opDouble {
+		return math.Float64frombits(v.bits)
+	}
+	return float64(math.Float32frombits(uint32(v.bits)))
+}
+
+func (v Value) Bool() bool {
+	return v.bits != 0
+}
+
+// Bytes returns the payload of a string value. It aliases the decoded data.
+func (v Value) Bytes() []byte {
+	return v.raw
+}
+
+// String returns the text of a string value or the enumerator name of an
+// enum value.
+func (v Value) String() string {
+	if v.in != nil && v.in.op == opEnum {
+		name, _ := v.in.enumName(uint32(v.bits))
+		return name
+	}
+	return string(v.raw)
+}
+
+func (v Value) Len() int {
+	return len(v.elems)
+}
+
+// Index returns the i-th element of a sequence or the i-th member of a
+// struct or bitset.
+func (v Value) Index(i int) Value {
+	return v.elems[i]
+}
+
+// Lookup returns the member of a struct or bitset value by name.
+func (v Value) Lookup(name string) (Value, bool) {
+	if v.in == nil || v.in.plan == nil {
+		return Value{}, false
+	}
+	i, ok := v.in.plan.index[name]
+	if !ok {
+		return Value{}, false
+	}
+	return v.elems[i], true
+}
+
+// Record receives the fields of one decoded struct. A Record may be reused
+// across calls to DecodeRecord; once its buffers have grown to fit the
+// payloads, decoding does not allocate.
+type Record struct {
+	plan *plan
+	root Value
+}
+
+func (r *Record) Len() int {
+	return len(r.root.elems)
+}
+
+func (r *Record) Name(i int) string {
+	return r.plan.instrs[i].name
+}
+
+func (r *Record) Field(i int) Value {
+	return r.root.elems[i]
+}
+
+func (r *Record) Lookup(name string) (Value, bool) {
+	i, ok := r.plan.index[name]
+	if !ok {
+		return Value{}, false
+	}
+	return r.root.elems[i], true
+}
+
+func (c *IDLConverter) DecodeRecord(data []byte, rec *Record) error {
+	p := c.plan
+	if p == nil {
+		return errors.New("converter is not initialized")
+	}
+	if err := c.checkPacked(); err != nil {
+		return err
+	}
+	rec.plan = p
+	remained, err := decodeStructValue(p, data, &rec.root, c.maxDepth())
+	if err != nil {
+		return err
+	}
+	return c.checkTrailing(len(remained))
+}
+
+func (v *Value) setFixed(in *instruction, b []byte) error {
+	v.in = in
+	switch in.op {
+	case opInt8:
+		v.bits = uint64(int8(b[0]))
+	case opOctet, opBoolean:
+		v.bits = uint64(b[0])
+	case opShort:
+		v.bits = uint64(int16(binary.BigEndian.Uint16(b)))
+	case opUnsignedShort:
+		v.bits = uint64(binary.BigEndian.Uint16(b))
+	case opLong:
+		v.bits = uint64(int32(binary.BigEndian.Uint32(b)))
+	case opUnsignedLong, opFloat:
+		v.bits = uint64(binary.BigEndian.Uint32(b))
+	case opLongLong, opUnsignedLongLong, opDouble:
+		v.bits = binary.BigEndian.Uint64(b)
+	case opEnum:
+		v.bits = uint64(binary.BigEndian.Uint32(b))
+		if _, err := in.enumName(uint32(v.bits)); err != nil {
+			return err
+		}
+	case opBitSet:
+		v.bits = readUint(b[:in.size])
+		v.resize(len(in.plan.instrs))
+		for i := range in.plan.instrs {
+			field := &in.plan.instrs[i]
+			v.elems[i] = Value{in: field, bits: field.bitFieldBits(v.bits)}
+		}
+	case opStruct:
+		v.resize(len(in.plan.instrs))
+		for i := range in.plan.instrs {
+			field := &in.plan.instrs[i]
+			if err := v.elems[i].setFixed(field, b[field.offset:]); err != nil {
+				return in.plan.fieldError(field, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Value) resize(n int) {
+	if cap(v.elems) < n {
+		v.elems = make([]Value, n)
+	}
+	v.elems = v.elems[:n]
+}
+
+// decodeValue decodes one value into v. depth is the number of struct levels
+// that may still be entered.
+func decodeValue(in *instruction, data []byte, v *Value, count int64, depth int) ([]byte, error) {
+	if in.size > 0 {
+		if len(data) < in.size {
+			return nil, fmt.Errorf("expect data len %v got len %v", in.size, len(data))
+		}
+		if err := v.setFixed(in, data); err != nil {
+			return nil, err
+		}
+		return data[in.size:], nil
+	}
+	v.in = in
+	if in.op == opStruct {
+		return decodeStructValue(in.plan, data, v, depth)
+	}
+	n, remained, err := in.readLength(data, count)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.checkBound(n); err != nil {
+		return nil, err
+	}
+	switch in.op {
+	case opString:
+		if int64(len(remained)) < n {
+			return nil, errors.New("data truncated, insufficient bytes for string")
+		}
+		v.raw = remained[:n]
+		return remained[n:], nil
+	case opSequence:
+		if n < 0 {
+			return decodeOpenSequence(in, remained, v, depth)
+		}
+		if err := checkListLen(in.elem, n, remained); err != nil {
+			return nil, err
+		}
+		v.resize(int(n))
+		for i := range v.elems {
+			remained, err = decodeValue(in.elem, remained, &v.elems[i], 0, depth)
+			if err != nil {
+				return nil, fmt.Errorf("parse sequence %v error:%v", in.elem.op, err.Error())
+			}
+		}
+		return remained, nil
+	}
+	return nil, fmt.Errorf("unsupported op:%v", in.op)
+}
+
+// decodeOpenSequence decodes variable-size elements until data is exhausted,
+// reusing the element slots of earlier decodes.
+func decodeOpenSequence(in *instruction, data []byte, v *Value, depth int) ([]byte, error) {
+	v.elems = v.elems[:0]
+	var err error
+	for len(data) > 0 {
+		if err := in.checkBound(int64(len(v.elems) + 1)); err != nil {
+			return nil, err
+		}
+		if len(v.elems) == cap(v.elems) {
+			v.elems = append(v.elems, Value{})
+		} else {
+			v.elems = v.elems[:len(v.elems)+1]
+		}
+		if data, err = decodeValue(in.elem, data, &v.elems[len(v.elems)-1], 0, depth); err != nil {
+			return nil, fmt.Errorf("parse sequence %v error:%v", in.elem.op, err.Error())
+		}
+	}
+	return data, nil
+}
+
+func decodeStructValue(p *plan, data []byte, v *Value, depth int) ([]byte, error) {
+	if depth <= 0 {
+		return nil, p.depthError()
+	}
+	depth--
+	if err := p.checkPrefix(data); err != nil {
+		return nil, err
+	}
+	v.resize(len(p.instrs))
+	var err error
+	remained := data[p.prefix:]
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		if in.inPrefix {
+			if err := v.elems[i].setFixed(in, data[in.offset:]); err != nil {
+				return nil, p.fieldError(in, err)
+			}
+			continue
+		}
+		var count int64
+		if in.length.kind == lengthFrom {
+			count = v.elems[in.length.from].count()
+		}
+		if remained, err = decodeValue(in, remained, &v.elems[i], count, depth); err != nil {
+			return nil, p.fieldError(in, err)
+		}
+	}
+	return remained, nil
+}
+
+// count returns the value of an integer field used as a length, or -1 when
+// it does not fit an int64.
+func (v Value) count() int64 {
+	if v.in.op == opUnsignedLongLong && v.bits > math.MaxInt64 {
+		return -1
+	}
+	return int64(v.bits)
+}
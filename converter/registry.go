@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+)
+
+// Registry serves converters for many target structs of one parsed schema.
+// The schema is parsed and verified once; every struct is indexed by its
+// fully scoped name, e.g. "spi::can::Frame". It is safe for concurrent use.
+type Registry struct {
+	// NumberMode, LengthPrefix, Strict, Encoding and MaxDepth are applied
+	// to converters created by Register.
+	NumberMode   NumberMode
+	LengthPrefix int
+	Strict       bool
+	Encoding     Encoding
+	MaxDepth     int
+
+	module     ast.Module
+	structs    map[string]registryEntry
+	mu         sync.RWMutex
+	converters map[string]*IDLConverter
+}
+
+type registryEntry struct {
+	st    struct_type.Struct
+	scope *ast.Scope
+}
+
+func NewRegistry(module ast.Module) (*Registry, error) {
+	global := ast.NewGlobalScope(module)
+	if err := verifyStruct(global); err != nil {
+		return nil, err
+	}
+	r := &Registry{
+		module:     module,
+		structs:    make(map[string]registryEntry),
+		converters: make(map[string]*IDLConverter),
+	}
+	r.index(global)
+	return r, nil
+}
+
+func NewRegistryFromString(schema string) (*Registry, error) {
+	module, err := ast.ParseSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(module)
+}
+
+func NewRegistryFromFile(path string) (*Registry, error) {
+	v, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistryFromString(string(v))
+}
+
+func (r *Registry) index(s *ast.Scope) {
+	for _, con := range s.Module().Content {
+		switch def := con.(type) {
+		case ast.Module:
+			r.index(s.Child(def))
+		case struct_type.Struct:
+			r.structs[s.Qualify(def.Name)] = registryEntry{st: def, scope: s}
+		}
+	}
+}
+
+func (r *Registry) Module() ast.Module {
+	return r.module
+}
+
+// Types returns the scoped names of every struct in the schema, sorted.
+func (r *Registry) Types() []string {
+	names := make([]string, 0, len(r.structs))
+	for name := range r.structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register binds schemaID to the struct named typeName and returns the
+// converter serving it.
+func (r *Registry) Register(schemaID, typeName string) (*IDLConverter, error) {
+	entry, ok := r.structs[strings.TrimPrefix(typeName, "::")]
+	if !ok {
+		return nil, fmt.Errorf("struct %v not found", typeName)
+	}
+	c := &IDLConverter{
+		SchemaID:     schemaID,
+		TypeName:     typeName,
+		NumberMode:   r.NumberMode,
+		LengthPrefix: r.LengthPrefix,
+		Strict:       r.Strict,
+		Encoding:     r.Encoding,
+		MaxDepth:     r.MaxDepth,
+		Module:       r.module,
+	}
+	if err := c.bindTarget(entry.st, entry.scope); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.converters[schemaID]; ok {
+		return nil, fmt.Errorf("schema id %v already registered", schemaID)
+	}
+	r.converters[schemaID] = c
+	return c, nil
+}
+
+func (r *Registry) Converter(schemaID string) (*IDLConverter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.converters[schemaID]
+	return c, ok
+}
+
+func (r *Registry) Decode(schemaID string, data []byte) (map[string]interface{}, error) {
+	c, ok := r.Converter(schemaID)
+	if !ok {
+		return nil, fmt.Errorf("schema id %v not registered", schemaID)
+	}
+	return c.Decode(data)
+}
@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const registrySchema = `module gateway {
+	module can {
+		struct Frame {
+			octet header;
+			sequence<octet> payload;
+		};
+		struct Status {
+			unsigned short code;
+		};
+	};
+	module lin {
+		struct Frame {
+			string source;
+		};
+	};
+}`
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistryFromString(registrySchema)
+	require.NoError(t, err)
+	require.Equal(t, []string{"gateway::can::Frame", "gateway::can::Status", "gateway::lin::Frame"}, r.Types())
+
+	can, err := r.Register("can-frame", "gateway::can::Frame")
+	require.NoError(t, err)
+	require.Equal(t, "can-frame", can.SchemaID)
+	_, err = r.Register("lin-frame", "::gateway::lin::Frame")
+	require.NoError(t, err)
+	_, err = r.Register("can-frame", "gateway::can::Status")
+	require.Error(t, err)
+	_, err = r.Register("missing", "gateway::can::Missing")
+	require.Error(t, err)
+
+	m, err := r.Decode("can-frame", []byte{1, 0, 0, 0, 1, 9})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"header": int64(1), "payload": []interface{}{int64(9)}}, m)
+	m, err = r.Decode("lin-frame", []byte{0, 0, 0, 1, 'x'})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"source": "x"}, m)
+	_, err = r.Decode("unknown", nil)
+	require.Error(t, err)
+
+	c, ok := r.Converter("can-frame")
+	require.True(t, ok)
+	require.Same(t, can, c)
+}
+
+func TestRegistryReopenedModule(t *testing.T) {
+	r, err := NewRegistryFromString(registrySchema + `
+module gateway { module can { struct Ack { Status status; }; }; };`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"gateway::can::Ack", "gateway::can::Frame", "gateway::can::Status", "gateway::lin::Frame"}, r.Types())
+	_, err = r.Register("ack", "gateway::can::Ack")
+	require.NoError(t, err)
+}
+
+func TestRegistryOptions(t *testing.T) {
+	r, err := NewRegistryFromString(registrySchema)
+	require.NoError(t, err)
+	r.NumberMode = NumberModeNative
+	r.Strict = true
+	_, err = r.Register("status", "gateway::can::Status")
+	require.NoError(t, err)
+
+	m, err := r.Decode("status", []byte{0, 7})
+	require.NoError(t, err)
+	require.Equal(t, uint16(7), m["code"])
+	_, err = r.Decode("status", []byte{0, 7, 0})
+	require.ErrorIs(t, err, ErrTrailingBytes)
+
+	r.Encoding = EncodingCDR
+	_, err = r.Register("status-cdr", "gateway::can::Status")
+	require.NoError(t, err)
+	m, err = r.Decode("status-cdr", []byte{0x00, 0x01, 0x00, 0x02, 0x07, 0x00, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, uint16(7), m["code"])
+
+	r.Encoding, r.MaxDepth = EncodingPacked, 1
+	_, err = r.Register("frame-shallow", "gateway::can::Frame")
+	require.NoError(t, err)
+	c, _ := r.Converter("frame-shallow")
+	require.Equal(t, 1, c.maxDepth())
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r, err := NewRegistryFromString(registrySchema)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := []string{"a", "b", "c", "d", "e", "f", "g", "h"}[i]
+			if _, err := r.Register(id, "gateway::can::Frame"); err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < 100; j++ {
+				if _, err := r.Decode(id, []byte{1, 0, 0, 0, 0}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
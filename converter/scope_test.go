@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const scopedSchema = `module spi {
+	enum State { IDLE, RUNNING, STOPPED };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<5> code;
+	};
+	struct Header {
+		octet version;
+		State state;
+	};
+	module can {
+		struct Header {
+			unsigned short id;
+		};
+		struct Frame {
+			Header local;
+			::spi::Header outer;
+			Flags flags;
+			sequence<State> history;
+		};
+	};
+	struct Packet {
+		can::Frame frame;
+		string note;
+	};
+}`
+
+var scopedFrameData = []byte{
+	0x01, 0x02,
+	0x03, 0x00, 0x00, 0x00, 0x01,
+	0xA5,
+	0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+}
+
+func TestScopedTypeResolution(t *testing.T) {
+	c, err := NewIDLConverterFromString(scopedSchema, "spi::can::Frame")
+	require.NoError(t, err)
+	m, err := c.Decode(scopedFrameData)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"local":   map[string]interface{}{"id": int64(0x0102)},
+		"outer":   map[string]interface{}{"version": int64(3), "state": "RUNNING"},
+		"flags":   map[string]interface{}{"level": int64(5), "code": int64(20)},
+		"history": []interface{}{"IDLE", "STOPPED"},
+	}, m)
+
+	c, err = NewIDLConverterFromString(scopedSchema, "spi::Packet")
+	require.NoError(t, err)
+	m, err = c.Decode(append(append([]byte{}, scopedFrameData...), 0, 0, 0, 1, 'x'))
+	require.NoError(t, err)
+	require.Equal(t, "x", m["note"])
+	require.Equal(t, map[string]interface{}{"id": int64(0x0102)}, m["frame"].(map[string]interface{})["local"])
+}
+
+func TestScopedNativeNumberMode(t *testing.T) {
+	c := &IDLConverter{Schema: scopedSchema, TypeName: "spi::can::Frame", NumberMode: NumberModeNative}
+	require.NoError(t, c.Init())
+	m, err := c.Decode(scopedFrameData)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": uint16(0x0102)}, m["local"])
+	require.Equal(t, map[string]interface{}{"level": uint8(5), "code": uint8(20)}, m["flags"])
+}
+
+func TestScopedDecodeRecord(t *testing.T) {
+	c, err := NewIDLConverterFromString(scopedSchema, "spi::can::Frame")
+	require.NoError(t, err)
+	var r Record
+	require.NoError(t, c.DecodeRecord(scopedFrameData, &r))
+
+	outer, ok := r.Lookup("outer")
+	require.True(t, ok)
+	state, ok := outer.Lookup("state")
+	require.True(t, ok)
+	require.Equal(t, "RUNNING", state.String())
+	flags, ok := r.Lookup("flags")
+	require.True(t, ok)
+	code, ok := flags.Lookup("code")
+	require.True(t, ok)
+	require.Equal(t, uint64(20), code.Uint())
+	history, ok := r.Lookup("history")
+	require.True(t, ok)
+	require.Equal(t, 2, history.Len())
+	require.Equal(t, "STOPPED", history.Index(1).String())
+}
+
+func TestScopedDecodeInto(t *testing.T) {
+	type header struct {
+		Version uint8
+		State   string
+	}
+	type flags struct {
+		Level uint8
+		Code  uint8
+	}
+	type frame struct {
+		Local struct {
+			ID uint16
+		}
+		Outer   header
+		Flags   flags
+		History []string
+	}
+	c, err := NewIDLConverterFromString(scopedSchema, "spi::can::Frame")
+	require.NoError(t, err)
+	var got frame
+	require.NoError(t, c.DecodeInto(scopedFrameData, &got))
+	require.Equal(t, uint16(0x0102), got.Local.ID)
+	require.Equal(t, header{Version: 3, State: "RUNNING"}, got.Outer)
+	require.Equal(t, flags{Level: 5, Code: 20}, got.Flags)
+	require.Equal(t, []string{"IDLE", "STOPPED"}, got.History)
+
+	var wrong struct {
+		Flags struct {
+			Code bool
+		}
+	}
+	require.Error(t, c.DecodeInto(scopedFrameData, &wrong))
+}
+
+func TestScopeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		errMsg string
+	}{
+		{
+			name:   "unresolved name",
+			schema: `module m { struct S { Missing x; }; }`,
+			errMsg: "type Missing not found",
+		},
+		{
+			name:   "inner scope not visible",
+			schema: `module m { module inner { struct T { octet a; }; }; struct S { T x; }; }`,
+			errMsg: "type T not found",
+		},
+		{
+			name:   "struct contains itself",
+			schema: `module m { struct S { octet a; S next; }; }`,
+			errMsg: "struct m::S contains itself",
+		},
+		{
+			name:   "invalid enum value",
+			schema: `module m { enum E { A }; struct S { E e; }; }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewIDLConverterFromString(tt.schema, "m::S")
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			_, err = c.Decode([]byte{0, 0, 0, 1})
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+var ErrRecordTooLarge = errors.New("record exceeds the maximum record size")
+
+const defaultMaxRecordSize = 16 << 20
+
+// Decoder reads back-to-back records of the converter's target struct from
+// an io.Reader. It only reads as many bytes as the schema says the current
+// record needs, so nothing past the record is pulled from the reader.
+type Decoder struct {
+	// MaxRecordSize bounds the bytes buffered for one record, guarding
+	// against corrupt length prefixes. Zero means 16 MiB; a negative value
+	// removes the limit.
+	MaxRecordSize int
+
+	r      io.Reader
+	c      *IDLConverter
+	buf    []byte
+	offset int64
+}
+
+func NewDecoder(r io.Reader, c *IDLConverter) *Decoder {
+	return &Decoder{r: r, c: c}
+}
+
+// Next decodes the next record and returns it together with the number of
+// bytes it occupied. It returns io.EOF when the stream ends on a record
+// boundary and io.ErrUnexpectedEOF when it ends inside a record. After an
+// error the unconsumed bytes stay buffered, so the caller may Discard some
+// of them to resynchronize and call Next again.
+func (d *Decoder) Next() (map[string]interface{}, int, error) {
+	p := d.c.plan
+	if p == nil {
+		return nil, 0, errors.New("converter is not initialized")
+	}
+	if err := d.c.checkPacked(); err != nil {
+		return nil, 0, err
+	}
+	if p.open() {
+		return nil, 0, fmt.Errorf("struct %v runs to the end of the data and cannot be read from a stream", p.name)
+	}
+	for {
+		// The frame takes one level more than decoding allows, so that a
+		// too deeply nested struct is inside it and reported as such.
+		n, need := p.frameLen(d.buf, d.c.maxDepth()+1)
+		if need == 0 {
+			m, _, err := decoder{numberMode: d.c.NumberMode, depth: d.c.maxDepth()}.decodeStruct(p, d.buf[:n])
+			if err != nil {
+				return nil, 0, err
+			}
+			d.consume(n)
+			return m, n, nil
+		}
+		if limit := d.maxRecordSize(); limit > 0 && need > limit {
+			return nil, 0, fmt.Errorf("%w: need %v bytes, limit %v", ErrRecordTooLarge, need, limit)
+		}
+		if err := d.fill(need); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+func (d *Decoder) maxRecordSize() int {
+	if d.MaxRecordSize == 0 {
+		return defaultMaxRecordSize
+	}
+	return d.MaxRecordSize
+}
+
+// Buffered returns the number of bytes read from the underlying reader but
+// not yet consumed.
+func (d *Decoder) Buffered() int {
+	return len(d.buf)
+}
+
+// Offset returns the number of bytes consumed from the stream so far,
+// including discarded ones.
+func (d *Decoder) Offset() int64 {
+	return d.offset
+}
+
+// Discard drops up to n buffered bytes and returns how many were dropped.
+func (d *Decoder) Discard(n int) int {
+	n = min(n, len(d.buf))
+	d.consume(n)
+	return n
+}
+
+func (d *Decoder) consume(n int) {
+	d.buf = d.buf[:copy(d.buf, d.buf[n:])]
+	d.offset += int64(n)
+}
+
+func (d *Decoder) fill(need int) error {
+	for len(d.buf) < need {
+		if len(d.buf) == cap(d.buf) {
+			// Grow with what the reader delivers rather than to need at
+			// once: without a limit, a corrupt length may ask for far more
+			// than the stream holds.
+			d.buf = slices.Grow(d.buf, min(need-len(d.buf), max(len(d.buf), 512)))
+		}
+		n, err := d.r.Read(d.buf[len(d.buf):min(cap(d.buf), need)])
+		d.buf = d.buf[:len(d.buf)+n]
+		switch {
+		case err == io.EOF && len(d.buf) >= need:
+			return nil
+		case err == io.EOF && len(d.buf) == 0:
+			return io.EOF
+		case err == io.EOF:
+			return io.ErrUnexpectedEOF
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// frameLen returns the length of the record at the start of data. When data
+// holds only part of the record it returns the total length needed to make
+// further progress instead. Structs nested deeper than depth end the walk
+// early, like invalid lengths.
+func (p *plan) frameLen(data []byte, depth int) (n int, need int) {
+	return p.advance(data, 0, depth)
+}
+
+func (p *plan) advance(data []byte, pos int, depth int) (int, int) {
+	if depth <= 0 {
+		return pos, 0
+	}
+	depth--
+	var starts []int
+	if p.counted {
+		starts = make([]int, len(p.instrs))
+	}
+	var need int
+	for i := range p.instrs {
+		in := &p.instrs[i]
+		var count int64
+		if in.length.kind == lengthFrom {
+			var v Value
+			if err := v.setFixed(&p.instrs[in.length.from], data[starts[in.length.from]:]); err == nil {
+				count = v.count()
+			}
+		}
+		if starts != nil {
+			starts[i] = pos
+		}
+		if pos, need = in.advance(data, pos, count, depth); need > 0 {
+			return 0, need
+		}
+	}
+	return pos, 0
+}
+
+// advance skips one value. A length that cannot be valid stops the walk
+// early; decoding the frame then reports the error.
+func (in *instruction) advance(data []byte, pos int, count int64, depth int) (int, int) {
+	if in.size > 0 {
+		return advanceBy(data, pos, int64(in.size))
+	}
+	if in.op == opStruct {
+		return in.plan.advance(data, pos, depth)
+	}
+	n := count
+	switch in.length.kind {
+	case lengthFixed:
+		n = int64(in.length.count)
+	case lengthPrefix:
+		size := in.length.prefix
+		if pos+size > len(data) {
+			return 0, pos + size
+		}
+		n = int64(readUint(data[pos : pos+size]))
+		pos += size
+	}
+	if n < 0 || in.checkBound(n) != nil {
+		return pos, 0
+	}
+	switch in.op {
+	case opString:
+		return advanceBy(data, pos, n)
+	case opSequence:
+		if in.elem.size > 0 {
+			if n > math.MaxInt/int64(in.elem.size) {
+				return pos, 0
+			}
+			return advanceBy(data, pos, n*int64(in.elem.size))
+		}
+		// Each element takes at least one byte, as checkListLen requires, so
+		// a count past the buffered bytes asks for more before looping.
+		size := int64(max(in.elem.minSize(), 1))
+		if n > int64(len(data)-pos)/size {
+			if n > math.MaxInt/size {
+				return pos, 0
+			}
+			return advanceBy(data, pos, n*size)
+		}
+		var need int
+		for i := int64(0); i < n; i++ {
+			if pos, need = in.elem.advance(data, pos, 0, depth); need > 0 {
+				return 0, need
+			}
+		}
+	}
+	return pos, 0
+}
+
+// advanceBy skips n bytes. A length past the largest int cannot be valid
+// and stops the walk early.
+func advanceBy(data []byte, pos int, n int64) (int, int) {
+	if n > int64(len(data)-pos) {
+		if n > int64(math.MaxInt-pos) {
+			return pos, 0
+		}
+		return 0, pos + int(n)
+	}
+	return pos + int(n), 0
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func TestDecoderNext(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	stream := bytes.Repeat(benchData, 3)
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)), c)
+	for i := 0; i < 3; i++ {
+		m, n, err := d.Next()
+		require.NoError(t, err)
+		require.Equal(t, len(benchData), n)
+		require.Equal(t, "can0", m["source"])
+		require.Zero(t, d.Buffered())
+	}
+	_, _, err := d.Next()
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, int64(len(stream)), d.Offset())
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	c := newTestConverter(t, benchStruct)
+	stream := append(append([]byte{}, benchData...), benchData[:20]...)
+	d := NewDecoder(bytes.NewReader(stream), c)
+	_, _, err := d.Next()
+	require.NoError(t, err)
+	_, _, err = d.Next()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	require.Equal(t, 20, d.Buffered())
+}
+
+func TestDecoderHugeLength(t *testing.T) {
+	c := &IDLConverter{Schema: `module m { struct S { string s; }; }`, TypeName: "m::S", LengthPrefix: 8}
+	require.NoError(t, c.Init())
+	stream := []byte{0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}
+	d := NewDecoder(bytes.NewReader(stream), c)
+	_, _, err := d.Next()
+	require.Error(t, err)
+	require.Equal(t, 8, d.Buffered())
+}
+
+func TestDecoderDefaultMaxRecordSize(t *testing.T) {
+	c := newTestConverter(t, struct_type.Struct{
+		Name:   "Msg",
+		Fields: []struct_type.Field{{Name: "text", Type: typeref.NewStringType()}},
+	})
+	stream := []byte{0x01, 0x00, 0x00, 0x00, 'x'}
+	_, _, err := NewDecoder(bytes.NewReader(stream), c).Next()
+	require.ErrorIs(t, err, ErrRecordTooLarge)
+
+	d := NewDecoder(bytes.NewReader(stream), c)
+	d.MaxRecordSize = -1
+	_, _, err = d.Next()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecoderHugeCount(t *testing.T) {
+	// Empty elements take no bytes, so only the count bounds the walk.
+	for _, prefix := range []int{4, 8} {
+		c := &IDLConverter{Schema: `module m { struct E {}; struct S { sequence<E> es; }; }`, TypeName: "m::S", LengthPrefix: prefix}
+		require.NoError(t, c.Init())
+		stream := bytes.Repeat([]byte{0x7F}, prefix)
+		_, _, err := NewDecoder(bytes.NewReader(stream), c).Next()
+		require.ErrorIs(t, err, ErrRecordTooLarge)
+
+		d := NewDecoder(bytes.NewReader(stream), c)
+		d.MaxRecordSize = -1
+		_, _, err = d.Next()
+		require.Error(t, err)
+	}
+}
+
+func TestDecoderResynchronize(t *testing.T) {
+	c := newTestConverter(t, struct_type.Struct{
+		Name: "Msg",
+		Fields: []struct_type.Field{
+			{Name: "tag", Type: typeref.NewOctetType()},
+			{Name: "text", Type: typeref.NewStringType()},
+		},
+	})
+	stream := []byte{
+		1, 0xFF, 0xFF, 0xFF, 0xFF,
+		2, 0, 0, 0, 2, 'h', 'i',
+	}
+	d := NewDecoder(bytes.NewReader(stream), c)
+	d.MaxRecordSize = 64
+	_, n, err := d.Next()
+	require.True(t, errors.Is(err, ErrRecordTooLarge))
+	require.Zero(t, n)
+	require.Equal(t, 5, d.Buffered())
+
+	require.Equal(t, 5, d.Discard(5))
+	m, n, err := d.Next()
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+	require.Equal(t, map[string]interface{}{"tag": int64(2), "text": "hi"}, m)
+	require.Equal(t, int64(12), d.Offset())
+}
+
+func TestFrameLen(t *testing.T) {
+	p, err := compilePlan(benchStruct, &ast.Scope{})
+	require.NoError(t, err)
+	n, need := p.frameLen(benchData, defaultMaxDepth)
+	require.Equal(t, len(benchData), n)
+	require.Zero(t, need)
+
+	_, need = p.frameLen(benchData[:3], defaultMaxDepth)
+	require.Equal(t, 11, need)
+	_, need = p.frameLen(benchData[:17], defaultMaxDepth)
+	require.Equal(t, 19, need)
+	_, need = p.frameLen(benchData[:19], defaultMaxDepth)
+	require.Equal(t, 23, need)
+}
+
+func TestDecoderLengthFrom(t *testing.T) {
+	c, err := NewIDLConverterFromString(`module m {
+		struct Msg {
+			octet n;
+			@length_from(n) sequence<short> values;
+			@length_prefix(1) string tag;
+		};
+	}`, "m::Msg")
+	require.NoError(t, err)
+	record := []byte{2, 0, 1, 0, 2, 1, 'a'}
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(bytes.Repeat(record, 2))), c)
+	for i := 0; i < 2; i++ {
+		m, n, err := d.Next()
+		require.NoError(t, err)
+		require.Equal(t, len(record), n)
+		require.Equal(t, "a", m["tag"])
+	}
+
+	c, err = NewIDLConverterFromString(lengthSchema, "m::Frame")
+	require.NoError(t, err)
+	_, _, err = NewDecoder(bytes.NewReader(lengthData), c).Next()
+	require.Error(t, err)
+}
@@ -2,10 +2,13 @@ module github.com/yisaer/idl-parser
 
 go 1.24.1
 
+require (
+	github.com/oleiade/gomme v0.0.0-20231216113819-c8967c191356
+	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/oleiade/gomme v0.0.0-20231216113819-c8967c191356 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,512 @@
+// Package gogen generates Go types from a parsed IDL module, together with
+// MarshalIDL/UnmarshalIDL methods that use the converter's wire format.
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+type Config struct {
+	Package string
+	// Source is the schema file name recorded in the generated header.
+	Source string
+}
+
+type generator struct {
+	buf bytes.Buffer
+	// names maps the scoped name of each generated definition to its Go
+	// type name.
+	names map[string]string
+	defs  []definition
+	tmp   int
+}
+
+// definition is a struct, bitset or enum together with the scope it is
+// declared in.
+type definition struct {
+	con   ast.ModuleContent
+	scope *ast.Scope
+}
+
+func Generate(module ast.Module, cfg Config) ([]byte, error) {
+	if cfg.Package == "" {
+		return nil, fmt.Errorf("package name is required")
+	}
+	g := &generator{names: make(map[string]string)}
+	g.collect(ast.NewGlobalScope(module))
+	if err := g.assignNames(); err != nil {
+		return nil, err
+	}
+	for _, def := range g.defs {
+		var err error
+		switch d := def.con.(type) {
+		case struct_type.Struct:
+			err = g.genStruct(d, def.scope)
+		case bitset.BitSet:
+			err = g.genBitSet(d, def.scope)
+		case enum_type.Enum:
+			g.genEnum(d, def.scope)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	body := g.buf.String()
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by idlgen. DO NOT EDIT.\n")
+	if cfg.Source != "" {
+		fmt.Fprintf(&out, "// source: %s\n", cfg.Source)
+	}
+	fmt.Fprintf(&out, "\npackage %s\n\n", cfg.Package)
+	var imports []string
+	if strings.Contains(body, "fmt.") {
+		imports = append(imports, `"fmt"`)
+	}
+	if strings.Contains(body, "wire.") {
+		if len(imports) > 0 {
+			imports = append(imports, "")
+		}
+		imports = append(imports, `"github.com/yisaer/idl-parser/wire"`)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&out, "import (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	out.WriteString(body)
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code error:%v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) collect(s *ast.Scope) {
+	for _, con := range s.Module().Content {
+		switch def := con.(type) {
+		case ast.Module:
+			g.collect(s.Child(def))
+		case struct_type.Struct, bitset.BitSet, enum_type.Enum:
+			g.defs = append(g.defs, definition{con: def, scope: s})
+		}
+	}
+}
+
+// assignNames names each Go type after its definition, or after its scoped
+// name joined by underscores when definitions in several modules share the
+// name, e.g. a::X becomes A_X.
+func (g *generator) assignNames() error {
+	count := make(map[string]int)
+	for _, def := range g.defs {
+		count[exported(def.con.GetName())]++
+	}
+	taken := make(map[string]string)
+	for _, def := range g.defs {
+		qualified := def.scope.Qualify(def.con.GetName())
+		name := exported(def.con.GetName())
+		if count[name] > 1 {
+			parts := strings.Split(qualified, "::")
+			for i := range parts {
+				parts[i] = exported(parts[i])
+			}
+			name = strings.Join(parts, "_")
+		}
+		if prev, ok := taken[name]; ok {
+			return fmt.Errorf("%v and %v both map to go type %v", prev, qualified, name)
+		}
+		taken[name] = qualified
+		g.names[qualified] = name
+	}
+	return nil
+}
+
+// resolve looks up the definition a type name used in s refers to and
+// returns it with the scope it is declared in.
+func (g *generator) resolve(t typeref.TypeRef, s *ast.Scope) (ast.ModuleContent, *ast.Scope, error) {
+	def, owner, err := s.Resolve(t.TypeName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("undefined type %v", t.TypeName())
+	}
+	return def, owner, nil
+}
+
+// typedef returns the typedef t refers to, if any.
+func (g *generator) typedef(t typeref.TypeRef, s *ast.Scope) (typedef_type.Typedef, *ast.Scope, bool) {
+	def, owner, err := g.resolve(t, s)
+	if err != nil {
+		return typedef_type.Typedef{}, nil, false
+	}
+	td, ok := def.(typedef_type.Typedef)
+	return td, owner, ok
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) nextTmp(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+var primitives = map[typ.FieldRefType]struct {
+	goType string
+	wire   string
+}{
+	typ.Int8Type:             {"int8", "Int8"},
+	typ.OctetType:            {"uint8", "Uint8"},
+	typ.ShortType:            {"int16", "Int16"},
+	typ.UnsignedShortType:    {"uint16", "Uint16"},
+	typ.LongType:             {"int32", "Int32"},
+	typ.UnsignedLongType:     {"uint32", "Uint32"},
+	typ.LongLongType:         {"int64", "Int64"},
+	typ.UnsignedLongLongType: {"uint64", "Uint64"},
+	typ.BooleanType:          {"bool", "Bool"},
+	typ.FloatType:            {"float32", "Float32"},
+	typ.DoubleType:           {"float64", "Float64"},
+	typ.StringType:           {"string", "String"},
+}
+
+func (g *generator) goType(t typeref.TypeRef, s *ast.Scope) (string, error) {
+	if p, ok := primitives[t.TypeRefType()]; ok {
+		return p.goType, nil
+	}
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		inner, err := g.goType(t.(typeref.Sequence).InnerType, s)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + inner, nil
+	case typ.SelfDefinedTypeType:
+		def, owner, err := g.resolve(t, s)
+		if err != nil {
+			return "", err
+		}
+		if td, ok := def.(typedef_type.Typedef); ok {
+			inner, err := g.goType(td.Aliased, owner)
+			if err != nil {
+				return "", err
+			}
+			for i := len(td.Dims) - 1; i >= 0; i-- {
+				inner = fmt.Sprintf("[%d]%s", td.Dims[i], inner)
+			}
+			return inner, nil
+		}
+		name, ok := g.names[owner.Qualify(def.GetName())]
+		if !ok {
+			return "", fmt.Errorf("type %v is not supported", t.TypeName())
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("unsupported type %v", t.TypeName())
+}
+
+func (g *generator) genStruct(st struct_type.Struct, s *ast.Scope) error {
+	name := g.names[s.Qualify(st.Name)]
+	// Inherited fields come first, each resolved in the scope of the struct
+	// declaring it.
+	fields, err := s.Members(st)
+	if err != nil {
+		return err
+	}
+	g.printf("\ntype %s struct {\n", name)
+	for _, field := range fields {
+		goType, err := g.goType(field.Type, field.Scope)
+		if err != nil {
+			return fmt.Errorf("struct %v field %v: %v", st.Name, field.Name, err)
+		}
+		g.printf("%s %s `idl:%q`\n", exported(field.Name), goType, field.Name)
+	}
+	g.printf("}\n")
+
+	g.printf("\nfunc (m *%s) MarshalIDL() ([]byte, error) {\nreturn m.appendIDL(nil)\n}\n", name)
+	g.printf(`
+func (m *%s) UnmarshalIDL(data []byte) error {
+	remained, err := m.readIDL(data)
+	if err != nil {
+		return err
+	}
+	if len(remained) > 0 {
+		return fmt.Errorf("%s: %%v trailing bytes", len(remained))
+	}
+	return nil
+}
+`, name, st.Name)
+
+	prefixes := make([]int, len(fields))
+	for i, field := range fields {
+		size, err := lengthPrefix(field.Field)
+		if err != nil {
+			return fmt.Errorf("struct %v field %v: %v", st.Name, field.Name, err)
+		}
+		prefixes[i] = size
+	}
+
+	g.printf("\nfunc (m *%s) appendIDL(b []byte) ([]byte, error) {\n", name)
+	start := g.buf.Len()
+	for i, field := range fields {
+		g.genAppend("m."+exported(field.Name), field.Type, prefixes[i], field.Scope)
+	}
+	// Lengths and nested values may fail to encode; declare err only when
+	// the body uses it.
+	if body := g.buf.String()[start:]; strings.Contains(body, "err =") {
+		g.buf.Truncate(start)
+		g.printf("var err error\n%s", body)
+	}
+	g.printf("return b, nil\n}\n")
+
+	g.printf("\nfunc (m *%s) readIDL(data []byte) ([]byte, error) {\n", name)
+	if len(fields) > 0 {
+		g.printf("var err error\n")
+	}
+	for i, field := range fields {
+		if err := g.genRead("m."+exported(field.Name), field.Type, prefixes[i], field.Scope); err != nil {
+			return err
+		}
+	}
+	g.printf("return data, nil\n}\n")
+	return nil
+}
+
+// lengthPrefix returns the length prefix size of a field. Lengths taken from
+// another field or running to the end of the data are not supported.
+func lengthPrefix(field struct_type.Field) (int, error) {
+	if anno, ok := field.Annotations.Get("length_prefix"); ok {
+		size, err := strconv.Atoi(anno.Values["value"])
+		if err != nil || size != 1 && size != 2 && size != 4 && size != 8 {
+			return 0, fmt.Errorf("@length_prefix expects 1, 2, 4 or 8, got %q", anno.Values["value"])
+		}
+		return size, nil
+	}
+	for _, name := range []string{"length_from", "length_to_end"} {
+		if _, ok := field.Annotations.Get(name); ok {
+			return 0, fmt.Errorf("@%v is not supported by gogen", name)
+		}
+	}
+	return 4, nil
+}
+
+// genBound checks the length of expr against the bound of a string or
+// sequence, as the converter does.
+func (g *generator) genBound(expr string, t typeref.TypeRef) {
+	switch v := t.(type) {
+	case typeref.StringType:
+		if v.Bound > 0 {
+			g.printf("if len(%s) > %d {\nreturn nil, fmt.Errorf(\"string of %%v bytes exceeds bound %d\", len(%s))\n}\n", expr, v.Bound, v.Bound, expr)
+		}
+	case typeref.Sequence:
+		if v.Bound > 0 {
+			g.printf("if len(%s) > %d {\nreturn nil, fmt.Errorf(\"sequence of %%v elements exceeds bound %d\", len(%s))\n}\n", expr, v.Bound, v.Bound, expr)
+		}
+	}
+}
+
+func (g *generator) genAppend(expr string, t typeref.TypeRef, prefix int, s *ast.Scope) {
+	g.genBound(expr, t)
+	if t.TypeRefType() == typ.StringType && prefix != 4 {
+		g.printf("if b, err = wire.AppendStringN(b, %s, %d); err != nil {\nreturn nil, err\n}\n", expr, prefix)
+		return
+	}
+	if t.TypeRefType() == typ.StringType {
+		g.printf("if b, err = wire.AppendString(b, %s); err != nil {\nreturn nil, err\n}\n", expr)
+		return
+	}
+	if p, ok := primitives[t.TypeRefType()]; ok {
+		g.printf("b = wire.Append%s(b, %s)\n", p.wire, expr)
+		return
+	}
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		i := g.nextTmp("i")
+		if prefix != 4 {
+			g.printf("if b, err = wire.AppendLengthN(b, len(%s), %d); err != nil {\nreturn nil, err\n}\n", expr, prefix)
+		} else {
+			g.printf("if b, err = wire.AppendLength(b, len(%s)); err != nil {\nreturn nil, err\n}\n", expr)
+		}
+		g.printf("for %s := range %s {\n", i, expr)
+		g.genAppend(fmt.Sprintf("%s[%s]", expr, i), t.(typeref.Sequence).InnerType, 4, s)
+		g.printf("}\n")
+	case typ.SelfDefinedTypeType:
+		if td, owner, ok := g.typedef(t, s); ok {
+			g.genAppendArray(expr, td.Aliased, td.Dims, prefix, owner)
+			return
+		}
+		g.printf("if b, err = %s.appendIDL(b); err != nil {\nreturn nil, err\n}\n", expr)
+	}
+}
+
+// genAppendArray appends the elements of an array, which has no length on
+// the wire.
+func (g *generator) genAppendArray(expr string, t typeref.TypeRef, dims []int, prefix int, s *ast.Scope) {
+	if len(dims) == 0 {
+		g.genAppend(expr, t, prefix, s)
+		return
+	}
+	i := g.nextTmp("i")
+	g.printf("for %s := range %s {\n", i, expr)
+	g.genAppendArray(fmt.Sprintf("%s[%s]", expr, i), t, dims[1:], 4, s)
+	g.printf("}\n")
+}
+
+func (g *generator) genRead(expr string, t typeref.TypeRef, prefix int, s *ast.Scope) error {
+	if t.TypeRefType() == typ.StringType && prefix != 4 {
+		g.printf("if %s, data, err = wire.ReadStringN(data, %d); err != nil {\nreturn nil, err\n}\n", expr, prefix)
+		g.genBound(expr, t)
+		return nil
+	}
+	if p, ok := primitives[t.TypeRefType()]; ok {
+		g.printf("if %s, data, err = wire.Read%s(data); err != nil {\nreturn nil, err\n}\n", expr, p.wire)
+		g.genBound(expr, t)
+		return nil
+	}
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		goType, err := g.goType(t, s)
+		if err != nil {
+			return err
+		}
+		n, i := g.nextTmp("n"), g.nextTmp("i")
+		g.printf("var %s int\n", n)
+		if prefix != 4 {
+			g.printf("if %s, data, err = wire.ReadLengthN(data, %d); err != nil {\nreturn nil, err\n}\n", n, prefix)
+		} else {
+			g.printf("if %s, data, err = wire.ReadLength(data); err != nil {\nreturn nil, err\n}\n", n)
+		}
+		if bound := t.(typeref.Sequence).Bound; bound > 0 {
+			g.printf("if %s > %d {\nreturn nil, fmt.Errorf(\"sequence of %%v elements exceeds bound %d\", %s)\n}\n", n, bound, bound, n)
+		}
+		g.printf("%s = make(%s, %s)\n", expr, goType, n)
+		g.printf("for %s := range %s {\n", i, expr)
+		if err := g.genRead(fmt.Sprintf("%s[%s]", expr, i), t.(typeref.Sequence).InnerType, 4, s); err != nil {
+			return err
+		}
+		g.printf("}\n")
+	case typ.SelfDefinedTypeType:
+		if td, owner, ok := g.typedef(t, s); ok {
+			return g.genReadArray(expr, td.Aliased, td.Dims, prefix, owner)
+		}
+		g.printf("if data, err = %s.readIDL(data); err != nil {\nreturn nil, err\n}\n", expr)
+	}
+	return nil
+}
+
+func (g *generator) genReadArray(expr string, t typeref.TypeRef, dims []int, prefix int, s *ast.Scope) error {
+	if len(dims) == 0 {
+		return g.genRead(expr, t, prefix, s)
+	}
+	i := g.nextTmp("i")
+	g.printf("for %s := range %s {\n", i, expr)
+	if err := g.genReadArray(fmt.Sprintf("%s[%s]", expr, i), t, dims[1:], 4, s); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+func bitSetStorage(width int) (goType, wireName string) {
+	switch {
+	case width <= 8:
+		return "uint8", "Uint8"
+	case width <= 16:
+		return "uint16", "Uint16"
+	case width <= 32:
+		return "uint32", "Uint32"
+	}
+	return "uint64", "Uint64"
+}
+
+func (g *generator) genBitSet(bs bitset.BitSet, s *ast.Scope) error {
+	name := g.names[s.Qualify(bs.Name)]
+	total := 0
+	for _, field := range bs.Fields {
+		if field.Type.Width == 0 {
+			return fmt.Errorf("bitset %v field %v has zero width", bs.Name, field.Name)
+		}
+		total += int(field.Type.Width)
+	}
+	if total == 0 || total > 64 {
+		return fmt.Errorf("bitset %v has total width %v, expect 1 to 64", bs.Name, total)
+	}
+	storage, wireName := bitSetStorage(total)
+	g.printf("\n// %s packs its bitfields into a %s, first field in the least significant bits.\n", name, storage)
+	g.printf("type %s %s\n", name, storage)
+	shift := 0
+	for _, field := range bs.Fields {
+		width := int(field.Type.Width)
+		fieldType, _ := bitSetStorage(width)
+		mask := fmt.Sprintf("%#x", uint64(1)<<width-1)
+		accessor := exported(field.Name)
+		g.printf("\nfunc (b %s) %s() %s {\nreturn %s(b>>%d) & %s\n}\n", name, accessor, fieldType, fieldType, shift, mask)
+		g.printf("\nfunc (b *%s) Set%s(v %s) {\n*b = *b&^(%s<<%d) | %s(v&%s)<<%d\n}\n",
+			name, accessor, fieldType, mask, shift, name, mask, shift)
+		shift += width
+	}
+	g.printf("\nfunc (b *%s) appendIDL(buf []byte) ([]byte, error) {\nreturn wire.Append%s(buf, %s(*b)), nil\n}\n", name, wireName, storage)
+	g.printf(`
+func (b *%s) readIDL(data []byte) ([]byte, error) {
+	v, remained, err := wire.Read%s(data)
+	if err != nil {
+		return nil, err
+	}
+	*b = %s(v)
+	return remained, nil
+}
+`, name, wireName, name)
+	return nil
+}
+
+func (g *generator) genEnum(e enum_type.Enum, s *ast.Scope) {
+	name := g.names[s.Qualify(e.Name)]
+	g.printf("\ntype %s uint32\n\nconst (\n", name)
+	for i, member := range e.Members {
+		if i == 0 {
+			g.printf("%s%s %s = iota\n", name, exported(member), name)
+			continue
+		}
+		g.printf("%s%s\n", name, exported(member))
+	}
+	g.printf(")\n")
+
+	g.printf("\nfunc (e %s) String() string {\nswitch e {\n", name)
+	for _, member := range e.Members {
+		g.printf("case %s%s:\nreturn %q\n", name, exported(member), member)
+	}
+	g.printf("}\nreturn fmt.Sprintf(\"%s(%%d)\", uint32(e))\n}\n", e.Name)
+
+	g.printf("\nfunc (e *%s) appendIDL(b []byte) ([]byte, error) {\nreturn wire.AppendUint32(b, uint32(*e)), nil\n}\n", name)
+	g.printf(`
+func (e *%s) readIDL(data []byte) ([]byte, error) {
+	v, remained, err := wire.ReadUint32(data)
+	if err != nil {
+		return nil, err
+	}
+	if v >= %d {
+		return nil, fmt.Errorf("invalid %s value %%v", v)
+	}
+	*e = %s(v)
+	return remained, nil
+}
+`, name, len(e.Members), e.Name, name)
+}
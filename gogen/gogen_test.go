@@ -0,0 +1,151 @@
+package gogen
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+func TestGenerateMatchesExample(t *testing.T) {
+	schema, err := os.ReadFile("internal/example/spi.idl")
+	require.NoError(t, err)
+	res := ast.Parse(string(schema))
+	require.Nil(t, res.Err)
+
+	got, err := Generate(res.Output, Config{Package: "example", Source: "spi.idl"})
+	require.NoError(t, err)
+	expected, err := os.ReadFile("internal/example/spi_idl.go")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(got), "run go generate ./gogen/... to refresh the example")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		cfg   Config
+	}{
+		{
+			name:  "missing package",
+			input: `module m { struct A { octet a; }; }`,
+			cfg:   Config{},
+		},
+		{
+			name:  "undefined type",
+			input: `module m { struct A { Missing a; }; }`,
+			cfg:   Config{Package: "m"},
+		},
+		{
+			name:  "bitset wider than 64 bits",
+			input: `module m { bitset B { bitfield<40> a; bitfield<40> b; }; }`,
+			cfg:   Config{Package: "m"},
+		},
+		{
+			name:  "length from another field",
+			input: `module m { struct A { octet n; @length_from(n) sequence<octet> a; }; }`,
+			cfg:   Config{Package: "m"},
+		},
+		{
+			name:  "inheritance cycle",
+			input: `module m { struct A : B { octet a; }; struct B : A { octet b; }; }`,
+			cfg:   Config{Package: "m"},
+		},
+		{
+			name:  "go name collision",
+			input: `module m { struct x { octet a; }; struct X { octet b; }; }`,
+			cfg:   Config{Package: "m"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ast.Parse(tt.input)
+			require.Nil(t, res.Err)
+			_, err := Generate(res.Output, tt.cfg)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestGenerateInheritance(t *testing.T) {
+	res := ast.Parse(`module m {
+	struct Base { octet kind; };
+	struct Derived : Base { short value; };
+}`)
+	require.Nil(t, res.Err)
+	got, err := Generate(res.Output, Config{Package: "m"})
+	require.NoError(t, err)
+	src := string(got)
+	require.Contains(t, src, "type Derived struct {\n\tKind  uint8 `idl:\"kind\"`\n\tValue int16 `idl:\"value\"`\n}")
+	// Base fields come first on the wire too.
+	require.Contains(t, src, "func (m *Derived) appendIDL(b []byte) ([]byte, error) {\n\tb = wire.AppendUint8(b, m.Kind)\n\tb = wire.AppendInt16(b, m.Value)\n")
+	require.Contains(t, src, "func (m *Derived) readIDL(data []byte) ([]byte, error) {\n\tvar err error\n\tif m.Kind, data, err = wire.ReadUint8(data); err != nil {")
+}
+
+func TestGenerateScopedNames(t *testing.T) {
+	res := ast.Parse(`module spi {
+	module can {
+		struct Id { octet bid; };
+		struct X { octet a; };
+	};
+	module lin {
+		typedef short Code;
+		struct X { Code b; };
+	};
+	struct Frame { can::Id id; can::X cx; lin::X lx; };
+}`)
+	require.Nil(t, res.Err)
+	got, err := Generate(res.Output, Config{Package: "m"})
+	require.NoError(t, err)
+	src := string(got)
+	require.Contains(t, src, "type Spi_Can_X struct {\n\tA uint8 `idl:\"a\"`\n}")
+	require.Contains(t, src, "type Spi_Lin_X struct {\n\tB int16 `idl:\"b\"`\n}")
+	require.Contains(t, src, "type Frame struct {\n\tId Id        `idl:\"id\"`\n\tCx Spi_Can_X `idl:\"cx\"`\n\tLx Spi_Lin_X `idl:\"lx\"`\n}")
+}
+
+func TestGenerateBounds(t *testing.T) {
+	res := ast.Parse(`module m {
+	struct S {
+		@length_prefix(1) string<4> tag;
+		sequence<long, 2> values;
+	};
+}`)
+	require.Nil(t, res.Err)
+	got, err := Generate(res.Output, Config{Package: "m"})
+	require.NoError(t, err)
+	src := string(got)
+	tagCheck := "if len(m.Tag) > 4 {\n\t\treturn nil, fmt.Errorf(\"string of %v bytes exceeds bound 4\", len(m.Tag))\n\t}\n"
+	valuesCheck := "if len(m.Values) > 2 {\n\t\treturn nil, fmt.Errorf(\"sequence of %v elements exceeds bound 2\", len(m.Values))\n\t}\n"
+	require.Contains(t, src, "\tvar err error\n\t"+tagCheck+"\tif b, err = wire.AppendStringN(b, m.Tag, 1); err != nil {")
+	require.Contains(t, src, valuesCheck+"\tif b, err = wire.AppendLength(b, len(m.Values)); err != nil {")
+	require.Contains(t, src, "if m.Tag, data, err = wire.ReadStringN(data, 1); err != nil {\n\t\treturn nil, err\n\t}\n\t"+tagCheck)
+}
+
+func TestGenerateTypedefArrays(t *testing.T) {
+	res := ast.Parse(`module m {
+	typedef double double__9[9];
+	typedef octet grid[2][3];
+	module Pose_Constants {
+		const int8 LEVEL = -1;
+	};
+	struct Pose {
+		int8 level;
+		double__9 covariance;
+		grid cells;
+		string<8> frame;
+	};
+}`)
+	require.Nil(t, res.Err)
+	got, err := Generate(res.Output, Config{Package: "m"})
+	require.NoError(t, err)
+	src := string(got)
+	require.Contains(t, src, "Level      int8        `idl:\"level\"`")
+	require.Contains(t, src, "Covariance [9]float64  `idl:\"covariance\"`")
+	require.Contains(t, src, "Cells      [2][3]uint8 `idl:\"cells\"`")
+	require.Contains(t, src, "b = wire.AppendFloat64(b, m.Covariance[i1])")
+	require.Contains(t, src, `return nil, fmt.Errorf("string of %v bytes exceeds bound 8", len(m.Frame))`)
+	require.NotContains(t, src, "LEVEL")
+	require.NotContains(t, src, "make(")
+}
@@ -0,0 +1,5 @@
+// Package example holds code generated from spi.idl and checks that it
+// round-trips through the converter's wire format.
+package example
+
+//go:generate go run github.com/yisaer/idl-parser/cmd/idlgen -schema spi.idl -out spi_idl.go
@@ -0,0 +1,129 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/converter"
+)
+
+func TestCANFrameWireFormat(t *testing.T) {
+	var id IdBits
+	id.SetBid(0x3)
+	id.SetCid(0xABC)
+	require.Equal(t, uint8(0x3), id.Bid())
+	require.Equal(t, uint16(0xABC), id.Cid())
+
+	frame := CANFrame{Header: 0x2A, Id: id, Payload: []byte{1, 2}}
+	data, err := frame.MarshalIDL()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x2A, 0xAB, 0xC3, 0, 0, 0, 2, 1, 2}, data)
+
+	var got CANFrame
+	require.NoError(t, got.UnmarshalIDL(data))
+	require.Equal(t, frame, got)
+
+	require.Error(t, got.UnmarshalIDL(append(data, 0)))
+	require.Error(t, got.UnmarshalIDL(data[:len(data)-1]))
+}
+
+func TestDerivedMatchesConverter(t *testing.T) {
+	frame := TimedFrame{Header: 7, Payload: []byte{1}, Stamp: 42}
+	data, err := frame.MarshalIDL()
+	require.NoError(t, err)
+
+	c, err := converter.NewIDLConverterFromFile("spi.idl", "spi::TimedFrame")
+	require.NoError(t, err)
+	expected, err := c.Encode(map[string]interface{}{
+		"header":  7,
+		"id":      map[string]interface{}{"bid": 0, "cid": 0},
+		"payload": []interface{}{1},
+		"stamp":   42,
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	var got TimedFrame
+	require.NoError(t, got.UnmarshalIDL(data))
+	require.Equal(t, frame, got)
+}
+
+func TestSPIRoundTrip(t *testing.T) {
+	spi := SPI{
+		Header:    0xFFFF,
+		Offset:    -3,
+		Count:     -1234567890,
+		Crc:       4294967295,
+		Seq:       -1,
+		Timestamp: 18446744073709551615,
+		Valid:     true,
+		Ratio:     -2.5,
+		Source:    "can0",
+		Status:    StatusFAILED,
+		Messages: []CANFrame{
+			{Header: 1, Payload: []byte{}},
+			{Header: 2, Payload: []byte{9, 8, 7}},
+		},
+		Matrix: [][]int32{{1, 2}, {}, {-3}},
+	}
+	data, err := spi.MarshalIDL()
+	require.NoError(t, err)
+
+	var got SPI
+	require.NoError(t, got.UnmarshalIDL(data))
+	require.Equal(t, spi, got)
+	require.Equal(t, "FAILED", got.Status.String())
+
+	c, err := converter.NewIDLConverterFromFile("spi.idl", "spi::SPI")
+	require.NoError(t, err)
+	m, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, "FAILED", m["status"])
+	require.Equal(t, "can0", m["source"])
+	require.Equal(t, uint64(18446744073709551615), m["timestamp"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"header": int64(1), "id": map[string]interface{}{"bid": int64(0), "cid": int64(0)}, "payload": []interface{}{}},
+		map[string]interface{}{"header": int64(2), "id": map[string]interface{}{"bid": int64(0), "cid": int64(0)}, "payload": []interface{}{int64(9), int64(8), int64(7)}},
+	}, m["messages"])
+}
+
+func TestLengthPrefixOverflow(t *testing.T) {
+	spi := SPI{Source: strings.Repeat("x", 1<<16)}
+	_, err := spi.MarshalIDL()
+	require.EqualError(t, err, "length 65536 does not fit a 2-byte prefix")
+}
+
+func TestBoundsMatchConverter(t *testing.T) {
+	c, err := converter.NewIDLConverterFromFile("spi.idl", "spi::Tagged")
+	require.NoError(t, err)
+
+	tests := []struct {
+		tagged Tagged
+		value  map[string]interface{}
+		err    string
+	}{
+		{Tagged{Tag: "toolong"}, map[string]interface{}{"tag": "toolong", "values": []interface{}{}}, "string of 7 bytes exceeds bound 4"},
+		{Tagged{Values: []int32{1, 2, 3}}, map[string]interface{}{"tag": "", "values": []interface{}{1, 2, 3}}, "sequence of 3 elements exceeds bound 2"},
+	}
+	for _, tt := range tests {
+		_, err := tt.tagged.MarshalIDL()
+		require.EqualError(t, err, tt.err)
+		_, err = c.Encode(tt.value)
+		require.ErrorContains(t, err, tt.err)
+	}
+
+	data := []byte{7, 't', 'o', 'o', 'l', 'o', 'n', 'g', 0, 0, 0, 0}
+	var tagged Tagged
+	require.EqualError(t, tagged.UnmarshalIDL(data), "string of 7 bytes exceeds bound 4")
+	_, err = c.Decode(data)
+	require.ErrorContains(t, err, "string of 7 bytes exceeds bound 4")
+}
+
+func TestInvalidEnumValue(t *testing.T) {
+	var s Status
+	_, err := s.readIDL([]byte{0, 0, 0, 7})
+	require.Error(t, err)
+	require.Equal(t, "Status(7)", Status(7).String())
+}
@@ -0,0 +1,388 @@
+// Code generated by idlgen. DO NOT EDIT.
+// source: spi.idl
+
+package example
+
+import (
+	"fmt"
+
+	"github.com/yisaer/idl-parser/wire"
+)
+
+type Status uint32
+
+const (
+	StatusOK Status = iota
+	StatusFAILED
+)
+
+func (e Status) String() string {
+	switch e {
+	case StatusOK:
+		return "OK"
+	case StatusFAILED:
+		return "FAILED"
+	}
+	return fmt.Sprintf("Status(%d)", uint32(e))
+}
+
+func (e *Status) appendIDL(b []byte) ([]byte, error) {
+	return wire.AppendUint32(b, uint32(*e)), nil
+}
+
+func (e *Status) readIDL(data []byte) ([]byte, error) {
+	v, remained, err := wire.ReadUint32(data)
+	if err != nil {
+		return nil, err
+	}
+	if v >= 2 {
+		return nil, fmt.Errorf("invalid Status value %v", v)
+	}
+	*e = Status(v)
+	return remained, nil
+}
+
+// IdBits packs its bitfields into a uint16, first field in the least significant bits.
+type IdBits uint16
+
+func (b IdBits) Bid() uint8 {
+	return uint8(b>>0) & 0xf
+}
+
+func (b *IdBits) SetBid(v uint8) {
+	*b = *b&^(0xf<<0) | IdBits(v&0xf)<<0
+}
+
+func (b IdBits) Cid() uint16 {
+	return uint16(b>>4) & 0xfff
+}
+
+func (b *IdBits) SetCid(v uint16) {
+	*b = *b&^(0xfff<<4) | IdBits(v&0xfff)<<4
+}
+
+func (b *IdBits) appendIDL(buf []byte) ([]byte, error) {
+	return wire.AppendUint16(buf, uint16(*b)), nil
+}
+
+func (b *IdBits) readIDL(data []byte) ([]byte, error) {
+	v, remained, err := wire.ReadUint16(data)
+	if err != nil {
+		return nil, err
+	}
+	*b = IdBits(v)
+	return remained, nil
+}
+
+type CANFrame struct {
+	Header  uint8   `idl:"header"`
+	Id      IdBits  `idl:"id"`
+	Payload []uint8 `idl:"payload"`
+}
+
+func (m *CANFrame) MarshalIDL() ([]byte, error) {
+	return m.appendIDL(nil)
+}
+
+func (m *CANFrame) UnmarshalIDL(data []byte) error {
+	remained, err := m.readIDL(data)
+	if err != nil {
+		return err
+	}
+	if len(remained) > 0 {
+		return fmt.Errorf("CANFrame: %v trailing bytes", len(remained))
+	}
+	return nil
+}
+
+func (m *CANFrame) appendIDL(b []byte) ([]byte, error) {
+	var err error
+	b = wire.AppendUint8(b, m.Header)
+	if b, err = m.Id.appendIDL(b); err != nil {
+		return nil, err
+	}
+	if b, err = wire.AppendLength(b, len(m.Payload)); err != nil {
+		return nil, err
+	}
+	for i1 := range m.Payload {
+		b = wire.AppendUint8(b, m.Payload[i1])
+	}
+	return b, nil
+}
+
+func (m *CANFrame) readIDL(data []byte) ([]byte, error) {
+	var err error
+	if m.Header, data, err = wire.ReadUint8(data); err != nil {
+		return nil, err
+	}
+	if data, err = m.Id.readIDL(data); err != nil {
+		return nil, err
+	}
+	var n2 int
+	if n2, data, err = wire.ReadLength(data); err != nil {
+		return nil, err
+	}
+	m.Payload = make([]uint8, n2)
+	for i3 := range m.Payload {
+		if m.Payload[i3], data, err = wire.ReadUint8(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+type TimedFrame struct {
+	Header  uint8   `idl:"header"`
+	Id      IdBits  `idl:"id"`
+	Payload []uint8 `idl:"payload"`
+	Stamp   uint64  `idl:"stamp"`
+}
+
+func (m *TimedFrame) MarshalIDL() ([]byte, error) {
+	return m.appendIDL(nil)
+}
+
+func (m *TimedFrame) UnmarshalIDL(data []byte) error {
+	remained, err := m.readIDL(data)
+	if err != nil {
+		return err
+	}
+	if len(remained) > 0 {
+		return fmt.Errorf("TimedFrame: %v trailing bytes", len(remained))
+	}
+	return nil
+}
+
+func (m *TimedFrame) appendIDL(b []byte) ([]byte, error) {
+	var err error
+	b = wire.AppendUint8(b, m.Header)
+	if b, err = m.Id.appendIDL(b); err != nil {
+		return nil, err
+	}
+	if b, err = wire.AppendLength(b, len(m.Payload)); err != nil {
+		return nil, err
+	}
+	for i4 := range m.Payload {
+		b = wire.AppendUint8(b, m.Payload[i4])
+	}
+	b = wire.AppendUint64(b, m.Stamp)
+	return b, nil
+}
+
+func (m *TimedFrame) readIDL(data []byte) ([]byte, error) {
+	var err error
+	if m.Header, data, err = wire.ReadUint8(data); err != nil {
+		return nil, err
+	}
+	if data, err = m.Id.readIDL(data); err != nil {
+		return nil, err
+	}
+	var n5 int
+	if n5, data, err = wire.ReadLength(data); err != nil {
+		return nil, err
+	}
+	m.Payload = make([]uint8, n5)
+	for i6 := range m.Payload {
+		if m.Payload[i6], data, err = wire.ReadUint8(data); err != nil {
+			return nil, err
+		}
+	}
+	if m.Stamp, data, err = wire.ReadUint64(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type Tagged struct {
+	Tag    string  `idl:"tag"`
+	Values []int32 `idl:"values"`
+}
+
+func (m *Tagged) MarshalIDL() ([]byte, error) {
+	return m.appendIDL(nil)
+}
+
+func (m *Tagged) UnmarshalIDL(data []byte) error {
+	remained, err := m.readIDL(data)
+	if err != nil {
+		return err
+	}
+	if len(remained) > 0 {
+		return fmt.Errorf("Tagged: %v trailing bytes", len(remained))
+	}
+	return nil
+}
+
+func (m *Tagged) appendIDL(b []byte) ([]byte, error) {
+	var err error
+	if len(m.Tag) > 4 {
+		return nil, fmt.Errorf("string of %v bytes exceeds bound 4", len(m.Tag))
+	}
+	if b, err = wire.AppendStringN(b, m.Tag, 1); err != nil {
+		return nil, err
+	}
+	if len(m.Values) > 2 {
+		return nil, fmt.Errorf("sequence of %v elements exceeds bound 2", len(m.Values))
+	}
+	if b, err = wire.AppendLength(b, len(m.Values)); err != nil {
+		return nil, err
+	}
+	for i7 := range m.Values {
+		b = wire.AppendInt32(b, m.Values[i7])
+	}
+	return b, nil
+}
+
+func (m *Tagged) readIDL(data []byte) ([]byte, error) {
+	var err error
+	if m.Tag, data, err = wire.ReadStringN(data, 1); err != nil {
+		return nil, err
+	}
+	if len(m.Tag) > 4 {
+		return nil, fmt.Errorf("string of %v bytes exceeds bound 4", len(m.Tag))
+	}
+	var n8 int
+	if n8, data, err = wire.ReadLength(data); err != nil {
+		return nil, err
+	}
+	if n8 > 2 {
+		return nil, fmt.Errorf("sequence of %v elements exceeds bound 2", n8)
+	}
+	m.Values = make([]int32, n8)
+	for i9 := range m.Values {
+		if m.Values[i9], data, err = wire.ReadInt32(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+type SPI struct {
+	Header    uint16     `idl:"header"`
+	Offset    int16      `idl:"offset"`
+	Count     int32      `idl:"count"`
+	Crc       uint32     `idl:"crc"`
+	Seq       int64      `idl:"seq"`
+	Timestamp uint64     `idl:"timestamp"`
+	Valid     bool       `idl:"valid"`
+	Ratio     float32    `idl:"ratio"`
+	Source    string     `idl:"source"`
+	Status    Status     `idl:"status"`
+	Messages  []CANFrame `idl:"messages"`
+	Matrix    [][]int32  `idl:"matrix"`
+}
+
+func (m *SPI) MarshalIDL() ([]byte, error) {
+	return m.appendIDL(nil)
+}
+
+func (m *SPI) UnmarshalIDL(data []byte) error {
+	remained, err := m.readIDL(data)
+	if err != nil {
+		return err
+	}
+	if len(remained) > 0 {
+		return fmt.Errorf("SPI: %v trailing bytes", len(remained))
+	}
+	return nil
+}
+
+func (m *SPI) appendIDL(b []byte) ([]byte, error) {
+	var err error
+	b = wire.AppendUint16(b, m.Header)
+	b = wire.AppendInt16(b, m.Offset)
+	b = wire.AppendInt32(b, m.Count)
+	b = wire.AppendUint32(b, m.Crc)
+	b = wire.AppendInt64(b, m.Seq)
+	b = wire.AppendUint64(b, m.Timestamp)
+	b = wire.AppendBool(b, m.Valid)
+	b = wire.AppendFloat32(b, m.Ratio)
+	if b, err = wire.AppendStringN(b, m.Source, 2); err != nil {
+		return nil, err
+	}
+	if b, err = m.Status.appendIDL(b); err != nil {
+		return nil, err
+	}
+	if b, err = wire.AppendLength(b, len(m.Messages)); err != nil {
+		return nil, err
+	}
+	for i10 := range m.Messages {
+		if b, err = m.Messages[i10].appendIDL(b); err != nil {
+			return nil, err
+		}
+	}
+	if b, err = wire.AppendLength(b, len(m.Matrix)); err != nil {
+		return nil, err
+	}
+	for i11 := range m.Matrix {
+		if b, err = wire.AppendLength(b, len(m.Matrix[i11])); err != nil {
+			return nil, err
+		}
+		for i12 := range m.Matrix[i11] {
+			b = wire.AppendInt32(b, m.Matrix[i11][i12])
+		}
+	}
+	return b, nil
+}
+
+func (m *SPI) readIDL(data []byte) ([]byte, error) {
+	var err error
+	if m.Header, data, err = wire.ReadUint16(data); err != nil {
+		return nil, err
+	}
+	if m.Offset, data, err = wire.ReadInt16(data); err != nil {
+		return nil, err
+	}
+	if m.Count, data, err = wire.ReadInt32(data); err != nil {
+		return nil, err
+	}
+	if m.Crc, data, err = wire.ReadUint32(data); err != nil {
+		return nil, err
+	}
+	if m.Seq, data, err = wire.ReadInt64(data); err != nil {
+		return nil, err
+	}
+	if m.Timestamp, data, err = wire.ReadUint64(data); err != nil {
+		return nil, err
+	}
+	if m.Valid, data, err = wire.ReadBool(data); err != nil {
+		return nil, err
+	}
+	if m.Ratio, data, err = wire.ReadFloat32(data); err != nil {
+		return nil, err
+	}
+	if m.Source, data, err = wire.ReadStringN(data, 2); err != nil {
+		return nil, err
+	}
+	if data, err = m.Status.readIDL(data); err != nil {
+		return nil, err
+	}
+	var n13 int
+	if n13, data, err = wire.ReadLength(data); err != nil {
+		return nil, err
+	}
+	m.Messages = make([]CANFrame, n13)
+	for i14 := range m.Messages {
+		if data, err = m.Messages[i14].readIDL(data); err != nil {
+			return nil, err
+		}
+	}
+	var n15 int
+	if n15, data, err = wire.ReadLength(data); err != nil {
+		return nil, err
+	}
+	m.Matrix = make([][]int32, n15)
+	for i16 := range m.Matrix {
+		var n17 int
+		if n17, data, err = wire.ReadLength(data); err != nil {
+			return nil, err
+		}
+		m.Matrix[i16] = make([]int32, n17)
+		for i18 := range m.Matrix[i16] {
+			if m.Matrix[i16][i18], data, err = wire.ReadInt32(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
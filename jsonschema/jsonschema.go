@@ -0,0 +1,373 @@
+// Package jsonschema exports IDL structs as JSON Schema (draft 2020-12)
+// describing the values produced by converter.Decode.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+const (
+	rangeAnnotation        = "range"
+	defaultAnnotation      = "default"
+	lengthPrefixAnnotation = "length_prefix"
+)
+
+type integerInfo struct {
+	bits   int
+	signed bool
+}
+
+var integers = map[typ.FieldRefType]integerInfo{
+	typ.Int8Type:             {8, true},
+	typ.OctetType:            {8, false},
+	typ.ShortType:            {16, true},
+	typ.UnsignedShortType:    {16, false},
+	typ.LongType:             {32, true},
+	typ.UnsignedLongType:     {32, false},
+	typ.LongLongType:         {64, true},
+	typ.UnsignedLongLongType: {64, false},
+}
+
+func (info integerInfo) bounds() (any, any) {
+	if info.signed {
+		return int64(-1) << (info.bits - 1), int64(1)<<(info.bits-1) - 1
+	}
+	return 0, ^uint64(0) >> (64 - info.bits)
+}
+
+// parse parses an annotation value as an integer of this width.
+func (info integerInfo) parse(v string) (any, error) {
+	if info.signed {
+		return strconv.ParseInt(v, 10, info.bits)
+	}
+	return strconv.ParseUint(v, 10, info.bits)
+}
+
+// Generate returns the JSON Schema of the struct typeName, a scoped name such
+// as "spi::CANFrame". Structs, enums and bitsets it references are placed in
+// $defs. Integers are bounded by their width, bounded sequences get maxItems
+// and the field annotations @range(min=.., max=..) and @default(value) map to
+// minimum/maximum and default.
+func Generate(module ast.Module, typeName string) ([]byte, error) {
+	def, owner, err := ast.NewGlobalScope(module).Resolve(typeName)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := def.(struct_type.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a struct", typeName)
+	}
+	g := &generator{root: owner.Qualify(st.Name), seen: make(map[string]bool)}
+	g.seen[g.root] = true
+	body, err := g.structSchema(st, owner)
+	if err != nil {
+		return nil, err
+	}
+	schema := object{{"$schema", Draft}, {"title", g.root}}
+	schema = append(schema, body...)
+	if len(g.defs) > 0 {
+		schema = append(schema, member{"$defs", g.defs})
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// object is a JSON object that keeps its members in order.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	root string
+	defs object
+	seen map[string]bool
+}
+
+// shape is the schema of a type together with what annotations need to know
+// about it.
+type shape struct {
+	schema  object
+	name    string
+	kind    typ.FieldRefType
+	members []string
+}
+
+func (g *generator) structSchema(st struct_type.Struct, s *ast.Scope) (object, error) {
+	members, err := s.Members(st)
+	if err != nil {
+		return nil, err
+	}
+	properties := make(object, 0, len(members))
+	required := make([]string, 0, len(members))
+	for _, field := range members {
+		schema, err := g.fieldSchema(field.Field, field.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("st %v field %v: %v", st.Name, field.Name, err)
+		}
+		properties = append(properties, member{field.Name, schema})
+		required = append(required, field.Name)
+	}
+	return object{
+		{"type", "object"},
+		{"properties", properties},
+		{"required", required},
+		{"additionalProperties", false},
+	}, nil
+}
+
+func (g *generator) fieldSchema(field struct_type.Field, s *ast.Scope) (object, error) {
+	sh, err := g.typeSchema(field.Type, s)
+	if err != nil {
+		return nil, err
+	}
+	sh.name = field.Type.TypeName()
+	if anno, ok := field.Annotations.Get(lengthPrefixAnnotation); ok && sh.kind == typ.SequenceType {
+		if size, err := strconv.Atoi(anno.Values["value"]); err == nil && size < 8 {
+			sh.setMaxItems(1<<(8*size) - 1)
+		}
+	}
+	if anno, ok := field.Annotations.Get(rangeAnnotation); ok {
+		if err := sh.applyRange(anno); err != nil {
+			return nil, err
+		}
+	}
+	if anno, ok := field.Annotations.Get(defaultAnnotation); ok {
+		value, err := sh.parse(anno.Values["value"])
+		if err != nil {
+			return nil, fmt.Errorf("@%v: %v", defaultAnnotation, err)
+		}
+		sh.schema = append(sh.schema, member{"default", value})
+	}
+	return sh.schema, nil
+}
+
+func (sh *shape) setMaxItems(n int) {
+	for i := range sh.schema {
+		if sh.schema[i].key == "maxItems" {
+			sh.schema[i].value = min(sh.schema[i].value.(int), n)
+			return
+		}
+	}
+	sh.schema = append(sh.schema, member{"maxItems", n})
+}
+
+func (sh *shape) applyRange(anno annotation.Annotation) error {
+	if _, ok := integers[sh.kind]; !ok && !isFloat(sh.kind) {
+		return fmt.Errorf("@%v applies only to numbers", rangeAnnotation)
+	}
+	for _, bound := range []struct{ key, keyword string }{{"min", "minimum"}, {"max", "maximum"}} {
+		v, ok := anno.Values[bound.key]
+		if !ok {
+			continue
+		}
+		value, err := sh.parse(v)
+		if err != nil {
+			return fmt.Errorf("@%v %v: %v", rangeAnnotation, bound.key, err)
+		}
+		sh.set(bound.keyword, value)
+	}
+	return nil
+}
+
+func (sh *shape) set(key string, value any) {
+	for i := range sh.schema {
+		if sh.schema[i].key == key {
+			sh.schema[i].value = value
+			return
+		}
+	}
+	sh.schema = append(sh.schema, member{key, value})
+}
+
+// parse converts an annotation value to the JSON value of the type.
+func (sh *shape) parse(v string) (any, error) {
+	if info, ok := integers[sh.kind]; ok {
+		value, err := info.parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v value %q", sh.name, v)
+		}
+		return value, nil
+	}
+	switch {
+	case isFloat(sh.kind):
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float value %q", v)
+		}
+		return value, nil
+	case sh.kind == typ.BooleanType:
+		value, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean value %q", v)
+		}
+		return value, nil
+	case sh.kind == typ.StringType:
+		return v, nil
+	case sh.members != nil:
+		for _, m := range sh.members {
+			if m == v {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an enum member", v)
+	}
+	return nil, fmt.Errorf("%v takes no value", sh.name)
+}
+
+func (g *generator) typeSchema(t typeref.TypeRef, s *ast.Scope) (shape, error) {
+	kind := t.TypeRefType()
+	if info, ok := integers[kind]; ok {
+		lo, hi := info.bounds()
+		return shape{schema: object{{"type", "integer"}, {"minimum", lo}, {"maximum", hi}}, kind: kind}, nil
+	}
+	switch kind {
+	case typ.BooleanType:
+		return shape{schema: object{{"type", "boolean"}}, kind: kind}, nil
+	case typ.FloatType, typ.DoubleType:
+		return shape{schema: object{{"type", "number"}}, kind: kind}, nil
+	case typ.StringType:
+		schema := object{{"type", "string"}}
+		if bound := t.(typeref.StringType).Bound; bound > 0 {
+			schema = append(schema, member{"maxLength", bound})
+		}
+		return shape{schema: schema, kind: kind}, nil
+	case typ.SequenceType:
+		seq := t.(typeref.Sequence)
+		items, err := g.typeSchema(seq.InnerType, s)
+		if err != nil {
+			return shape{}, err
+		}
+		schema := object{{"type", "array"}, {"items", items.schema}}
+		if seq.Bound > 0 {
+			schema = append(schema, member{"maxItems", seq.Bound})
+		}
+		return shape{schema: schema, kind: kind}, nil
+	case typ.SelfDefinedTypeType:
+		return g.refSchema(t.TypeName(), s)
+	}
+	return shape{}, fmt.Errorf("unsupported type:%v", t.TypeName())
+}
+
+// refSchema references a named type, adding it to $defs on first use.
+func (g *generator) refSchema(name string, s *ast.Scope) (shape, error) {
+	def, owner, err := s.Resolve(name)
+	if err != nil {
+		return shape{}, err
+	}
+	if td, ok := def.(typedef_type.Typedef); ok {
+		return g.typedefSchema(td, owner)
+	}
+	key := owner.Qualify(def.GetName())
+	sh := shape{kind: typ.SelfDefinedTypeType}
+	if e, ok := def.(enum_type.Enum); ok {
+		sh.members = e.Members
+	}
+	if key == g.root {
+		sh.schema = object{{"$ref", "#"}}
+		return sh, nil
+	}
+	defName := strings.ReplaceAll(key, "::", ".")
+	sh.schema = object{{"$ref", "#/$defs/" + defName}}
+	if g.seen[key] {
+		return sh, nil
+	}
+	g.seen[key] = true
+	index := len(g.defs)
+	g.defs = append(g.defs, member{defName, nil})
+	var schema object
+	switch d := def.(type) {
+	case struct_type.Struct:
+		schema, err = g.structSchema(d, owner)
+	case enum_type.Enum:
+		schema = object{{"type", "string"}, {"enum", d.Members}}
+	case bitset.BitSet:
+		schema = bitSetSchema(d)
+	case union_type.Union:
+		err = fmt.Errorf("union %v is not supported", name)
+	default:
+		err = fmt.Errorf("%v is not a type", name)
+	}
+	if err != nil {
+		return shape{}, err
+	}
+	g.defs[index].value = append(object{{"title", key}}, schema...)
+	return sh, nil
+}
+
+// typedefSchema inlines the schema of the aliased type, as an array of
+// exactly n items for each dimension.
+func (g *generator) typedefSchema(td typedef_type.Typedef, s *ast.Scope) (shape, error) {
+	sh, err := g.typeSchema(td.Aliased, s)
+	if err != nil {
+		return shape{}, fmt.Errorf("typedef %v: %v", td.Name, err)
+	}
+	for i := len(td.Dims) - 1; i >= 0; i-- {
+		n := td.Dims[i]
+		sh = shape{
+			schema: object{{"type", "array"}, {"items", sh.schema}, {"minItems", n}, {"maxItems", n}},
+			kind:   typ.SequenceType,
+		}
+	}
+	return sh, nil
+}
+
+func isFloat(kind typ.FieldRefType) bool {
+	return kind == typ.FloatType || kind == typ.DoubleType
+}
+
+func bitSetSchema(bs bitset.BitSet) object {
+	properties := make(object, 0, len(bs.Fields))
+	required := make([]string, 0, len(bs.Fields))
+	for _, field := range bs.Fields {
+		hi := uint64(0)
+		if w := field.Type.Width; w > 0 && w <= 64 {
+			hi = ^uint64(0) >> (64 - w)
+		}
+		properties = append(properties, member{field.Name, object{{"type", "integer"}, {"minimum", 0}, {"maximum", hi}}})
+		required = append(required, field.Name)
+	}
+	return object{
+		{"type", "object"},
+		{"properties", properties},
+		{"required", required},
+		{"additionalProperties", false},
+	}
+}
@@ -0,0 +1,163 @@
+package jsonschema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+const telemetrySchema = `module tm {
+	enum Mode { OFF, ON };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<5> code;
+	};
+	module gps {
+		struct Fix {
+			float lat;
+			float lon;
+		};
+	};
+	struct Reading {
+		@range(min=-40, max=125) short temperature;
+		@default(ON) Mode mode;
+		@default("no note") string note;
+		@range(min=0.5) @default(1.5) float gain;
+		unsigned long long counter;
+		Flags flags;
+		sequence<gps::Fix, 16> track;
+		@length_prefix(1) sequence<octet> raw;
+		@default(true) boolean valid;
+	};
+}`
+
+const readingJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "tm::Reading",
+  "type": "object",
+  "properties": {
+    "temperature": {"type": "integer", "minimum": -40, "maximum": 125},
+    "mode": {"$ref": "#/$defs/tm.Mode", "default": "ON"},
+    "note": {"type": "string", "default": "no note"},
+    "gain": {"type": "number", "minimum": 0.5, "default": 1.5},
+    "counter": {"type": "integer", "minimum": 0, "maximum": 18446744073709551615},
+    "flags": {"$ref": "#/$defs/tm.Flags"},
+    "track": {"type": "array", "items": {"$ref": "#/$defs/tm.gps.Fix"}, "maxItems": 16},
+    "raw": {"type": "array", "items": {"type": "integer", "minimum": 0, "maximum": 255}, "maxItems": 255},
+    "valid": {"type": "boolean", "default": true}
+  },
+  "required": ["temperature", "mode", "note", "gain", "counter", "flags", "track", "raw", "valid"],
+  "additionalProperties": false,
+  "$defs": {
+    "tm.Mode": {"title": "tm::Mode", "type": "string", "enum": ["OFF", "ON"]},
+    "tm.Flags": {
+      "title": "tm::Flags",
+      "type": "object",
+      "properties": {
+        "level": {"type": "integer", "minimum": 0, "maximum": 7},
+        "code": {"type": "integer", "minimum": 0, "maximum": 31}
+      },
+      "required": ["level", "code"],
+      "additionalProperties": false
+    },
+    "tm.gps.Fix": {
+      "title": "tm::gps::Fix",
+      "type": "object",
+      "properties": {
+        "lat": {"type": "number"},
+        "lon": {"type": "number"}
+      },
+      "required": ["lat", "lon"],
+      "additionalProperties": false
+    }
+  }
+}`
+
+func parseModule(t *testing.T, code string) ast.Module {
+	result := ast.Parse(code)
+	require.Nil(t, result.Err)
+	return result.Output
+}
+
+func TestGenerate(t *testing.T) {
+	got, err := Generate(parseModule(t, telemetrySchema), "tm::Reading")
+	require.NoError(t, err)
+	require.JSONEq(t, readingJSONSchema, string(got))
+}
+
+func TestGenerateIntegerBounds(t *testing.T) {
+	got, err := Generate(parseModule(t, `module m {
+	struct S {
+		octet a;
+		short b;
+		unsigned short c;
+		long d;
+		unsigned long e;
+		long long f;
+	};
+}`), "m::S")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "m::S",
+  "type": "object",
+  "properties": {
+    "a": {"type": "integer", "minimum": 0, "maximum": 255},
+    "b": {"type": "integer", "minimum": -32768, "maximum": 32767},
+    "c": {"type": "integer", "minimum": 0, "maximum": 65535},
+    "d": {"type": "integer", "minimum": -2147483648, "maximum": 2147483647},
+    "e": {"type": "integer", "minimum": 0, "maximum": 4294967295},
+    "f": {"type": "integer", "minimum": -9223372036854775808, "maximum": 9223372036854775807}
+  },
+  "required": ["a", "b", "c", "d", "e", "f"],
+  "additionalProperties": false
+}`, string(got))
+}
+
+func TestGenerateTypedefArrays(t *testing.T) {
+	got, err := Generate(parseModule(t, `module m {
+	typedef double double__3[3];
+	typedef int8 grid[2][2];
+	struct S {
+		double__3 a;
+		grid b;
+		@default(value="map") string<16> c;
+	};
+}`), "m::S")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "m::S",
+  "type": "object",
+  "properties": {
+    "a": {"type": "array", "items": {"type": "number"}, "minItems": 3, "maxItems": 3},
+    "b": {"type": "array", "items": {"type": "array", "items": {"type": "integer", "minimum": -128, "maximum": 127}, "minItems": 2, "maxItems": 2}, "minItems": 2, "maxItems": 2},
+    "c": {"type": "string", "maxLength": 16, "default": "map"}
+  },
+  "required": ["a", "b", "c"],
+  "additionalProperties": false
+}`, string(got))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		code     string
+		typeName string
+		err      string
+	}{
+		{`module m { struct S { long a; }; }`, "m::T", "type m::T not found"},
+		{`module m { enum E { A }; }`, "m::E", "m::E is not a struct"},
+		{`module m { struct S { @range(min=300) octet a; }; }`, "m::S", `st S field a: @range min: invalid octet value "300"`},
+		{`module m { struct S { @range(max=1) string a; }; }`, "m::S", "st S field a: @range applies only to numbers"},
+		{`module m { enum E { A }; struct S { @default(B) E a; }; }`, "m::S", `st S field a: @default: "B" is not an enum member`},
+		{`module m { struct S { @default(yes) boolean a; }; }`, "m::S", `st S field a: @default: invalid boolean value "yes"`},
+		{`module m { struct S { @default(1) sequence<long> a; }; }`, "m::S", "st S field a: @default: sequence takes no value"},
+		{`module m { struct S { Missing a; }; }`, "m::S", "st S field a: type Missing not found"},
+	}
+	for _, test := range tests {
+		_, err := Generate(parseModule(t, test.code), test.typeName)
+		require.EqualError(t, err, test.err, test.code)
+	}
+}
@@ -0,0 +1,271 @@
+// Package printer turns a parsed IDL module back into canonical IDL text.
+// Format additionally keeps the comments of the source it reformats.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+type printer struct {
+	buf     bytes.Buffer
+	indent  int
+	anchors []anchor
+}
+
+// Print renders module as canonical IDL: one definition, field or
+// enumerator per line, tab indentation and a blank line between the
+// definitions of a module.
+func Print(module ast.Module) []byte {
+	p := &printer{}
+	p.module(module)
+	return p.buf.Bytes()
+}
+
+// Format parses src and prints it canonically, keeping its // comments
+// attached to the definition, field or enumerator they annotate.
+func Format(src []byte) ([]byte, error) {
+	code := string(src)
+	modules, err := ast.ParseModules(code)
+	if err != nil {
+		return nil, err
+	}
+	p := &printer{anchors: scan(code)}
+	if len(modules) == 1 {
+		p.module(modules[0])
+	} else {
+		// A module reopened at the top level stays where the source has
+		// it, keeping its comments in order.
+		var root ast.Module
+		for _, m := range modules {
+			root.Content = append(root.Content, m)
+		}
+		p.module(root)
+	}
+	p.leading(p.next())
+	if len(p.anchors) > 0 {
+		return nil, fmt.Errorf("cannot place %v comments", len(p.anchors))
+	}
+	return p.buf.Bytes(), nil
+}
+
+// next returns the comments of the next item; items are printed in the same
+// order as the scanner found them in the source.
+func (p *printer) next() anchor {
+	if len(p.anchors) == 0 {
+		return anchor{}
+	}
+	a := p.anchors[0]
+	p.anchors = p.anchors[1:]
+	return a
+}
+
+func (p *printer) leading(a anchor) {
+	for _, c := range a.leading {
+		if c == "" {
+			p.buf.WriteByte('\n')
+			continue
+		}
+		p.line(c, "")
+	}
+}
+
+func (p *printer) line(text, trailing string) {
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+	p.buf.WriteString(text)
+	if trailing != "" {
+		p.buf.WriteString(" " + trailing)
+	}
+	p.buf.WriteByte('\n')
+}
+
+// open prints the header of a definition and indents its body.
+func (p *printer) open(header string) {
+	a := p.next()
+	p.leading(a)
+	p.line(header+" {", a.trailing)
+	p.indent++
+}
+
+// close prints the closing brace of a definition, after any comments that
+// preceded it inside the body.
+func (p *printer) close(semicolon bool) {
+	a := p.next()
+	p.leading(a)
+	p.indent--
+	text := "}"
+	if semicolon {
+		text = "};"
+	}
+	p.line(text, a.trailing)
+}
+
+func (p *printer) item(text string) {
+	a := p.next()
+	p.leading(a)
+	p.line(text, a.trailing)
+}
+
+func (p *printer) module(m ast.Module) {
+	if m.Name == "" && p.indent == 0 {
+		// An unnamed root, as built by ast.Merge, holds top-level modules.
+		for i, con := range m.Content {
+			if i > 0 {
+				p.buf.WriteByte('\n')
+			}
+			p.content(con)
+		}
+		return
+	}
+	p.open("module " + m.Name)
+	for i, con := range m.Content {
+		if i > 0 {
+			p.buf.WriteByte('\n')
+		}
+		p.content(con)
+	}
+	p.close(p.indent > 1)
+}
+
+func (p *printer) content(con ast.ModuleContent) {
+	switch def := con.(type) {
+	case ast.Module:
+		p.module(def)
+	case struct_type.Struct:
+		header := annotations(def.Annotations) + "struct " + def.Name
+		if def.Base != "" {
+			header += " : " + def.Base
+		}
+		p.open(header)
+		for _, field := range def.Fields {
+			p.item(annotations(field.Annotations) + typeString(field.Type) + " " + field.Name + ";")
+		}
+		p.close(true)
+	case bitset.BitSet:
+		p.open("bitset " + def.Name)
+		for _, field := range def.Fields {
+			p.item(typeString(field.Type) + " " + field.Name + ";")
+		}
+		p.close(true)
+	case enum_type.Enum:
+		p.open("enum " + def.Name)
+		for i, member := range def.Members {
+			if i < len(def.Members)-1 {
+				member += ","
+			}
+			p.item(member)
+		}
+		p.close(true)
+	case union_type.Union:
+		p.open(annotations(def.Annotations) + "union " + def.Name + " switch (" + typeString(def.Discriminator) + ")")
+		for _, c := range def.Cases {
+			p.unionCase(c)
+		}
+		p.close(true)
+	case typedef_type.Typedef:
+		text := "typedef " + typeString(def.Aliased) + " " + def.Name
+		for _, dim := range def.Dims {
+			text += fmt.Sprintf("[%d]", dim)
+		}
+		p.item(text + ";")
+	case const_type.Const:
+		p.item(annotations(def.Annotations) + "const " + typeString(def.ValueType) + " " + def.Name + " = " + def.Value + ";")
+	case forward_type.Forward:
+		p.item(def.Kind + " " + def.Name + ";")
+	}
+}
+
+// unionCase prints the labels of a case on their own lines followed by the
+// indented member; the labels and member form a single item, and each label
+// keeps the comments found around it.
+func (p *printer) unionCase(c union_type.Case) {
+	a := p.next()
+	labels := make([]string, 0, len(c.Labels)+1)
+	for _, label := range c.Labels {
+		labels = append(labels, "case "+label+":")
+	}
+	if c.Default {
+		labels = append(labels, "default:")
+	}
+	for i, label := range labels {
+		var la anchor
+		if i < len(a.labels) {
+			la = a.labels[i]
+		}
+		p.leading(la)
+		p.line(label, la.trailing)
+	}
+	p.indent++
+	p.leading(a)
+	p.line(annotations(c.Field.Annotations)+typeString(c.Field.Type)+" "+c.Field.Name+";", a.trailing)
+	p.indent--
+}
+
+func typeString(t typeref.TypeRef) string {
+	switch v := t.(type) {
+	case typeref.Sequence:
+		if v.Bound > 0 {
+			return fmt.Sprintf("sequence<%v, %d>", typeString(v.InnerType), v.Bound)
+		}
+		return "sequence<" + typeString(v.InnerType) + ">"
+	case typeref.StringType:
+		if v.Bound > 0 {
+			return fmt.Sprintf("string<%d>", v.Bound)
+		}
+	case typeref.BitFieldType:
+		return fmt.Sprintf("bitfield<%d>", v.Width)
+	}
+	return t.TypeName()
+}
+
+// annotations renders annotations followed by a space. A lone "value" is
+// printed positionally; other values are sorted by name and quoted unless
+// numeric.
+func annotations(annos annotation.Annotations) string {
+	var b strings.Builder
+	for _, anno := range annos {
+		b.WriteString("@" + anno.Name)
+		if value, ok := anno.Values["value"]; ok && len(anno.Values) == 1 {
+			b.WriteString("(" + annotationValue(value) + ")")
+		} else if len(anno.Values) > 0 {
+			names := make([]string, 0, len(anno.Values))
+			for name := range anno.Values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for i, name := range names {
+				names[i] = name + "=" + annotationValue(anno.Values[name])
+			}
+			b.WriteString("(" + strings.Join(names, ", ") + ")")
+		}
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func annotationValue(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	if utils.Identifier(v).Remaining == "" {
+		return v
+	}
+	return `"` + valueEscaper.Replace(v) + `"`
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
@@ -0,0 +1,300 @@
+package printer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+var roundTripSchemas = []string{
+	`module spi {
+		bitset idbits {
+			bitfield<4> bid;
+			bitfield<12> cid;
+		};
+		struct CANFrame {
+			@format octet header;
+			@format(a="b",key=123) idbits id;
+			@format(type=canpack, dbc=ab) @merge sequence<sequence<CANFrame, 4>> nested;
+		};
+	}`,
+	`module m {
+		enum State { IDLE, RUNNING, };
+		struct A {
+			unsigned short a; short b; unsigned long c; long d;
+			unsigned long long e; long long f; boolean g; float h; string i;
+			::m::inner::B j;
+			@length_prefix(2) string k;
+			@length_from(a) sequence<State> l;
+			@format(path="a/b.c") @length_to_end sequence<octet> m;
+		};
+		module inner {
+			struct B { octet x };
+		};
+	}`,
+	`module u {
+		enum Kind { INT, TEXT };
+		union Value switch (Kind) {
+			case INT: long i;
+			case TEXT: @default("none") string s;
+		};
+		union Code switch (short) { case 1: case -2: octet a; default: sequence<octet, 8> raw; };
+	}`,
+	`module geometry_msgs {
+		module msg {
+			typedef double double__9[9];
+			typedef int8 grid[2][3];
+			module Pose_Constants {
+				@verbatim (language="comment", text="Say \"hi\"" "\n" "C:\\tmp")
+				const int8 LEVEL = -1;
+				const string NAME = "pose";
+			};
+			@verbatim (language="comment", text="A pose.")
+			struct Pose {
+				double__9 covariance;
+				grid cells;
+				@default (value="map") string<16> frame;
+			};
+		};
+	};`,
+	`module shapes {
+		struct Shape { string name; };
+		module solid {
+			struct Cube : Shape { double edge; };
+		};
+		struct Box : ::shapes::solid::Cube { long id; };
+	}`,
+	`module tree {
+		struct Node;
+		union Value;
+		struct Node { long value; sequence<Node> children; };
+		union Value switch (long) { case 1: sequence<Value> list; default: long n; };
+	}`,
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	example, err := os.ReadFile("../gogen/internal/example/spi.idl")
+	require.NoError(t, err)
+	for _, schema := range append(roundTripSchemas, string(example)) {
+		res := ast.Parse(schema)
+		require.Nil(t, res.Err)
+		printed := Print(res.Output)
+		again := ast.Parse(string(printed))
+		require.Nil(t, again.Err, string(printed))
+		require.Empty(t, again.Remaining)
+		require.Equal(t, res.Output, again.Output, string(printed))
+		require.Equal(t, string(printed), string(Print(again.Output)))
+	}
+}
+
+func TestPrint(t *testing.T) {
+	res := ast.Parse(roundTripSchemas[1])
+	require.Nil(t, res.Err)
+	require.Equal(t, `module m {
+	enum State {
+		IDLE,
+		RUNNING
+	};
+
+	struct A {
+		unsigned short a;
+		short b;
+		unsigned long c;
+		long d;
+		unsigned long long e;
+		long long f;
+		boolean g;
+		float h;
+		string i;
+		::m::inner::B j;
+		@length_prefix(2) string k;
+		@length_from(a) sequence<State> l;
+		@format(path="a/b.c") @length_to_end sequence<octet> m;
+	};
+
+	module inner {
+		struct B {
+			octet x;
+		};
+	};
+}
+`, string(Print(res.Output)))
+}
+
+func TestFormatComments(t *testing.T) {
+	src := `// Package header.
+
+// SPI messages.
+module spi { // trailing on module
+    bitset idbits {
+        bitfield<4> bid; // 4 bits for bus_id
+        bitfield<12> cid;  // 12 bits for can_id
+    };
+	// Status of a frame.
+	enum Status { OK, // fine
+		FAILED };
+	enum Level {
+		LOW,
+		HIGH // last
+		// after the last
+	};
+	struct CANFrame {
+		// leading on header
+		@format(a="b") octet header;
+		idbits id; // trailing on id
+		// dangling at the end of the body
+	}; // after struct
+}
+// end of file
+`
+	expected := `// Package header.
+
+// SPI messages.
+module spi { // trailing on module
+	bitset idbits {
+		bitfield<4> bid; // 4 bits for bus_id
+		bitfield<12> cid; // 12 bits for can_id
+	};
+
+	// Status of a frame.
+	enum Status {
+		OK, // fine
+		FAILED
+	};
+
+	enum Level {
+		LOW,
+		HIGH // last
+		// after the last
+	};
+
+	struct CANFrame {
+		// leading on header
+		@format(a=b) octet header;
+		idbits id; // trailing on id
+		// dangling at the end of the body
+	}; // after struct
+}
+// end of file
+`
+	got, err := Format([]byte(src))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(got))
+
+	again, err := Format(got)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(again))
+
+	_, err = Format([]byte("module m { struct A { octet ; }; }"))
+	require.Error(t, err)
+	_, err = Format([]byte("module m { } junk"))
+	require.Error(t, err)
+}
+
+func TestFormatReopenedModule(t *testing.T) {
+	src := `module a { struct X { long v; }; };
+// reopened
+module a {
+	struct Y { X x; }; // uses X
+};
+`
+	expected := `module a {
+	struct X {
+		long v;
+	};
+}
+
+// reopened
+module a {
+	struct Y {
+		X x;
+	}; // uses X
+}
+`
+	got, err := Format([]byte(src))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(got))
+
+	again, err := Format(got)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(again))
+}
+
+func TestFormatROS(t *testing.T) {
+	src := `// generated from rosidl_adapter
+#include "std_msgs/msg/Header.idl"
+
+module pkg {
+  module msg {
+    typedef double double__2[2];
+    @verbatim (language="comment", text=
+      "First line." "\n"
+      "Second line.")
+    struct Pose {
+      double__2 xy; // position
+    };
+  };
+};
+`
+	expected := `// generated from rosidl_adapter
+#include "std_msgs/msg/Header.idl"
+module pkg {
+	module msg {
+		typedef double double__2[2];
+
+		@verbatim(language=comment, text="First line.\nSecond line.") struct Pose {
+			double__2 xy; // position
+		};
+	};
+}
+`
+	got, err := Format([]byte(src))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(got))
+
+	again, err := Format(got)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(again))
+}
+
+func TestFormatUnion(t *testing.T) {
+	src := `module u {
+	union Code switch (short) { // codes
+		// small codes
+		case 1: case 2: octet a; // one byte
+		case 3: // three
+		// four
+		case 4:
+			// wide
+			long b;
+		default: string s;
+	};
+}
+`
+	expected := `module u {
+	union Code switch (short) { // codes
+		// small codes
+		case 1:
+		case 2:
+			octet a; // one byte
+		case 3: // three
+		// four
+		case 4:
+			// wide
+			long b;
+		default:
+			string s;
+	};
+}
+`
+	got, err := Format([]byte(src))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(got))
+
+	again, err := Format(got)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(again))
+}
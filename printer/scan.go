@@ -0,0 +1,175 @@
+package printer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// anchor holds the comments of one printed item: a definition header, a
+// field, an enumerator, a union case or a closing brace. Leading comments
+// sit on their own lines before the item, an empty string standing for a
+// blank line between them; the trailing comment follows the item on its
+// last line. The labels of a union case hold the comments before and after
+// each label, and leading then holds those between the last label and the
+// member.
+type anchor struct {
+	leading  []string
+	trailing string
+	labels   []anchor
+}
+
+type token struct {
+	text string
+	line int
+}
+
+// scan splits code into items the way the printer emits them and collects
+// the comments around each item. An item ends at "{" (a definition header),
+// at ";" or "," outside brackets (a field or enumerator), or at "}", which
+// first ends an unterminated last item and then is an item of its own. A
+// comment on the last line of an unterminated item trails it when "}"
+// follows. The final anchor holds the comments after the last item.
+func scan(code string) []anchor {
+	var anchors []anchor
+	var cur anchor
+	inItem := false
+	isCase := false
+	depth := 0
+	lastComment := 0
+	ended := -1
+	itemLine := 0
+	// pending is a comment on the current item's line, placed once it is
+	// known whether the item continues; split is where it goes in leading.
+	pending := ""
+	split := 0
+	labelLine := 0
+
+	finish := func(line int) {
+		anchors = append(anchors, cur)
+		cur = anchor{}
+		inItem = false
+		isCase = false
+		ended = line
+	}
+	tokens := tokenize(code)
+	for i, tok := range tokens {
+		if strings.HasPrefix(tok.text, "//") || strings.HasPrefix(tok.text, "#") {
+			text := strings.TrimRightFunc(tok.text, unicode.IsSpace)
+			switch {
+			case tok.line == labelLine && cur.labels[len(cur.labels)-1].trailing == "":
+				cur.labels[len(cur.labels)-1].trailing = text
+			case tok.line == ended && !inItem && len(anchors) > 0 && anchors[len(anchors)-1].trailing == "":
+				anchors[len(anchors)-1].trailing = text
+			case inItem && tok.line == itemLine && pending == "":
+				pending, split = text, len(cur.leading)
+				lastComment = tok.line
+			default:
+				if len(cur.leading) > 0 && tok.line > lastComment+1 {
+					cur.leading = append(cur.leading, "")
+				}
+				cur.leading = append(cur.leading, text)
+				lastComment = tok.line
+			}
+			continue
+		}
+		labelLine = 0
+		if pending != "" {
+			if tok.text == "}" && depth == 0 {
+				rest := cur.leading[split:]
+				if len(rest) > 0 && rest[0] == "" {
+					rest = rest[1:]
+				}
+				cur.leading, cur.trailing = cur.leading[:split], pending
+				anchors = append(anchors, cur)
+				cur = anchor{leading: rest}
+				inItem, isCase = false, false
+			} else {
+				cur.leading = append(cur.leading[:split], append([]string{pending}, cur.leading[split:]...)...)
+			}
+			pending = ""
+		}
+		itemLine = tok.line
+		switch tok.text {
+		case "(", "<", "[":
+			depth++
+		case ")", ">", "]":
+			depth--
+		}
+		if depth > 0 {
+			inItem = true
+			continue
+		}
+		switch tok.text {
+		case "{":
+			finish(tok.line)
+		case ";", ",":
+			if inItem {
+				finish(tok.line)
+			}
+		case "}":
+			if inItem {
+				finish(tok.line)
+			}
+			finish(tok.line)
+		case ":":
+			// A label ends at a lone ":"; "::" belongs to a scoped name.
+			if isCase && !(i+1 < len(tokens) && tokens[i+1].text == ":") && !(i > 0 && tokens[i-1].text == ":") {
+				cur.labels = append(cur.labels, anchor{leading: cur.leading})
+				cur.leading = nil
+				labelLine = tok.line
+			}
+		case "case", "default":
+			isCase = isCase || !inItem
+			inItem = true
+		default:
+			inItem = true
+		}
+	}
+	return append(anchors, cur)
+}
+
+// tokenize splits code into comments, preprocessor lines, identifiers or
+// numbers, quoted strings and single punctuation characters. Preprocessor
+// lines are kept like comments.
+func tokenize(code string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(code); {
+		c := code[i]
+		start := i
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(code[i:], "//") || c == '#':
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			for i++; i < len(code) && code[i] != '"' && code[i] != '\n'; i++ {
+				if code[i] == '\\' && i+1 < len(code) && code[i+1] != '\n' {
+					i++
+				}
+			}
+			if i < len(code) && code[i] == '"' {
+				i++
+			}
+		case c == '_' || c == '.' || isWord(rune(c)):
+			for i < len(code) && (code[i] == '_' || code[i] == '.' || isWord(rune(code[i]))) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, token{text: code[start:i], line: line})
+	}
+	return tokens
+}
+
+func isWord(r rune) bool {
+	return r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,465 @@
+// Package protogen generates proto3 definitions from a parsed IDL module.
+// Every module holding definitions becomes one .proto file and package,
+// structs and bitsets become messages, unions become messages with a single
+// oneof, and sequences become repeated fields. IDL features proto3 cannot
+// express are reported as losses instead of failing the generation.
+package protogen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+)
+
+const (
+	idAnnotation      = "id"
+	defaultAnnotation = "default"
+	rangeAnnotation   = "range"
+
+	maxFieldNumber      = 1<<29 - 1
+	firstReservedNumber = 19000
+	lastReservedNumber  = 19999
+)
+
+type Config struct {
+	// Source is the schema file name recorded in the generated headers.
+	Source string
+}
+
+// File is one generated .proto file.
+type File struct {
+	// Name is the path of the file derived from its package, e.g.
+	// "spi/can.proto" for module spi::can.
+	Name    string
+	Package string
+	Content []byte
+}
+
+// Loss is an IDL feature that has no exact proto3 counterpart, reported
+// against the scoped name of the definition or member it affects.
+type Loss struct {
+	Name   string
+	Reason string
+}
+
+func (l Loss) String() string {
+	return l.Name + ": " + l.Reason
+}
+
+type scalar struct {
+	proto string
+	// widened is set when the proto type holds a wider range than the IDL one.
+	widened bool
+}
+
+var scalars = map[typ.FieldRefType]scalar{
+	typ.Int8Type:             {"int32", true},
+	typ.OctetType:            {"uint32", true},
+	typ.ShortType:            {"int32", true},
+	typ.UnsignedShortType:    {"uint32", true},
+	typ.LongType:             {"int32", false},
+	typ.UnsignedLongType:     {"uint32", false},
+	typ.LongLongType:         {"int64", false},
+	typ.UnsignedLongLongType: {"uint64", false},
+	typ.BooleanType:          {"bool", false},
+	typ.FloatType:            {"float", false},
+	typ.DoubleType:           {"double", false},
+	typ.StringType:           {"string", false},
+}
+
+// Generate returns the proto3 files of module in module order together with
+// the losses of the mapping. Struct and union members are numbered by their
+// @id annotation or, failing that, one past the previous member, starting
+// at 1.
+func Generate(module ast.Module, cfg Config) ([]File, []Loss, error) {
+	g := &generator{}
+	module = ast.Merge(module)
+	if err := g.module(ast.NewGlobalScope(module).Child(module)); err != nil {
+		return nil, nil, err
+	}
+	files := make([]File, 0, len(g.files))
+	for _, f := range g.files {
+		files = append(files, f.render(cfg))
+	}
+	return files, g.losses, nil
+}
+
+type generator struct {
+	files  []*file
+	losses []Loss
+}
+
+type file struct {
+	name     string
+	pkg      string
+	imports  map[string]bool
+	body     bytes.Buffer
+	enumVals map[string]bool
+}
+
+func (g *generator) lose(name, format string, args ...any) {
+	g.losses = append(g.losses, Loss{Name: name, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (g *generator) module(s *ast.Scope) error {
+	var f *file
+	for _, con := range s.Module().Content {
+		if sub, ok := con.(ast.Module); ok {
+			if err := g.module(s.Child(sub)); err != nil {
+				return err
+			}
+			continue
+		}
+		switch def := con.(type) {
+		case typedef_type.Typedef, forward_type.Forward:
+			// Typedefs are inlined where they are used and forward
+			// declarations have nothing to emit.
+			continue
+		case const_type.Const:
+			g.lose(s.Qualify(def.Name), "constants are not exported")
+			continue
+		}
+		if f == nil {
+			f = &file{
+				name:     strings.ReplaceAll(s.Path(), "::", "/") + ".proto",
+				pkg:      packageName(s),
+				imports:  make(map[string]bool),
+				enumVals: make(map[string]bool),
+			}
+			g.files = append(g.files, f)
+		}
+		var err error
+		switch def := con.(type) {
+		case struct_type.Struct:
+			err = g.genStruct(f, s, def)
+		case union_type.Union:
+			err = g.genUnion(f, s, def)
+		case enum_type.Enum:
+			g.genEnum(f, s, def)
+		case bitset.BitSet:
+			g.genBitSet(f, s, def)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func packageName(s *ast.Scope) string {
+	return strings.ReplaceAll(s.Path(), "::", ".")
+}
+
+func (f *file) printf(format string, args ...any) {
+	fmt.Fprintf(&f.body, format, args...)
+}
+
+// separate puts a blank line between top-level definitions.
+func (f *file) separate() {
+	if f.body.Len() > 0 {
+		f.body.WriteByte('\n')
+	}
+}
+
+// message collects the body of a generated message: nested wrapper
+// messages first, then the fields.
+type message struct {
+	nested []string
+	fields []string
+}
+
+func (f *file) writeMessage(name string, m *message) {
+	f.separate()
+	f.printf("message %s {\n", name)
+	for _, line := range append(m.nested, m.fields...) {
+		f.printf("  %s\n", line)
+	}
+	f.printf("}\n")
+}
+
+func (f *file) render(cfg Config) File {
+	var out bytes.Buffer
+	out.WriteString("// Code generated from IDL. DO NOT EDIT.\n")
+	if cfg.Source != "" {
+		fmt.Fprintf(&out, "// source: %s\n", cfg.Source)
+	}
+	fmt.Fprintf(&out, "\nsyntax = \"proto3\";\n\npackage %s;\n", f.pkg)
+	if len(f.imports) > 0 {
+		imports := make([]string, 0, len(f.imports))
+		for name := range f.imports {
+			imports = append(imports, name)
+		}
+		sort.Strings(imports)
+		out.WriteByte('\n')
+		for _, name := range imports {
+			fmt.Fprintf(&out, "import %q;\n", name)
+		}
+	}
+	out.WriteByte('\n')
+	out.Write(f.body.Bytes())
+	return File{Name: f.name, Package: f.pkg, Content: out.Bytes()}
+}
+
+// fieldNumbers assigns the proto field number of each member.
+func fieldNumbers(owner string, fields []struct_type.Field) ([]int, error) {
+	numbers := make([]int, len(fields))
+	used := make(map[int]string, len(fields))
+	next := 1
+	for i, field := range fields {
+		n := next
+		if anno, ok := field.Annotations.Get(idAnnotation); ok {
+			v, err := strconv.Atoi(anno.Values["value"])
+			if err != nil {
+				return nil, fmt.Errorf("%v member %v: invalid @id %q", owner, field.Name, anno.Values["value"])
+			}
+			n = v
+		}
+		switch {
+		case n < 1 || n > maxFieldNumber:
+			return nil, fmt.Errorf("%v member %v: field number %v out of range 1 to %v", owner, field.Name, n, maxFieldNumber)
+		case n >= firstReservedNumber && n <= lastReservedNumber:
+			return nil, fmt.Errorf("%v member %v: field number %v is reserved by protobuf", owner, field.Name, n)
+		case used[n] != "":
+			return nil, fmt.Errorf("%v member %v: field number %v is already used by %v", owner, field.Name, n, used[n])
+		}
+		used[n] = field.Name
+		numbers[i] = n
+		next = n + 1
+	}
+	return numbers, nil
+}
+
+func (g *generator) genStruct(f *file, s *ast.Scope, st struct_type.Struct) error {
+	name := s.Qualify(st.Name)
+	members, err := s.Members(st)
+	if err != nil {
+		return err
+	}
+	// Proto has no inheritance: inherited fields are copied in, first.
+	fields := make([]struct_type.Field, len(members))
+	for i, member := range members {
+		fields[i] = member.Field
+	}
+	numbers, err := fieldNumbers(name, fields)
+	if err != nil {
+		return err
+	}
+	m := &message{}
+	for i, field := range fields {
+		line, err := g.field(f, members[i].Scope, m, name, field, numbers[i], true)
+		if err != nil {
+			return err
+		}
+		m.fields = append(m.fields, line)
+	}
+	f.writeMessage(st.Name, m)
+	return nil
+}
+
+func (g *generator) genUnion(f *file, s *ast.Scope, u union_type.Union) error {
+	name := s.Qualify(u.Name)
+	fields := make([]struct_type.Field, len(u.Cases))
+	for i, c := range u.Cases {
+		fields[i] = c.Field
+		if c.Default || len(c.Labels) > 1 {
+			g.lose(name+"."+c.Field.Name, "discriminator value of the case is not kept")
+		}
+	}
+	numbers, err := fieldNumbers(name, fields)
+	if err != nil {
+		return err
+	}
+	m := &message{fields: []string{"oneof value {"}}
+	for i, field := range fields {
+		line, err := g.field(f, s, m, name, field, numbers[i], false)
+		if err != nil {
+			return err
+		}
+		m.fields = append(m.fields, "  "+line)
+	}
+	m.fields = append(m.fields, "}")
+	f.writeMessage(u.Name, m)
+	return nil
+}
+
+// field returns the declaration of one member. Sequences that cannot be
+// repeated fields, inside a oneof or as the element of another sequence,
+// are wrapped in a message nested in m.
+func (g *generator) field(f *file, s *ast.Scope, m *message, owner string, field struct_type.Field, number int, canRepeat bool) (string, error) {
+	path := owner + "." + field.Name
+	if _, ok := field.Annotations.Get(defaultAnnotation); ok {
+		g.lose(path, "@default is not kept, proto3 has no field defaults")
+	}
+	if _, ok := field.Annotations.Get(rangeAnnotation); ok {
+		g.lose(path, "@range is not enforced")
+	}
+	t, s := g.resolveTypedef(s, path, field.Type)
+	if !canRepeat && t.TypeRefType() == typ.SequenceType && !isBytes(t) {
+		wrapper := exported(field.Name) + "List"
+		if err := g.wrap(f, s, m, path, wrapper, t); err != nil {
+			return "", err
+		}
+		g.lose(path, "sequence in a oneof is wrapped in message %v", wrapper)
+		return fmt.Sprintf("%s %s = %d;", wrapper, field.Name, number), nil
+	}
+	protoType, repeated, err := g.protoType(f, s, m, path, field.Name, t)
+	if err != nil {
+		return "", err
+	}
+	label := ""
+	if repeated {
+		label = "repeated "
+	}
+	return fmt.Sprintf("%s%s %s = %d;", label, protoType, field.Name, number), nil
+}
+
+// wrap adds to m a message named name holding the sequence t as its single
+// repeated field.
+func (g *generator) wrap(f *file, s *ast.Scope, m *message, path, name string, t typeref.TypeRef) error {
+	elem, _, err := g.protoType(f, s, m, path, name, t)
+	if err != nil {
+		return err
+	}
+	m.nested = append(m.nested, "message "+name+" {", "  repeated "+elem+" items = 1;", "}")
+	return nil
+}
+
+func isBytes(t typeref.TypeRef) bool {
+	seq, ok := t.(typeref.Sequence)
+	return ok && seq.InnerType.TypeRefType() == typ.OctetType
+}
+
+// protoType returns the proto type of t and whether the field is repeated.
+func (g *generator) protoType(f *file, s *ast.Scope, m *message, path, fieldName string, t typeref.TypeRef) (string, bool, error) {
+	t, s = g.resolveTypedef(s, path, t)
+	if sc, ok := scalars[t.TypeRefType()]; ok {
+		if sc.widened {
+			g.lose(path, "%v widened to %v", t.TypeName(), sc.proto)
+		}
+		if str, ok := t.(typeref.StringType); ok && str.Bound > 0 {
+			g.lose(path, "bound %v of the string is not enforced", str.Bound)
+		}
+		return sc.proto, false, nil
+	}
+	switch t.TypeRefType() {
+	case typ.SequenceType:
+		seq := t.(typeref.Sequence)
+		if seq.Bound > 0 {
+			g.lose(path, "bound %v of the sequence is not enforced", seq.Bound)
+		}
+		if isBytes(seq) {
+			return "bytes", false, nil
+		}
+		inner, is := g.resolveTypedef(s, path, seq.InnerType)
+		if inner.TypeRefType() == typ.SequenceType && !isBytes(inner) {
+			wrapper := exported(fieldName) + "Item"
+			if err := g.wrap(f, is, m, path, wrapper, inner); err != nil {
+				return "", false, err
+			}
+			g.lose(path, "nested sequence is wrapped in message %v", wrapper)
+			return wrapper, true, nil
+		}
+		elem, _, err := g.protoType(f, is, m, path, fieldName, inner)
+		return elem, true, err
+	case typ.SelfDefinedTypeType:
+		name, err := g.reference(f, s, t.TypeName())
+		if err != nil {
+			return "", false, fmt.Errorf("%v: %v", path, err)
+		}
+		return name, false, nil
+	}
+	return "", false, fmt.Errorf("%v: unsupported type:%v", path, t.TypeName())
+}
+
+// resolveTypedef replaces a typedef by the type it aliases, each array
+// dimension becoming a sequence, and returns the scope the result is
+// resolved in. Wrapping decisions are made on the result.
+func (g *generator) resolveTypedef(s *ast.Scope, path string, t typeref.TypeRef) (typeref.TypeRef, *ast.Scope) {
+	for t.TypeRefType() == typ.SelfDefinedTypeType {
+		def, owner, err := s.Resolve(t.TypeName())
+		if err != nil {
+			break
+		}
+		td, ok := def.(typedef_type.Typedef)
+		if !ok {
+			break
+		}
+		t, s = td.Aliased, owner
+		for i := len(td.Dims) - 1; i >= 0; i-- {
+			t = typeref.NewSequence(t)
+		}
+		if len(td.Dims) > 0 {
+			g.lose(path, "array %v becomes a repeated field, its length is not enforced", td.Name)
+		}
+	}
+	return t, s
+}
+
+// reference returns the proto name of a named type, qualified when it lives
+// in another package, whose file is then imported.
+func (g *generator) reference(f *file, s *ast.Scope, name string) (string, error) {
+	def, owner, err := s.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := def.(ast.Module); ok {
+		return "", fmt.Errorf("%v is not a type", name)
+	}
+	pkg := packageName(owner)
+	if pkg == f.pkg {
+		return def.GetName(), nil
+	}
+	f.imports[strings.ReplaceAll(owner.Path(), "::", "/")+".proto"] = true
+	return "." + pkg + "." + def.GetName(), nil
+}
+
+// genEnum prints an enum. Enumerators share the scope of the package in
+// proto3, so one that clashes with an earlier enumerator is prefixed with
+// its enum name.
+func (g *generator) genEnum(f *file, s *ast.Scope, e enum_type.Enum) {
+	f.separate()
+	f.printf("enum %s {\n", e.Name)
+	for i, member := range e.Members {
+		value := member
+		if f.enumVals[value] {
+			value = strings.ToUpper(e.Name) + "_" + member
+			g.lose(s.Qualify(e.Name)+"."+member, "renamed to %v, proto3 enumerators share the package scope", value)
+		}
+		f.enumVals[value] = true
+		f.printf("  %s = %d;\n", value, i)
+	}
+	f.printf("}\n")
+}
+
+func (g *generator) genBitSet(f *file, s *ast.Scope, bs bitset.BitSet) {
+	g.lose(s.Qualify(bs.Name), "bitfields are not packed")
+	m := &message{}
+	for i, field := range bs.Fields {
+		protoType := "uint32"
+		if field.Type.Width > 32 {
+			protoType = "uint64"
+		}
+		m.fields = append(m.fields, fmt.Sprintf("%s %s = %d;", protoType, field.Name, i+1))
+	}
+	f.writeMessage(bs.Name, m)
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,264 @@
+package protogen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+const busSchema = `module bus {
+	enum State { IDLE, RUNNING };
+	enum Mode { IDLE, FAST };
+	bitset Flags {
+		bitfield<3> level;
+		bitfield<40> stamp;
+	};
+	module can {
+		struct Frame {
+			octet header;
+			@id(5) unsigned long id;
+			long long stamp;
+			sequence<octet> payload;
+			@default(IDLE) State state;
+			sequence<sequence<float>, 4> matrix;
+		};
+	};
+	union Value switch (long) {
+		case 1: string text;
+		case 2: case 3: can::Frame frame;
+		default: sequence<boolean> bits;
+	};
+	struct Packet {
+		Flags flags;
+		can::Frame frame;
+		Value value;
+		@range(min=0, max=9) unsigned short level;
+	};
+}`
+
+const busProto = `// Code generated from IDL. DO NOT EDIT.
+// source: bus.idl
+
+syntax = "proto3";
+
+package bus;
+
+import "bus/can.proto";
+
+enum State {
+  IDLE = 0;
+  RUNNING = 1;
+}
+
+enum Mode {
+  MODE_IDLE = 0;
+  FAST = 1;
+}
+
+message Flags {
+  uint32 level = 1;
+  uint64 stamp = 2;
+}
+
+message Value {
+  message BitsList {
+    repeated bool items = 1;
+  }
+  oneof value {
+    string text = 1;
+    .bus.can.Frame frame = 2;
+    BitsList bits = 3;
+  }
+}
+
+message Packet {
+  Flags flags = 1;
+  .bus.can.Frame frame = 2;
+  Value value = 3;
+  uint32 level = 4;
+}
+`
+
+const canProto = `// Code generated from IDL. DO NOT EDIT.
+// source: bus.idl
+
+syntax = "proto3";
+
+package bus.can;
+
+import "bus.proto";
+
+message Frame {
+  message MatrixItem {
+    repeated float items = 1;
+  }
+  uint32 header = 1;
+  uint32 id = 5;
+  int64 stamp = 6;
+  bytes payload = 7;
+  .bus.State state = 8;
+  repeated MatrixItem matrix = 9;
+}
+`
+
+func parseModule(t *testing.T, code string) ast.Module {
+	result := ast.Parse(code)
+	require.Nil(t, result.Err)
+	return result.Output
+}
+
+func TestGenerate(t *testing.T) {
+	files, losses, err := Generate(parseModule(t, busSchema), Config{Source: "bus.idl"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "bus.proto", files[0].Name)
+	require.Equal(t, "bus", files[0].Package)
+	require.Equal(t, busProto, string(files[0].Content))
+	require.Equal(t, "bus/can.proto", files[1].Name)
+	require.Equal(t, "bus.can", files[1].Package)
+	require.Equal(t, canProto, string(files[1].Content))
+
+	reasons := make([]string, len(losses))
+	for i, loss := range losses {
+		reasons[i] = loss.String()
+	}
+	require.Equal(t, []string{
+		"bus::Mode.IDLE: renamed to MODE_IDLE, proto3 enumerators share the package scope",
+		"bus::Flags: bitfields are not packed",
+		"bus::can::Frame.header: octet widened to uint32",
+		"bus::can::Frame.state: @default is not kept, proto3 has no field defaults",
+		"bus::can::Frame.matrix: bound 4 of the sequence is not enforced",
+		"bus::can::Frame.matrix: nested sequence is wrapped in message MatrixItem",
+		"bus::Value.frame: discriminator value of the case is not kept",
+		"bus::Value.bits: discriminator value of the case is not kept",
+		"bus::Value.bits: sequence in a oneof is wrapped in message BitsList",
+		"bus::Packet.level: @range is not enforced",
+		"bus::Packet.level: unsigned short widened to uint32",
+	}, reasons)
+}
+
+func TestGenerateTypedefs(t *testing.T) {
+	files, losses, err := Generate(parseModule(t, `module m {
+	typedef double double__3[3];
+	module S_Constants {
+		const int8 MIN = -1;
+	};
+	struct S {
+		int8 a;
+		double__3 b;
+		string<8> c;
+	};
+}`), Config{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Contains(t, string(files[0].Content), `message S {
+  int32 a = 1;
+  repeated double b = 2;
+  string c = 3;
+}`)
+	reasons := make([]string, len(losses))
+	for i, loss := range losses {
+		reasons[i] = loss.String()
+	}
+	require.Equal(t, []string{
+		"m::S_Constants::MIN: constants are not exported",
+		"m::S.a: int8 widened to int32",
+		"m::S.b: array double__3 becomes a repeated field, its length is not enforced",
+		"m::S.c: bound 8 of the string is not enforced",
+	}, reasons)
+}
+
+func TestGenerateTypedefWrappers(t *testing.T) {
+	// Arrays behind a typedef are wrapped like the sequences they become.
+	files, losses, err := Generate(parseModule(t, `module m {
+	typedef long arr[2];
+	union U switch (long) {
+		case 1: arr a;
+		case 2: sequence<long> l;
+	};
+	struct T {
+		sequence<arr> rows;
+		sequence<sequence<long>> cols;
+	};
+}`), Config{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Contains(t, string(files[0].Content), `message U {
+  message AList {
+    repeated int32 items = 1;
+  }
+  message LList {
+    repeated int32 items = 1;
+  }
+  oneof value {
+    AList a = 1;
+    LList l = 2;
+  }
+}
+
+message T {
+  message RowsItem {
+    repeated int32 items = 1;
+  }
+  message ColsItem {
+    repeated int32 items = 1;
+  }
+  repeated RowsItem rows = 1;
+  repeated ColsItem cols = 2;
+}`)
+	reasons := make([]string, len(losses))
+	for i, loss := range losses {
+		reasons[i] = loss.String()
+	}
+	require.Contains(t, reasons, "m::U.a: array arr becomes a repeated field, its length is not enforced")
+	require.Contains(t, reasons, "m::U.a: sequence in a oneof is wrapped in message AList")
+	require.Contains(t, reasons, "m::T.rows: array arr becomes a repeated field, its length is not enforced")
+	require.Contains(t, reasons, "m::T.rows: nested sequence is wrapped in message RowsItem")
+}
+
+func TestGenerateReopenedModule(t *testing.T) {
+	files, _, err := Generate(parseModule(t, `module m {
+	module can { struct Id { long bid; }; };
+	module can { struct Frame { Id id; }; };
+}`), Config{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "m/can.proto", files[0].Name)
+	require.Contains(t, string(files[0].Content), "message Id {")
+	require.Contains(t, string(files[0].Content), "message Frame {")
+}
+
+func TestGenerateInheritance(t *testing.T) {
+	files, _, err := Generate(parseModule(t, `module m {
+	struct Base { long id; };
+	struct Derived : Base { @id(5) string name; double v; };
+}`), Config{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Contains(t, string(files[0].Content), `message Derived {
+  int32 id = 1;
+  string name = 5;
+  double v = 6;
+}`)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		code string
+		err  string
+	}{
+		{`module m { struct S { @id(2) long a; @id(2) long b; }; }`, "m::S member b: field number 2 is already used by a"},
+		{`module m { struct S { @id(1) long a; @id(0) long b; }; }`, "m::S member b: field number 0 out of range 1 to 536870911"},
+		{`module m { struct S { @id(19000) long a; }; }`, "m::S member a: field number 19000 is reserved by protobuf"},
+		{`module m { struct S { @id(x) long a; }; }`, `m::S member a: invalid @id "x"`},
+		{`module m { struct S { Missing a; }; }`, "m::S.a: type Missing not found"},
+		{`module m { struct S : S { long a; }; }`, "struct m::S inherits from itself"},
+		{`module m { union U switch (long) { case 1: long a; case 2: @id(1) long b; }; }`, "m::U member b: field number 1 is already used by a"},
+	}
+	for _, test := range tests {
+		_, _, err := Generate(parseModule(t, test.code), Config{})
+		require.EqualError(t, err, test.err, test.code)
+	}
+}
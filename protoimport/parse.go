@@ -0,0 +1,431 @@
+package protoimport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The proto3 subset understood here: syntax, package and import statements,
+// messages with nested messages, enums and oneofs, and top-level enums.
+// Options, reserved ranges and service bodies are skipped.
+
+type protoFile struct {
+	pkg      string
+	imports  []string
+	messages []*protoMessage
+	enums    []*protoEnum
+	services []string
+}
+
+type protoMessage struct {
+	name     string
+	fields   []protoField
+	messages []*protoMessage
+	enums    []*protoEnum
+}
+
+type protoField struct {
+	label  string
+	typ    string
+	name   string
+	number int
+	// oneof is the oneof holding the field, empty for a plain field.
+	oneof string
+}
+
+type protoEnum struct {
+	name   string
+	values []protoEnumValue
+}
+
+type protoEnumValue struct {
+	name   string
+	number int
+}
+
+type token struct {
+	text string
+	line int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		case c == '"' || c == '\'':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("line %v: unterminated string", line)
+			}
+			i++
+		case c == '_' || c == '.' || isWord(rune(c)):
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || isWord(rune(src[i]))) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, token{text: src[start:i], line: line})
+	}
+	return tokens, nil
+}
+
+func isWord(r rune) bool {
+	return r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		line := 0
+		if len(p.tokens) > 0 {
+			line = p.tokens[len(p.tokens)-1].line
+		}
+		return token{line: line}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	got := tok.text
+	if got == "" {
+		got = "end of file"
+	}
+	return fmt.Errorf("line %v: %v, got %q", tok.line, fmt.Sprintf(format, args...), got)
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.next(); tok.text != text {
+		return p.errorf(tok, "expect %q", text)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	tok := p.next()
+	if tok.text == "" || !(unicode.IsLetter(rune(tok.text[0])) || tok.text[0] == '_' || tok.text[0] == '.') {
+		return "", p.errorf(tok, "expect a name")
+	}
+	return tok.text, nil
+}
+
+func (p *parser) number() (int, error) {
+	tok := p.next()
+	text := tok.text
+	if text == "-" {
+		text += p.next().text
+	}
+	n, err := strconv.ParseInt(text, 0, 32)
+	if err != nil {
+		return 0, p.errorf(tok, "expect a number")
+	}
+	return int(n), nil
+}
+
+// skipStatement skips up to and including the next ";".
+func (p *parser) skipStatement() error {
+	for {
+		switch tok := p.next(); tok.text {
+		case ";":
+			return nil
+		case "":
+			return p.errorf(tok, "expect \";\"")
+		}
+	}
+}
+
+// skipOptions skips a bracketed option list if one follows.
+func (p *parser) skipOptions() error {
+	if p.peek().text != "[" {
+		return nil
+	}
+	for {
+		switch tok := p.next(); tok.text {
+		case "]":
+			return nil
+		case "":
+			return p.errorf(tok, "expect \"]\"")
+		}
+	}
+}
+
+// skipBlock skips a braced body, including nested braces.
+func (p *parser) skipBlock() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		switch tok := p.next(); tok.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case "":
+			return p.errorf(tok, "expect \"}\"")
+		}
+	}
+	return nil
+}
+
+func parseProto(src string) (*protoFile, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f := &protoFile{}
+	if tok := p.peek(); tok.text != "syntax" {
+		return nil, p.errorf(tok, "expect syntax = \"proto3\"")
+	}
+	for p.peek().text != "" {
+		tok := p.next()
+		switch tok.text {
+		case "syntax":
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			if v := p.next(); v.text != `"proto3"` && v.text != `'proto3'` {
+				return nil, p.errorf(v, "only proto3 is supported")
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "package":
+			if f.pkg, err = p.ident(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "import":
+			name := p.next()
+			if name.text == "public" || name.text == "weak" {
+				name = p.next()
+			}
+			f.imports = append(f.imports, strings.Trim(name.text, `"'`))
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		case "message":
+			m, err := p.message()
+			if err != nil {
+				return nil, err
+			}
+			f.messages = append(f.messages, m)
+		case "enum":
+			e, err := p.enum()
+			if err != nil {
+				return nil, err
+			}
+			f.enums = append(f.enums, e)
+		case "service":
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.skipBlock(); err != nil {
+				return nil, err
+			}
+			f.services = append(f.services, name)
+		case ";":
+		default:
+			return nil, p.errorf(tok, "expect a top-level definition")
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) message() (*protoMessage, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &protoMessage{name: name}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		switch tok := p.peek(); tok.text {
+		case "}":
+			p.next()
+			return m, nil
+		case ";":
+			p.next()
+		case "option", "reserved", "extensions":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		case "message":
+			p.next()
+			nested, err := p.message()
+			if err != nil {
+				return nil, err
+			}
+			m.messages = append(m.messages, nested)
+		case "enum":
+			p.next()
+			e, err := p.enum()
+			if err != nil {
+				return nil, err
+			}
+			m.enums = append(m.enums, e)
+		case "oneof":
+			p.next()
+			if err := p.oneof(m); err != nil {
+				return nil, err
+			}
+		case "map":
+			return nil, p.errorf(tok, "map fields are not supported")
+		case "":
+			return nil, p.errorf(tok, "expect \"}\"")
+		default:
+			field, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			m.fields = append(m.fields, field)
+		}
+	}
+}
+
+func (p *parser) oneof(m *protoMessage) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		switch p.peek().text {
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			field, err := p.field()
+			if err != nil {
+				return err
+			}
+			field.oneof = name
+			m.fields = append(m.fields, field)
+		}
+	}
+}
+
+func (p *parser) field() (protoField, error) {
+	var f protoField
+	if text := p.peek().text; text == "repeated" || text == "optional" {
+		f.label = p.next().text
+	}
+	var err error
+	if f.typ, err = p.ident(); err != nil {
+		return f, err
+	}
+	if f.name, err = p.ident(); err != nil {
+		return f, err
+	}
+	if err := p.expect("="); err != nil {
+		return f, err
+	}
+	if f.number, err = p.number(); err != nil {
+		return f, err
+	}
+	if err := p.skipOptions(); err != nil {
+		return f, err
+	}
+	return f, p.expect(";")
+}
+
+func (p *parser) enum() (*protoEnum, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &protoEnum{name: name}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().text {
+		case "}":
+			p.next()
+			return e, nil
+		case ";":
+			p.next()
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		default:
+			value, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			number, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.skipOptions(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			e.values = append(e.values, protoEnumValue{name: value, number: number})
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package protoimport builds an IDL module tree from a proto3 file, so that
+// protobuf definitions can be printed as IDL or decoded with the converter.
+package protoimport
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+	"github.com/yisaer/idl-parser/protogen"
+)
+
+var scalars = map[string]typeref.TypeRef{
+	"int32":    typeref.NewLongType(),
+	"sint32":   typeref.NewLongType(),
+	"sfixed32": typeref.NewLongType(),
+	"uint32":   typeref.NewUnsignedLong(),
+	"fixed32":  typeref.NewUnsignedLong(),
+	"int64":    typeref.NewLongLongType(),
+	"sint64":   typeref.NewLongLongType(),
+	"sfixed64": typeref.NewLongLongType(),
+	"uint64":   typeref.NewUnsignedLongLong(),
+	"fixed64":  typeref.NewUnsignedLongLong(),
+	"bool":     typeref.NewBooleanType(),
+	"float":    typeref.NewFloatType(),
+	"double":   typeref.NewDoubleType(),
+	"string":   typeref.NewStringType(),
+	"bytes":    typeref.NewSequence(typeref.NewOctetType()),
+}
+
+// Parse reads a proto3 file and returns the equivalent module tree: the
+// package becomes nested modules, messages structs and enums enums. Nested
+// messages and enums are hoisted into the package module as Outer_Inner, a
+// message made of a single oneof becomes a union discriminated by field
+// number, and any other oneof becomes a union member of its message. Field
+// numbers are kept as @id annotations. A nested message holding only
+// "repeated T items = 1", as protogen emits for nested sequences, is read
+// back as a sequence unless its items lead back to it. Mappings that lose information are returned as a
+// report.
+func Parse(src string) (ast.Module, []protogen.Loss, error) {
+	f, err := parseProto(src)
+	if err != nil {
+		return ast.Module{}, nil, err
+	}
+	if f.pkg == "" {
+		return ast.Module{}, nil, fmt.Errorf("proto file has no package")
+	}
+	im := &importer{defs: make(map[string]*definition)}
+	for _, name := range f.imports {
+		im.lose(name, "import is not followed, its types stay unresolved names")
+	}
+	for _, name := range f.services {
+		im.lose(f.pkg+"."+name, "services are not imported")
+	}
+	for _, e := range f.enums {
+		im.collectEnum(f.pkg, "", e)
+	}
+	for _, m := range f.messages {
+		im.collectMessage(f.pkg, "", m, false)
+	}
+	im.unwrapCycles()
+	for _, e := range f.enums {
+		im.genEnum(f.pkg, e)
+	}
+	for _, m := range f.messages {
+		if err := im.genMessage(f.pkg, m); err != nil {
+			return ast.Module{}, nil, err
+		}
+	}
+
+	parts := strings.Split(f.pkg, ".")
+	module := ast.Module{
+		Name:    parts[len(parts)-1],
+		Content: im.content,
+		Type:    typ.ModuleContentTypeToString(typ.ModuleType),
+	}
+	for i := len(parts) - 2; i >= 0; i-- {
+		module = ast.Module{
+			Name:    parts[i],
+			Content: []ast.ModuleContent{module},
+			Type:    typ.ModuleContentTypeToString(typ.ModuleType),
+		}
+	}
+	return module, im.losses, nil
+}
+
+// definition is a message or enum known by its full proto name.
+type definition struct {
+	idlName string
+	// wrapper is the message of a protogen sequence wrapper.
+	wrapper *protoMessage
+	scope   string
+}
+
+type importer struct {
+	defs    map[string]*definition
+	content []ast.ModuleContent
+	losses  []protogen.Loss
+}
+
+func (im *importer) lose(name, format string, args ...any) {
+	im.losses = append(im.losses, protogen.Loss{Name: name, Reason: fmt.Sprintf(format, args...)})
+}
+
+func hoisted(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+func (im *importer) collectEnum(scope, prefix string, e *protoEnum) {
+	im.defs[scope+"."+e.name] = &definition{idlName: hoisted(prefix, e.name)}
+}
+
+func (im *importer) collectMessage(scope, prefix string, m *protoMessage, nested bool) {
+	full := scope + "." + m.name
+	def := &definition{idlName: hoisted(prefix, m.name), scope: full}
+	if nested && isWrapper(m) {
+		def.wrapper = m
+	}
+	im.defs[full] = def
+	for _, e := range m.enums {
+		im.collectEnum(full, def.idlName, e)
+	}
+	for _, nested := range m.messages {
+		im.collectMessage(full, def.idlName, nested, true)
+	}
+}
+
+// unwrapCycles keeps wrappers whose items lead back to themselves as
+// messages, since reading them as sequences would never end.
+func (im *importer) unwrapCycles() {
+	for _, start := range im.defs {
+		var path []*definition
+		for def := start; def != nil && def.wrapper != nil; {
+			if slices.Contains(path, def) {
+				if def == start {
+					for _, d := range path {
+						d.wrapper = nil
+					}
+				}
+				break
+			}
+			path = append(path, def)
+			def, _ = im.resolve(def.wrapper.fields[0].typ, def.scope)
+		}
+	}
+}
+
+func isWrapper(m *protoMessage) bool {
+	return len(m.fields) == 1 && len(m.messages) == 0 && len(m.enums) == 0 &&
+		m.fields[0].label == "repeated" && m.fields[0].name == "items" &&
+		m.fields[0].number == 1 && m.fields[0].oneof == ""
+}
+
+// resolve follows the proto scoping rules: a relative name is searched in
+// scope and then in each enclosing scope.
+func (im *importer) resolve(name, scope string) (*definition, bool) {
+	if strings.HasPrefix(name, ".") {
+		def, ok := im.defs[name[1:]]
+		return def, ok
+	}
+	for {
+		if def, ok := im.defs[scope+"."+name]; ok {
+			return def, true
+		}
+		i := strings.LastIndex(scope, ".")
+		if i < 0 {
+			break
+		}
+		scope = scope[:i]
+	}
+	def, ok := im.defs[name]
+	return def, ok
+}
+
+func (im *importer) genEnum(scope string, e *protoEnum) {
+	def := im.defs[scope+"."+e.name]
+	members := make([]string, len(e.values))
+	for i, v := range e.values {
+		members[i] = v.name
+		if v.number != i {
+			im.lose(scope+"."+e.name+"."+v.name, "number %v is not kept, IDL enumerators count from 0", v.number)
+		}
+	}
+	im.content = append(im.content, enum_type.Enum{
+		Name:    def.idlName,
+		Members: members,
+		Type:    typ.ModuleContentTypeToString(typ.EnumType),
+	})
+}
+
+func (im *importer) genMessage(scope string, m *protoMessage) error {
+	full := scope + "." + m.name
+	def := im.defs[full]
+	if def.wrapper != nil {
+		return nil
+	}
+	for _, e := range m.enums {
+		im.genEnum(full, e)
+	}
+	for _, nested := range m.messages {
+		if err := im.genMessage(full, nested); err != nil {
+			return err
+		}
+	}
+
+	if onlyOneof(m) {
+		u, err := im.union(full, def.idlName, m.fields)
+		if err != nil {
+			return err
+		}
+		im.content = append(im.content, u)
+		return nil
+	}
+
+	st := struct_type.Struct{Name: def.idlName, Type: typ.ModuleContentTypeToString(typ.StructType)}
+	var unions []ast.ModuleContent
+	for i := 0; i < len(m.fields); i++ {
+		field := m.fields[i]
+		if field.oneof == "" {
+			member, err := im.field(full, field)
+			if err != nil {
+				return err
+			}
+			st.Fields = append(st.Fields, member)
+			continue
+		}
+		j := i
+		for j < len(m.fields) && m.fields[j].oneof == field.oneof {
+			j++
+		}
+		unionName := hoisted(def.idlName, field.oneof)
+		u, err := im.union(full, unionName, m.fields[i:j])
+		if err != nil {
+			return err
+		}
+		unions = append(unions, u)
+		im.lose(full+"."+field.oneof, "oneof becomes member %v of union type %v", field.oneof, unionName)
+		st.Fields = append(st.Fields, struct_type.Field{
+			Annotations: idAnnotation(field.number),
+			Type:        typeref.TypeName{Name: unionName, SelfType: unionName},
+			Name:        field.oneof,
+		})
+		i = j - 1
+	}
+	im.content = append(im.content, unions...)
+	im.content = append(im.content, st)
+	return nil
+}
+
+func onlyOneof(m *protoMessage) bool {
+	for _, field := range m.fields {
+		if field.oneof == "" || field.oneof != m.fields[0].oneof {
+			return false
+		}
+	}
+	return len(m.fields) > 0
+}
+
+// union builds a union discriminated by the field numbers of fields.
+func (im *importer) union(scope, name string, fields []protoField) (union_type.Union, error) {
+	u := union_type.Union{
+		Name:          name,
+		Discriminator: typeref.NewLongType(),
+		Type:          typ.ModuleContentTypeToString(typ.UnionType),
+	}
+	for _, field := range fields {
+		member, err := im.field(scope, field)
+		if err != nil {
+			return u, err
+		}
+		u.Cases = append(u.Cases, union_type.Case{
+			Labels: []string{strconv.Itoa(field.number)},
+			Field:  member,
+		})
+	}
+	return u, nil
+}
+
+func idAnnotation(number int) annotation.Annotations {
+	return annotation.Annotations{{Name: "id", Values: map[string]string{"value": strconv.Itoa(number)}}}
+}
+
+func (im *importer) field(scope string, field protoField) (struct_type.Field, error) {
+	path := scope + "." + field.name
+	if field.label == "optional" {
+		im.lose(path, "field presence is not kept")
+	}
+	t, err := im.typeRef(scope, path, field.typ)
+	if err != nil {
+		return struct_type.Field{}, err
+	}
+	if field.label == "repeated" {
+		t = typeref.NewSequence(t)
+	}
+	return struct_type.Field{
+		Annotations: idAnnotation(field.number),
+		Type:        t,
+		Name:        field.name,
+	}, nil
+}
+
+func (im *importer) typeRef(scope, path, name string) (typeref.TypeRef, error) {
+	if t, ok := scalars[name]; ok {
+		return t, nil
+	}
+	def, ok := im.resolve(name, scope)
+	if !ok {
+		idl := strings.ReplaceAll(name, ".", "::")
+		return typeref.TypeName{Name: idl, SelfType: idl}, nil
+	}
+	if def.wrapper != nil {
+		elem, err := im.typeRef(def.scope, path, def.wrapper.fields[0].typ)
+		if err != nil {
+			return nil, err
+		}
+		return typeref.NewSequence(elem), nil
+	}
+	return typeref.TypeName{Name: def.idlName, SelfType: def.idlName}, nil
+}
@@ -0,0 +1,211 @@
+package protoimport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/converter"
+	"github.com/yisaer/idl-parser/printer"
+	"github.com/yisaer/idl-parser/protogen"
+)
+
+const sensorProto = `// Sensor readings.
+syntax = "proto3";
+
+package fleet.sensor;
+
+import "google/protobuf/timestamp.proto";
+option go_package = "example.com/fleet/sensor";
+
+/* Operating state. */
+enum State {
+  STATE_IDLE = 0;
+  STATE_RUNNING = 1;
+}
+
+message Reading {
+  enum Unit {
+    CELSIUS = 0;
+    KELVIN = 2;
+  }
+  message Sample {
+    double value = 1;
+    Unit unit = 2;
+  }
+  uint32 device_id = 1;
+  repeated Sample samples = 2 [packed = true];
+  State state = 4;
+  oneof source {
+    string name = 5;
+    int64 serial = 6;
+  }
+  bytes raw = 7;
+  optional bool valid = 8;
+  google.protobuf.Timestamp at = 9;
+  reserved 3;
+}
+
+message Value {
+  oneof value {
+    string text = 1;
+    fixed64 count = 2;
+  }
+}
+
+service Sensors {
+  rpc Get (Reading) returns (Value);
+}
+`
+
+const sensorIDL = `module fleet {
+	module sensor {
+		enum State {
+			STATE_IDLE,
+			STATE_RUNNING
+		};
+
+		enum Reading_Unit {
+			CELSIUS,
+			KELVIN
+		};
+
+		struct Reading_Sample {
+			@id(1) double value;
+			@id(2) Reading_Unit unit;
+		};
+
+		union Reading_source switch (long) {
+			case 5:
+				@id(5) string name;
+			case 6:
+				@id(6) long long serial;
+		};
+
+		struct Reading {
+			@id(1) unsigned long device_id;
+			@id(2) sequence<Reading_Sample> samples;
+			@id(4) State state;
+			@id(5) Reading_source source;
+			@id(7) sequence<octet> raw;
+			@id(8) boolean valid;
+			@id(9) google::protobuf::Timestamp at;
+		};
+
+		union Value switch (long) {
+			case 1:
+				@id(1) string text;
+			case 2:
+				@id(2) unsigned long long count;
+		};
+	};
+}
+`
+
+func TestParse(t *testing.T) {
+	module, losses, err := Parse(sensorProto)
+	require.NoError(t, err)
+	require.Equal(t, sensorIDL, string(printer.Print(module)))
+
+	reasons := make([]string, len(losses))
+	for i, loss := range losses {
+		reasons[i] = loss.String()
+	}
+	require.Equal(t, []string{
+		"google/protobuf/timestamp.proto: import is not followed, its types stay unresolved names",
+		"fleet.sensor.Sensors: services are not imported",
+		"fleet.sensor.Reading.Unit.KELVIN: number 2 is not kept, IDL enumerators count from 0",
+		"fleet.sensor.Reading.source: oneof becomes member source of union type Reading_source",
+		"fleet.sensor.Reading.valid: field presence is not kept",
+	}, reasons)
+
+	again := ast.Parse(sensorIDL)
+	require.Nil(t, again.Err)
+	require.Equal(t, module, again.Output)
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	schema := ast.Parse(`module bus {
+	struct Sample {
+		@id(3) long long stamp;
+		sequence<sequence<float>> matrix;
+		sequence<octet> raw;
+	};
+}`)
+	require.Nil(t, schema.Err)
+	files, _, err := protogen.Generate(schema.Output, protogen.Config{})
+	require.NoError(t, err)
+	module, _, err := Parse(string(files[0].Content))
+	require.NoError(t, err)
+
+	require.Equal(t, `module bus {
+	struct Sample {
+		@id(3) long long stamp;
+		@id(4) sequence<sequence<float>> matrix;
+		@id(5) sequence<octet> raw;
+	};
+}
+`, string(printer.Print(module)))
+
+	c := &converter.IDLConverter{Module: module, TypeName: "bus::Sample"}
+	require.NoError(t, c.Init())
+	m, err := c.Decode([]byte{
+		0, 0, 0, 0, 0, 0, 0, 7,
+		0, 0, 0, 0,
+		0, 0, 0, 1, 0xAB,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), m["stamp"])
+	require.Equal(t, []interface{}{int64(0xAB)}, m["raw"])
+}
+
+func TestRecursiveWrapper(t *testing.T) {
+	module, _, err := Parse(`syntax = "proto3";
+package r;
+message M {
+  message W { repeated W items = 1; }
+  W x = 1;
+  message P { repeated Q items = 1; }
+  message Q { repeated P items = 1; }
+  P p = 2;
+}`)
+	require.NoError(t, err)
+	require.Equal(t, `module r {
+	struct M_W {
+		@id(1) sequence<M_W> items;
+	};
+
+	struct M_P {
+		@id(1) sequence<M_Q> items;
+	};
+
+	struct M_Q {
+		@id(1) sequence<M_P> items;
+	};
+
+	struct M {
+		@id(1) M_W x;
+		@id(2) M_P p;
+	};
+}
+`, string(printer.Print(module)))
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`package a;`, `line 1: expect syntax = "proto3", got "package"`},
+		{`syntax = "proto2"; package a;`, `line 1: only proto3 is supported, got "\"proto2\""`},
+		{`syntax = "proto3"; message A {}`, "proto file has no package"},
+		{"syntax = \"proto3\";\npackage a;\nmessage A {\n  map<string, int32> m = 1;\n}", `line 4: map fields are not supported, got "map"`},
+		{"syntax = \"proto3\";\npackage a;\nmessage A {\n  int32 a = ;\n}", `line 4: expect a number, got ";"`},
+		{"syntax = \"proto3\";\npackage a;\nmessage A {\n  int32 a = 1;\n", `line 4: expect "}", got "end of file"`},
+	}
+	for _, test := range tests {
+		_, _, err := Parse(test.src)
+		require.EqualError(t, err, test.err, test.src)
+	}
+}
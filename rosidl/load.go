@@ -0,0 +1,77 @@
+package rosidl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/utils"
+)
+
+var includeDirective = regexp.MustCompile(`(?m)^[ \t]*#include[ \t]+["<]([^">]+)[">]`)
+
+// Load parses the IDL file at path and every file it includes with
+// #include "pkg/msg/Name.idl", and merges them into one module tree with
+// ast.Merge, included files first. An include is looked up next to the
+// including file and then in each of includeDirs; every file is read once.
+func Load(path string, includeDirs ...string) (ast.Module, error) {
+	l := &loader{dirs: includeDirs, seen: make(map[string]bool)}
+	if err := l.load(path); err != nil {
+		return ast.Module{}, err
+	}
+	return ast.Merge(l.modules...), nil
+}
+
+type loader struct {
+	dirs    []string
+	seen    map[string]bool
+	modules []ast.Module
+}
+
+func (l *loader) load(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.seen[abs] {
+		return nil
+	}
+	l.seen[abs] = true
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, m := range includeDirective.FindAllStringSubmatch(string(src), -1) {
+		included, err := l.find(m[1], filepath.Dir(path))
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		if err := l.load(included); err != nil {
+			return err
+		}
+	}
+	res := ast.Parse(string(src))
+	if res.Err != nil {
+		return fmt.Errorf("%v: %v", path, res.Err)
+	}
+	if rest := utils.ParseEmpty0(res.Remaining).Remaining; rest != "" {
+		return fmt.Errorf("%v: unexpected content after module %v", path, res.Output.Name)
+	}
+	l.modules = append(l.modules, res.Output)
+	return nil
+}
+
+func (l *loader) find(name, dir string) (string, error) {
+	for _, d := range append([]string{dir}, l.dirs...) {
+		candidate := filepath.Join(d, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("include %q not found", name)
+}
@@ -0,0 +1,78 @@
+package rosidl
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/converter"
+)
+
+func TestLoad(t *testing.T) {
+	m, err := Load("testdata/sensor_msgs/msg/Imu.idl", "testdata")
+	require.NoError(t, err)
+	require.Equal(t, "", m.Name)
+	var names []string
+	for _, c := range m.Content {
+		names = append(names, c.GetName())
+	}
+	require.Equal(t, []string{"builtin_interfaces", "std_msgs", "sensor_msgs"}, names)
+
+	_, err = Load("testdata/sensor_msgs/msg/Imu.idl")
+	require.EqualError(t, err, `testdata/sensor_msgs/msg/Imu.idl: include "std_msgs/msg/Header.idl" not found`)
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Bad.idl")
+	require.NoError(t, os.WriteFile(path, []byte("module m { struct S { long a; }; };\nstruct T {};"), 0o644))
+	_, err := Load(path)
+	require.EqualError(t, err, path+": unexpected content after module m")
+}
+
+// TestDecodeCDR decodes a sensor_msgs/Imu payload as a ROS 2 publisher
+// sends it: little-endian CDR with the generated IDL as schema.
+func TestDecodeCDR(t *testing.T) {
+	m, err := Load("testdata/sensor_msgs/msg/Imu.idl", "testdata")
+	require.NoError(t, err)
+	c := &converter.IDLConverter{Module: m, TypeName: "sensor_msgs::msg::Imu", Encoding: converter.EncodingCDR}
+	require.NoError(t, c.Init())
+
+	data := []byte{
+		0x00, 0x01, 0x00, 0x03,
+		0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+		0x04, 0x00, 0x00, 0x00, 'i', 'm', 'u', 0x00,
+		0, 0, 0, 0, 0, 0, 0xF0, 0x3F,
+	}
+	covariance := make([]interface{}, 9)
+	for i := range covariance {
+		covariance[i] = 0.0
+		data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	covariance[8] = 0.5
+	binaryHalf := math.Float64bits(0.5)
+	for i := 0; i < 8; i++ {
+		data[len(data)-8+i] = byte(binaryHalf >> (8 * i))
+	}
+	data = append(data, 0xFF, 0, 0, 0)
+
+	want := map[string]interface{}{
+		"header": map[string]interface{}{
+			"stamp":    map[string]interface{}{"sec": int64(1), "nanosec": int64(2)},
+			"frame_id": "imu",
+		},
+		"orientation_x":          1.0,
+		"orientation_covariance": covariance,
+		"status":                 int64(-1),
+	}
+	got, err := c.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	encoded, err := c.Encode(want)
+	require.NoError(t, err)
+	require.Equal(t, data, encoded)
+}
@@ -0,0 +1,425 @@
+// Package rosidl imports ROS 2 interface definitions. ParseMsg and ParseSrv
+// turn .msg and .srv files into the module tree that rosidl_adapter would
+// generate as IDL, and Load reads ROS-generated .idl files together with the
+// files they include.
+package rosidl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/annotation"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+// primitive is a ROS primitive type: its spelling in generated IDL and its
+// type reference.
+type primitive struct {
+	idl string
+	ref func() typeref.TypeRef
+}
+
+var primitives = map[string]primitive{
+	"bool":    {"boolean", func() typeref.TypeRef { return typeref.NewBooleanType() }},
+	"byte":    {"octet", func() typeref.TypeRef { return typeref.NewOctetType() }},
+	"char":    {"uint8", func() typeref.TypeRef { return typeref.NewOctetType() }},
+	"float32": {"float", func() typeref.TypeRef { return typeref.NewFloatType() }},
+	"float64": {"double", func() typeref.TypeRef { return typeref.NewDoubleType() }},
+	"int8":    {"int8", func() typeref.TypeRef { return typeref.NewInt8Type() }},
+	"uint8":   {"uint8", func() typeref.TypeRef { return typeref.NewOctetType() }},
+	"int16":   {"int16", func() typeref.TypeRef { return typeref.NewShortType() }},
+	"uint16":  {"uint16", func() typeref.TypeRef { return typeref.NewUnsignedShortType() }},
+	"int32":   {"int32", func() typeref.TypeRef { return typeref.NewLongType() }},
+	"uint32":  {"uint32", func() typeref.TypeRef { return typeref.NewUnsignedLong() }},
+	"int64":   {"int64", func() typeref.TypeRef { return typeref.NewLongLongType() }},
+	"uint64":  {"uint64", func() typeref.TypeRef { return typeref.NewUnsignedLongLong() }},
+	"string":  {"string", func() typeref.TypeRef { return typeref.NewStringType() }},
+}
+
+// emptyMember is the member rosidl adds to a message without fields, since
+// IDL structs cannot be empty.
+const emptyMember = "structure_needs_at_least_one_member"
+
+var (
+	fieldName    = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	constantName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	typeName     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+// ParseMsg parses the .msg file of message name in package pkg and returns
+// the module pkg::msg holding the struct name. Fixed-size arrays become
+// typedefs named like rosidl names them, e.g. double__36, constants are
+// placed in a module name_Constants, default values become @default
+// annotations and comments @verbatim annotations. Type references such as
+// geometry_msgs/Point are left for the caller to provide, e.g. with Load.
+func ParseMsg(pkg, name, src string) (ast.Module, error) {
+	b := newBuilder(pkg)
+	if err := b.message(name, src); err != nil {
+		return ast.Module{}, err
+	}
+	return b.module("msg"), nil
+}
+
+// ParseSrv parses the .srv file of service name in package pkg and returns
+// the module pkg::srv holding the structs name_Request and name_Response,
+// split at the "---" line.
+func ParseSrv(pkg, name, src string) (ast.Module, error) {
+	request, response, ok := splitService(src)
+	if !ok {
+		return ast.Module{}, fmt.Errorf("service %v has no \"---\" separator", name)
+	}
+	b := newBuilder(pkg)
+	if err := b.message(name+"_Request", request); err != nil {
+		return ast.Module{}, err
+	}
+	if err := b.message(name+"_Response", response); err != nil {
+		return ast.Module{}, err
+	}
+	return b.module("srv"), nil
+}
+
+func splitService(src string) (string, string, bool) {
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			return strings.Join(lines[:i], "\n"), strings.Join(lines[i+1:], "\n"), true
+		}
+	}
+	return "", "", false
+}
+
+type builder struct {
+	pkg      string
+	typedefs []ast.ModuleContent
+	defined  map[string]bool
+	content  []ast.ModuleContent
+}
+
+func newBuilder(pkg string) *builder {
+	return &builder{pkg: pkg, defined: make(map[string]bool)}
+}
+
+func (b *builder) module(sub string) ast.Module {
+	inner := ast.Module{
+		Name:    sub,
+		Content: append(b.typedefs, b.content...),
+		Type:    typ.ModuleContentTypeToString(typ.ModuleType),
+	}
+	return ast.Module{
+		Name:    b.pkg,
+		Content: []ast.ModuleContent{inner},
+		Type:    typ.ModuleContentTypeToString(typ.ModuleType),
+	}
+}
+
+// line is one declaration of a .msg file with the comments attached to it.
+type line struct {
+	number   int
+	typ      string
+	name     string
+	value    string
+	constant bool
+	comments []string
+}
+
+func (b *builder) message(name, src string) error {
+	if !typeName.MatchString(name) {
+		return fmt.Errorf("invalid message name %q", name)
+	}
+	header, lines, err := scanMessage(src)
+	if err != nil {
+		return fmt.Errorf("%v %v", name, err)
+	}
+	st := struct_type.Struct{
+		Annotations: verbatim(header),
+		Name:        name,
+		Type:        typ.ModuleContentTypeToString(typ.StructType),
+	}
+	var consts []ast.ModuleContent
+	for _, l := range lines {
+		if err := b.declaration(&st, &consts, l); err != nil {
+			return fmt.Errorf("%v line %v: %v", name, l.number, err)
+		}
+	}
+	if len(st.Fields) == 0 {
+		st.Fields = []struct_type.Field{{Type: typeref.NewOctetType(), Name: emptyMember}}
+	}
+	if len(consts) > 0 {
+		b.content = append(b.content, ast.Module{
+			Name:    name + "_Constants",
+			Content: consts,
+			Type:    typ.ModuleContentTypeToString(typ.ModuleType),
+		})
+	}
+	b.content = append(b.content, st)
+	return nil
+}
+
+func (b *builder) declaration(st *struct_type.Struct, consts *[]ast.ModuleContent, l line) error {
+	if l.constant && strings.Contains(l.typ, "[") {
+		return fmt.Errorf("constant %v cannot be an array", l.name)
+	}
+	t, err := b.typeRef(l.typ)
+	if err != nil {
+		return err
+	}
+	if l.constant {
+		if !constantName.MatchString(l.name) {
+			return fmt.Errorf("invalid constant name %q", l.name)
+		}
+		if t.TypeRefType() == typ.SelfDefinedTypeType {
+			return fmt.Errorf("constant %v must have a primitive type", l.name)
+		}
+		value, err := constValue(t, l.value)
+		if err != nil {
+			return fmt.Errorf("constant %v: %v", l.name, err)
+		}
+		*consts = append(*consts, const_type.Const{
+			Annotations: verbatim(l.comments),
+			Name:        l.name,
+			ValueType:   t,
+			Value:       value,
+			Type:        typ.ModuleContentTypeToString(typ.ConstType),
+		})
+		return nil
+	}
+	if !fieldName.MatchString(l.name) {
+		return fmt.Errorf("invalid field name %q", l.name)
+	}
+	for _, f := range st.Fields {
+		if f.Name == l.name {
+			return fmt.Errorf("field %v is declared twice", l.name)
+		}
+	}
+	annos := verbatim(l.comments)
+	if l.value != "" {
+		value, err := defaultValue(t, l.value)
+		if err != nil {
+			return fmt.Errorf("field %v: %v", l.name, err)
+		}
+		annos = append(annos, annotation.Annotation{Name: "default", Values: map[string]string{"value": value}})
+	}
+	st.Fields = append(st.Fields, struct_type.Field{Annotations: annos, Type: t, Name: l.name})
+	return nil
+}
+
+// scanMessage splits a message into declarations. Comment lines right
+// before a declaration and a comment at the end of its line are attached to
+// it; a comment block at the top of the file that is followed by a blank
+// line describes the message.
+func scanMessage(src string) ([]string, []line, error) {
+	var header, pending []string
+	var lines []line
+	top := true
+	for i, text := range strings.Split(src, "\n") {
+		code, comment, hasComment := cutComment(text)
+		code = strings.TrimSpace(code)
+		if code == "" {
+			switch {
+			case hasComment:
+				pending = append(pending, comment)
+			case top && len(pending) > 0:
+				header, pending, top = pending, nil, false
+			default:
+				pending = nil
+			}
+			continue
+		}
+		top = false
+		l, err := parseLine(code)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %v: %v", i+1, err)
+		}
+		l.number = i + 1
+		l.comments = pending
+		if hasComment {
+			l.comments = append(l.comments, comment)
+		}
+		pending = nil
+		lines = append(lines, l)
+	}
+	return header, lines, nil
+}
+
+// cutComment splits text at a "#" that is not inside a quoted value.
+func cutComment(text string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return text[:i], strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return text, "", false
+}
+
+// parseLine parses "type name [default]" or "type NAME=value".
+func parseLine(code string) (line, error) {
+	fields := strings.Fields(code)
+	if len(fields) < 2 {
+		return line{}, fmt.Errorf("expect a type and a name, got %q", code)
+	}
+	t := fields[0]
+	rest := strings.TrimSpace(code[len(t):])
+	if name, value, ok := strings.Cut(rest, "="); ok && !strings.ContainsAny(strings.TrimSpace(name), " \t") {
+		return line{typ: t, name: strings.TrimSpace(name), value: strings.TrimSpace(value), constant: true}, nil
+	}
+	name := fields[1]
+	return line{typ: t, name: name, value: strings.TrimSpace(rest[len(name):])}, nil
+}
+
+// typeRef maps a ROS type, with an optional array suffix, to a type
+// reference, adding the typedef of a fixed-size array on first use.
+func (b *builder) typeRef(ros string) (typeref.TypeRef, error) {
+	base, suffix, array := strings.Cut(ros, "[")
+	elem, idl, err := b.baseType(base)
+	if err != nil {
+		return nil, err
+	}
+	if !array {
+		return elem, nil
+	}
+	size, ok := strings.CutSuffix(suffix, "]")
+	if !ok {
+		return nil, fmt.Errorf("invalid array type %q", ros)
+	}
+	switch {
+	case size == "":
+		return typeref.NewSequence(elem), nil
+	case strings.HasPrefix(size, "<="):
+		bound, err := positive(size[2:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array bound in %q", ros)
+		}
+		return typeref.NewBoundedSequence(elem, bound), nil
+	}
+	n, err := positive(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid array size in %q", ros)
+	}
+	name := typedefName(idl) + "__" + size
+	if !b.defined[name] {
+		b.defined[name] = true
+		b.typedefs = append(b.typedefs, typedef_type.Typedef{
+			Name:    name,
+			Aliased: elem,
+			Dims:    []int{n},
+			Type:    typ.ModuleContentTypeToString(typ.TypedefType),
+		})
+	}
+	return typeref.TypeName{Name: name, SelfType: name}, nil
+}
+
+// baseType returns the type reference of a ROS type without array suffix
+// together with its IDL spelling.
+func (b *builder) baseType(ros string) (typeref.TypeRef, string, error) {
+	if p, ok := primitives[ros]; ok {
+		return p.ref(), p.idl, nil
+	}
+	if bound, ok := strings.CutPrefix(ros, "string<="); ok {
+		n, err := positive(bound)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid string bound in %q", ros)
+		}
+		return typeref.NewBoundedString(n), fmt.Sprintf("string<%d>", n), nil
+	}
+	if ros == "wstring" || strings.HasPrefix(ros, "wstring<=") {
+		return nil, "", fmt.Errorf("wstring is not supported")
+	}
+	pkg, name, qualified := strings.Cut(ros, "/")
+	switch {
+	case !qualified && ros == "Header":
+		pkg, name = "std_msgs", "Header"
+	case !qualified:
+		pkg, name = b.pkg, ros
+	}
+	if !typeName.MatchString(pkg) || !typeName.MatchString(name) {
+		return nil, "", fmt.Errorf("invalid type %q", ros)
+	}
+	scoped := pkg + "::msg::" + name
+	return typeref.TypeName{Name: scoped, SelfType: scoped}, scoped, nil
+}
+
+var typedefNamer = strings.NewReplacer("::", "__", "<", "_", ">", "")
+
+// typedefName returns the name of the typedef of an array of the IDL type
+// idl, without the size, e.g. double or geometry_msgs__msg__Point.
+func typedefName(idl string) string {
+	return typedefNamer.Replace(idl)
+}
+
+func positive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err == nil && n <= 0 {
+		err = fmt.Errorf("%v is not positive", n)
+	}
+	return n, err
+}
+
+// defaultValue converts a .msg default to the value of a @default
+// annotation: strings lose their quotes and booleans are spelled TRUE or
+// FALSE. Array defaults are kept as written.
+func defaultValue(t typeref.TypeRef, value string) (string, error) {
+	switch t.TypeRefType() {
+	case typ.StringType:
+		return unquote(value), nil
+	case typ.BooleanType:
+		return boolValue(value)
+	}
+	return value, nil
+}
+
+// constValue converts a .msg constant value to an IDL literal.
+func constValue(t typeref.TypeRef, value string) (string, error) {
+	switch t.TypeRefType() {
+	case typ.StringType:
+		return strconv.Quote(unquote(value)), nil
+	case typ.BooleanType:
+		return boolValue(value)
+	}
+	if value == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return value, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		inner := value[1 : len(value)-1]
+		return strings.ReplaceAll(inner, `\`+value[:1], value[:1])
+	}
+	return value
+}
+
+func boolValue(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "true", "1":
+		return "TRUE", nil
+	case "false", "0":
+		return "FALSE", nil
+	}
+	return "", fmt.Errorf("invalid boolean %q", value)
+}
+
+func verbatim(comments []string) annotation.Annotations {
+	if len(comments) == 0 {
+		return nil
+	}
+	return annotation.Annotations{{
+		Name:   "verbatim",
+		Values: map[string]string{"language": "comment", "text": strings.Join(comments, "\n")},
+	}}
+}
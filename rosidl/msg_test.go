@@ -0,0 +1,121 @@
+package rosidl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/printer"
+)
+
+const imuMsg = `# This is a message to hold data from an IMU
+
+int8 STATUS_NO_FIX=-1 # unable to fix
+string FRAME="imu"
+
+Header header
+# Row major about x, y, z axes
+float64[9] orientation_covariance
+geometry_msgs/Vector3 angular_velocity
+float64[9] linear_acceleration_covariance
+uint8[<=4] flags
+string<=8 label "base"
+bool valid true
+float32[] samples
+`
+
+const imuIDL = `module sensor_msgs {
+	module msg {
+		typedef double double__9[9];
+
+		module Imu_Constants {
+			@verbatim(language=comment, text="unable to fix") const int8 STATUS_NO_FIX = -1;
+
+			const string FRAME = "imu";
+		};
+
+		@verbatim(language=comment, text="This is a message to hold data from an IMU") struct Imu {
+			std_msgs::msg::Header header;
+			@verbatim(language=comment, text="Row major about x, y, z axes") double__9 orientation_covariance;
+			geometry_msgs::msg::Vector3 angular_velocity;
+			double__9 linear_acceleration_covariance;
+			sequence<octet, 4> flags;
+			@default(base) string<8> label;
+			@default(TRUE) boolean valid;
+			sequence<float> samples;
+		};
+	};
+}
+`
+
+func TestParseMsg(t *testing.T) {
+	m, err := ParseMsg("sensor_msgs", "Imu", imuMsg)
+	require.NoError(t, err)
+	out := printer.Print(m)
+	require.Equal(t, imuIDL, string(out))
+
+	result := ast.Parse(string(out))
+	require.Nil(t, result.Err)
+	require.Equal(t, out, printer.Print(result.Output))
+}
+
+func TestParseMsgEmpty(t *testing.T) {
+	m, err := ParseMsg("std_msgs", "Empty", "# nothing here\n")
+	require.NoError(t, err)
+	require.Equal(t, `module std_msgs {
+	module msg {
+		@verbatim(language=comment, text="nothing here") struct Empty {
+			octet structure_needs_at_least_one_member;
+		};
+	};
+}
+`, string(printer.Print(m)))
+}
+
+func TestParseSrv(t *testing.T) {
+	m, err := ParseSrv("std_srvs", "SetBool", "bool data # e.g. for hardware enabling / disabling\n---\nbool success\nstring message\n")
+	require.NoError(t, err)
+	require.Equal(t, `module std_srvs {
+	module srv {
+		struct SetBool_Request {
+			@verbatim(language=comment, text="e.g. for hardware enabling / disabling") boolean data;
+		};
+
+		struct SetBool_Response {
+			boolean success;
+			string message;
+		};
+	};
+}
+`, string(printer.Print(m)))
+
+	_, err = ParseSrv("std_srvs", "Trigger", "bool success\n")
+	require.EqualError(t, err, `service Trigger has no "---" separator`)
+}
+
+func TestParseMsgErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"int32", `Msg line 1: expect a type and a name, got "int32"`},
+		{"int32 Value", `Msg line 1: invalid field name "Value"`},
+		{"int32 a\nint32 a", "Msg line 2: field a is declared twice"},
+		{"int32 lower=1", `Msg line 1: invalid constant name "lower"`},
+		{"int32[2] A=1", "Msg line 1: constant A cannot be an array"},
+		{"Point P=1", "Msg line 1: constant P must have a primitive type"},
+		{"bool B=yes", `Msg line 1: constant B: invalid boolean "yes"`},
+		{"wstring w", "Msg line 1: wstring is not supported"},
+		{"int32[0] a", `Msg line 1: invalid array size in "int32[0]"`},
+		{"int32[<=x] a", `Msg line 1: invalid array bound in "int32[<=x]"`},
+		{"string<=0 s", `Msg line 1: invalid string bound in "string<=0"`},
+		{"my-pkg/Point p", `Msg line 1: invalid type "my-pkg/Point"`},
+	}
+	for _, test := range tests {
+		_, err := ParseMsg("pkg", "Msg", test.src)
+		require.EqualError(t, err, test.err, test.src)
+	}
+	_, err := ParseMsg("pkg", "bad name", "int32 a")
+	require.EqualError(t, err, `invalid message name "bad name"`)
+}
@@ -0,0 +1,200 @@
+package sema
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/yisaer/idl-parser/ast"
+)
+
+// positions maps the scoped names declared in a source to where their names
+// appear: definitions as "spi::CANFrame" and members as "spi::CANFrame.id".
+// A name declared more than once has one position per declaration, handed
+// out in source order.
+type positions struct {
+	byName map[string][]Position
+}
+
+func (p *positions) take(name string) Position {
+	list := p.byName[name]
+	if len(list) == 0 {
+		return Position{}
+	}
+	p.byName[name] = list[1:]
+	return list[0]
+}
+
+// parsedFrom reports whether src is the text module was parsed from, either
+// as a single module or as top-level modules merged together. locate reads
+// names off the text, so any other source would misplace them.
+func parsedFrom(module ast.Module, src string) bool {
+	if src == "" {
+		return false
+	}
+	if res := ast.Parse(src); res.Err == nil && reflect.DeepEqual(res.Output, module) {
+		return true
+	}
+	parsed, err := ast.ParseSchema(src)
+	return err == nil && reflect.DeepEqual(parsed, module)
+}
+
+type token struct {
+	text string
+	pos  Position
+}
+
+// headers are the keywords that open a named scope with "{".
+var headers = map[string]bool{"module": true, "struct": true, "union": true, "enum": true, "bitset": true}
+
+// locate scans src the way the parser reads it. A declaration ends at "{"
+// (a definition header, named by the identifier after its keyword), at ";"
+// or "," outside brackets, or at "}", which also closes the innermost
+// scope. Other declarations are named by their last identifier outside
+// brackets and before any "=", skipping annotation names.
+func locate(src string) *positions {
+	p := &positions{byName: make(map[string][]Position)}
+	var scopes []string
+	var types []bool
+	var item []token
+	depth := 0
+	add := func(name string, pos Position) {
+		p.byName[name] = append(p.byName[name], pos)
+	}
+	scope := func() string {
+		return strings.Join(scopes, "::")
+	}
+	finish := func() {
+		if len(item) == 0 {
+			return
+		}
+		if tok, ok := declared(item); ok {
+			if len(types) > 0 && types[len(types)-1] {
+				add(scope()+"."+tok.text, tok.pos)
+			} else {
+				add(join(scope(), tok.text), tok.pos)
+			}
+		}
+		item = nil
+	}
+	for _, tok := range tokenize(src) {
+		switch tok.text {
+		case "(", "<", "[":
+			depth++
+		case ")", ">", "]":
+			depth--
+		}
+		if depth > 0 || tok.text == ")" || tok.text == ">" || tok.text == "]" {
+			item = append(item, token{text: "(", pos: tok.pos})
+			continue
+		}
+		switch tok.text {
+		case "{":
+			var name token
+			keyword := ""
+			for i, t := range item {
+				if headers[t.text] && i+1 < len(item) {
+					keyword, name = t.text, item[i+1]
+					break
+				}
+			}
+			if keyword != "" {
+				add(join(scope(), name.text), name.pos)
+			}
+			scopes = append(scopes, name.text)
+			types = append(types, keyword != "module")
+			item = nil
+		case ";", ",":
+			finish()
+		case "}":
+			finish()
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+				types = types[:len(types)-1]
+			}
+		default:
+			item = append(item, tok)
+		}
+	}
+	return p
+}
+
+// declared returns the identifier an item declares.
+func declared(item []token) (token, bool) {
+	var name token
+	found := false
+	for i, t := range item {
+		if t.text == "=" {
+			break
+		}
+		if !isIdentifier(t.text) || i > 0 && item[i-1].text == "@" {
+			continue
+		}
+		if i > 0 && headers[item[i-1].text] {
+			return t, true
+		}
+		name, found = t, true
+	}
+	return name, found
+}
+
+func isIdentifier(s string) bool {
+	return s != "" && (s[0] == '_' || unicode.IsLetter(rune(s[0])))
+}
+
+// tokenize splits src into identifiers or numbers, quoted strings and
+// single punctuation characters, dropping comments and preprocessor lines.
+func tokenize(src string) []token {
+	var tokens []token
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "//") || c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 4
+			}
+			block := src[i : i+end+4]
+			if n := strings.Count(block, "\n"); n > 0 {
+				line, lineStart = line+n, i+strings.LastIndex(block, "\n")+1
+			}
+			i += len(block)
+			continue
+		case c == '"':
+			for i++; i < len(src) && src[i] != '"' && src[i] != '\n'; i++ {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] != '\n' {
+					i++
+				}
+			}
+			if i < len(src) && src[i] == '"' {
+				i++
+			}
+		case c == '_' || isWord(rune(c)):
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || isWord(rune(src[i]))) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, token{text: src[start:i], pos: Position{Line: line, Column: start - lineStart + 1}})
+	}
+	return tokens
+}
+
+func isWord(r rune) bool {
+	return r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,391 @@
+// Package sema performs the semantic analysis the parser leaves out: it
+// builds the scoped symbols of a schema, resolves every type reference to its
+// definition and reports duplicate definitions and members, unresolved type
+// names, forward declarations without a matching definition, inheritance
+// cycles and bitfields wider than 64 bits.
+package sema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/bitset"
+	"github.com/yisaer/idl-parser/ast/const_type"
+	"github.com/yisaer/idl-parser/ast/enum_type"
+	"github.com/yisaer/idl-parser/ast/forward_type"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typedef_type"
+	"github.com/yisaer/idl-parser/ast/typeref"
+	"github.com/yisaer/idl-parser/ast/union_type"
+)
+
+// maxBitFieldWidth is the widest bitfield, the size of the largest integer
+// that can hold it.
+const maxBitFieldWidth = 64
+
+// Symbol is one named definition of a schema.
+type Symbol struct {
+	// Name is the scoped name without leading "::", e.g. spi::CANFrame.
+	Name string
+	// Def is the definition; for a module declared more than once it is the
+	// first declaration. A forward declared struct or union has its
+	// definition here, not the forward declaration.
+	Def ast.ModuleContent
+	Pos Position
+}
+
+// Reference is a type name used by a definition together with the symbol it
+// resolves to.
+type Reference struct {
+	// From names the user: a definition such as spi::Id or one of its
+	// members such as spi::CANFrame.id.
+	From   string
+	Name   string
+	Symbol *Symbol
+	Pos    Position
+}
+
+// Table holds the symbols of a schema in declaration order and the resolved
+// type references.
+type Table struct {
+	symbols    []*Symbol
+	byName     map[string]*Symbol
+	References []Reference
+}
+
+// Symbols returns every symbol in declaration order.
+func (t *Table) Symbols() []*Symbol {
+	return t.symbols
+}
+
+// Lookup returns the symbol of a fully scoped name; a leading "::" is
+// optional.
+func (t *Table) Lookup(name string) (*Symbol, bool) {
+	s, ok := t.byName[strings.TrimPrefix(name, "::")]
+	return s, ok
+}
+
+// Resolve looks up name as written inside the scope named scope, following
+// the IDL rules: the first component is searched in scope and then in each
+// enclosing scope, and the remaining components inside the definition found.
+// A leading "::" starts at the global scope.
+func (t *Table) Resolve(scope, name string) (*Symbol, error) {
+	parts := strings.Split(name, "::")
+	if parts[0] == "" {
+		return t.descend("", parts[1:], name)
+	}
+	for cur := scope; ; cur = parentScope(cur) {
+		if _, ok := t.byName[join(cur, parts[0])]; ok {
+			return t.descend(cur, parts, name)
+		}
+		if cur == "" {
+			return nil, fmt.Errorf("type %v not found", name)
+		}
+	}
+}
+
+// descend looks up parts one by one starting in scope; every component but
+// the last must name a module.
+func (t *Table) descend(scope string, parts []string, name string) (*Symbol, error) {
+	var s *Symbol
+	for _, part := range parts {
+		if s != nil {
+			if s.Def.ModuleContentType() != typ.ModuleType {
+				return nil, fmt.Errorf("%v in %v is not a module", s.Def.GetName(), name)
+			}
+			scope = s.Name
+		}
+		var ok bool
+		if s, ok = t.byName[join(scope, part)]; !ok {
+			return nil, fmt.Errorf("type %v not found", name)
+		}
+	}
+	return s, nil
+}
+
+func join(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "::" + name
+}
+
+func parentScope(scope string) string {
+	if i := strings.LastIndex(scope, "::"); i >= 0 {
+		return scope[:i]
+	}
+	return ""
+}
+
+// Position is a line and column in the source, both counted from 1. The zero
+// Position stands for an unknown location.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%v:%v", p.Line, p.Column)
+}
+
+// Error is one semantic error, located at the name of the definition or
+// member it concerns.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Msg
+	}
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Check analyses module and returns its symbol table together with every
+// semantic error found, in source order. src is the text module was parsed
+// from and is used to locate the errors. Positions are only looked up when
+// src parses to module itself; a module that was built, merged or loaded
+// from several files otherwise has errors that carry no position.
+func Check(module ast.Module, src string) (*Table, []*Error) {
+	c := &checker{
+		table: &Table{byName: make(map[string]*Symbol)},
+		pos:   &positions{byName: make(map[string][]Position)},
+	}
+	if parsedFrom(module, src) {
+		c.pos = locate(src)
+	}
+	if module.Name == "" {
+		c.declare("", module.Content)
+		c.check("", module.Content)
+	} else {
+		c.declare("", []ast.ModuleContent{module})
+		c.check("", []ast.ModuleContent{module})
+	}
+	for _, s := range c.table.symbols {
+		if f, ok := s.Def.(forward_type.Forward); ok {
+			c.errorf(s.Pos, "%v %v is declared but not defined", f.Kind, s.Name)
+		}
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i].Pos, c.errs[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.table, c.errs
+}
+
+type checker struct {
+	table *Table
+	pos   *positions
+	errs  []*Error
+	// defs holds the position of each definition in the order declare
+	// visits them, which check follows.
+	defs []Position
+	next int
+}
+
+func (c *checker) errorf(pos Position, format string, args ...interface{}) {
+	c.errs = append(c.errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// declare adds the symbols of contents, declared in scope, and of nested
+// modules, reporting names that are defined twice. A module may be reopened.
+func (c *checker) declare(scope string, contents []ast.ModuleContent) {
+	for _, con := range contents {
+		name := join(scope, con.GetName())
+		pos := c.pos.take(name)
+		c.defs = append(c.defs, pos)
+		prev, ok := c.table.byName[name]
+		_, forward := con.(forward_type.Forward)
+		_, prevForward := prev.forward()
+		switch {
+		case !ok:
+			s := &Symbol{Name: name, Def: con, Pos: pos}
+			c.table.symbols = append(c.table.symbols, s)
+			c.table.byName[name] = s
+		case forward || prevForward:
+			if kind(con) != kind(prev.Def) {
+				c.errorf(pos, "%v is declared as %v and %v", name, kind(prev.Def), kind(con))
+			}
+			if !forward {
+				prev.Def, prev.Pos = con, pos
+			}
+		case prev.Def.ModuleContentType() != typ.ModuleType || con.ModuleContentType() != typ.ModuleType:
+			c.errorf(pos, "%v is defined more than once", name)
+		}
+		if m, ok := con.(ast.Module); ok {
+			c.declare(name, m.Content)
+		}
+	}
+}
+
+// forward returns the forward declaration of a symbol not defined so far.
+func (s *Symbol) forward() (forward_type.Forward, bool) {
+	if s == nil {
+		return forward_type.Forward{}, false
+	}
+	f, ok := s.Def.(forward_type.Forward)
+	return f, ok
+}
+
+// kind names the kind of a definition as written in IDL, e.g. struct.
+func kind(con ast.ModuleContent) string {
+	if f, ok := con.(forward_type.Forward); ok {
+		return f.Kind
+	}
+	return strings.ToLower(typ.ModuleContentTypeToString(con.ModuleContentType()))
+}
+
+// check verifies the members and type references of contents once every
+// symbol is declared, so that a type may be used before its definition.
+func (c *checker) check(scope string, contents []ast.ModuleContent) {
+	for _, con := range contents {
+		name := join(scope, con.GetName())
+		pos := c.defs[c.next]
+		c.next++
+		switch def := con.(type) {
+		case ast.Module:
+			c.check(name, def.Content)
+		case struct_type.Struct:
+			members := c.members("struct", name)
+			for _, base := range c.bases(scope, name, def, pos) {
+				for _, f := range base.Def.(struct_type.Struct).Fields {
+					members.inherit(f.Name, base.Name)
+				}
+			}
+			for _, f := range def.Fields {
+				c.typeRef(scope, name+"."+f.Name, f.Type, members.add(f.Name))
+			}
+		case union_type.Union:
+			c.typeRef(scope, name, def.Discriminator, pos)
+			members := c.members("union", name)
+			for _, cs := range def.Cases {
+				c.typeRef(scope, name+"."+cs.Field.Name, cs.Field.Type, members.add(cs.Field.Name))
+			}
+		case enum_type.Enum:
+			members := c.members("enum", name)
+			for _, m := range def.Members {
+				members.add(m)
+			}
+		case bitset.BitSet:
+			members := c.members("bitset", name)
+			for _, f := range def.Fields {
+				c.bitField(name+"."+f.Name, f.Type, members.add(f.Name))
+			}
+		case typedef_type.Typedef:
+			c.typeRef(scope, name, def.Aliased, pos)
+		case const_type.Const:
+			c.typeRef(scope, name, def.ValueType, pos)
+		}
+	}
+}
+
+// bases resolves the base of the struct name, declared in scope, and
+// returns the structs it inherits from, the nearest first. It reports a base
+// that cannot be resolved or is not a struct, and a cycle through name;
+// problems further up the hierarchy are left to the structs they concern.
+func (c *checker) bases(scope, name string, st struct_type.Struct, pos Position) []*Symbol {
+	var chain []*Symbol
+	seen := map[string]bool{name: true}
+	for st.Base != "" {
+		s, err := c.table.Resolve(scope, st.Base)
+		base, ok := struct_type.Struct{}, false
+		if err == nil {
+			base, ok = s.Def.(struct_type.Struct)
+		}
+		if len(chain) == 0 {
+			switch {
+			case err != nil:
+				c.errorf(pos, "%v: base %v", name, err)
+			case !ok:
+				c.errorf(pos, "%v: base %v is not a struct", name, st.Base)
+			default:
+				c.table.References = append(c.table.References, Reference{From: name, Name: st.Base, Symbol: s, Pos: pos})
+			}
+		}
+		if !ok {
+			return chain
+		}
+		if seen[s.Name] {
+			if s.Name == name {
+				c.errorf(pos, "struct %v inherits from itself", name)
+			}
+			return chain
+		}
+		seen[s.Name] = true
+		chain = append(chain, s)
+		scope, st = parentScope(s.Name), base
+	}
+	return chain
+}
+
+// memberSet reports members declared twice in one definition or declared
+// again after being inherited.
+type memberSet struct {
+	c     *checker
+	kind  string
+	owner string
+	// seen maps each member to the base it is inherited from, or to "" for
+	// a member of the definition itself.
+	seen map[string]string
+}
+
+func (c *checker) members(kind, owner string) *memberSet {
+	return &memberSet{c: c, kind: kind, owner: owner, seen: make(map[string]string)}
+}
+
+// inherit records member name of base.
+func (m *memberSet) inherit(name, base string) {
+	if _, ok := m.seen[name]; !ok {
+		m.seen[name] = base
+	}
+}
+
+// add records member name and returns its position.
+func (m *memberSet) add(name string) Position {
+	pos := m.c.pos.take(m.owner + "." + name)
+	if base, ok := m.seen[name]; ok {
+		if base != "" {
+			m.c.errorf(pos, "%v %v: member %v is already declared in base %v", m.kind, m.owner, name, base)
+		} else {
+			m.c.errorf(pos, "%v %v: member %v is declared more than once", m.kind, m.owner, name)
+		}
+	}
+	m.seen[name] = ""
+	return pos
+}
+
+// typeRef resolves every type name within t, used by from.
+func (c *checker) typeRef(scope, from string, t typeref.TypeRef, pos Position) {
+	switch t := t.(type) {
+	case typeref.Sequence:
+		c.typeRef(scope, from, t.InnerType, pos)
+	case typeref.BitFieldType:
+		c.bitField(from, t, pos)
+	case typeref.TypeName:
+		s, err := c.table.Resolve(scope, t.Name)
+		if err != nil {
+			c.errorf(pos, "%v: %v", from, err)
+			return
+		}
+		switch s.Def.ModuleContentType() {
+		case typ.ModuleType, typ.ConstType:
+			c.errorf(pos, "%v: %v is not a type", from, t.Name)
+			return
+		}
+		c.table.References = append(c.table.References, Reference{From: from, Name: t.Name, Symbol: s, Pos: pos})
+	}
+}
+
+func (c *checker) bitField(from string, t typeref.BitFieldType, pos Position) {
+	if t.Width > maxBitFieldWidth {
+		c.errorf(pos, "%v: bitfield width %v exceeds %v", from, t.Width, maxBitFieldWidth)
+	}
+}
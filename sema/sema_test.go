@@ -0,0 +1,278 @@
+package sema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yisaer/idl-parser/ast"
+	"github.com/yisaer/idl-parser/ast/struct_type"
+	"github.com/yisaer/idl-parser/ast/typ"
+	"github.com/yisaer/idl-parser/ast/typeref"
+)
+
+func parse(t *testing.T, code string) ast.Module {
+	result := ast.Parse(code)
+	require.Nil(t, result.Err)
+	return result.Output
+}
+
+const validSchema = `module spi {
+	enum Status { OK, FAILED };
+	typedef double Matrix[3][3];
+	module can {
+		struct Id {
+			octet bid;
+			unsigned short cid;
+		};
+	};
+	struct CANFrame {
+		@id(1) can::Id id;
+		sequence<::spi::can::Id, 4> history;
+		Status status;
+		Matrix m;
+		Later later;
+	};
+	union Value switch (Status) {
+		case OK: can::Id id;
+		default: string reason;
+	};
+	struct Later {
+		long x;
+	};
+	const Status DEFAULT = OK;
+};`
+
+func TestCheck(t *testing.T) {
+	table, errs := Check(parse(t, validSchema), validSchema)
+	require.Empty(t, errs)
+
+	var names []string
+	for _, s := range table.Symbols() {
+		names = append(names, s.Name)
+	}
+	require.Equal(t, []string{
+		"spi", "spi::Status", "spi::Matrix", "spi::can", "spi::can::Id",
+		"spi::CANFrame", "spi::Value", "spi::Later", "spi::DEFAULT",
+	}, names)
+
+	s, ok := table.Lookup("::spi::can::Id")
+	require.True(t, ok)
+	require.Equal(t, Position{Line: 5, Column: 10}, s.Pos)
+	require.Equal(t, typ.StructType, s.Def.ModuleContentType())
+
+	var refs []string
+	for _, ref := range table.References {
+		refs = append(refs, ref.From+" "+ref.Name+" -> "+ref.Symbol.Name+" at "+ref.Pos.String())
+	}
+	require.Equal(t, []string{
+		"spi::CANFrame.id can::Id -> spi::can::Id at 11:18",
+		"spi::CANFrame.history ::spi::can::Id -> spi::can::Id at 12:31",
+		"spi::CANFrame.status Status -> spi::Status at 13:10",
+		"spi::CANFrame.m Matrix -> spi::Matrix at 14:10",
+		"spi::CANFrame.later Later -> spi::Later at 15:9",
+		"spi::Value Status -> spi::Status at 17:8",
+		"spi::Value.id can::Id -> spi::can::Id at 18:20",
+		"spi::DEFAULT Status -> spi::Status at 24:15",
+	}, refs)
+}
+
+func TestResolve(t *testing.T) {
+	table, errs := Check(parse(t, validSchema), "")
+	require.Empty(t, errs)
+	tests := []struct {
+		scope string
+		name  string
+		found string
+		err   string
+	}{
+		{"spi::can", "Id", "spi::can::Id", ""},
+		{"spi::can", "Status", "spi::Status", ""},
+		{"spi", "can::Id", "spi::can::Id", ""},
+		{"", "spi::can::Id", "spi::can::Id", ""},
+		{"spi", "::can::Id", "", "type ::can::Id not found"},
+		{"spi", "Status::OK", "", "Status in Status::OK is not a module"},
+		{"spi", "can::Missing", "", "type can::Missing not found"},
+	}
+	for _, test := range tests {
+		s, err := table.Resolve(test.scope, test.name)
+		if test.err != "" {
+			require.EqualError(t, err, test.err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.found, s.Name)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	code := `module m {
+	struct A {
+		long a;
+		// a comment with struct B { long a; };
+		Missing b;
+		short a;
+	};
+	enum A { X, Y, X };
+	bitset Flags {
+		bitfield<65> wide;
+		bitfield<3> narrow;
+	};
+	union U switch (long) {
+		case 1: long v;
+		case 2: string v;
+	};
+	typedef Nowhere Alias;
+	const long C = 1;
+	struct B {
+		C c;
+		m::A::x y;
+		bitfield<80> raw;
+	};
+	module A {};
+	module n {};
+	module n {};
+};`
+	_, errs := Check(parse(t, code), code)
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	require.Equal(t, []string{
+		"5:11: m::A.b: type Missing not found",
+		"6:9: struct m::A: member a is declared more than once",
+		"8:7: m::A is defined more than once",
+		"8:17: enum m::A: member X is declared more than once",
+		"10:16: m::Flags.wide: bitfield width 65 exceeds 64",
+		"15:18: union m::U: member v is declared more than once",
+		"17:18: m::Alias: type Nowhere not found",
+		"20:5: m::B.c: C is not a type",
+		"21:11: m::B.y: A in m::A::x is not a module",
+		"22:16: m::B.raw: bitfield width 80 exceeds 64",
+		"24:9: m::A is defined more than once",
+	}, got)
+}
+
+func TestCheckWithoutSource(t *testing.T) {
+	module := ast.Module{
+		Name: "m",
+		Type: typ.ModuleContentTypeToString(typ.ModuleType),
+		Content: []ast.ModuleContent{
+			struct_type.Struct{
+				Name: "S",
+				Fields: []struct_type.Field{
+					{Type: typeref.TypeName{Name: "T", SelfType: "T"}, Name: "t"},
+				},
+				Type: typ.ModuleContentTypeToString(typ.StructType),
+			},
+		},
+	}
+	_, errs := Check(module, "")
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "m::S.t: type T not found")
+}
+
+func TestCheckMerged(t *testing.T) {
+	module := ast.Merge(parse(t, `module a { struct X { long v; }; };`), parse(t, `module b { struct Y { a::X x; }; };`))
+	table, errs := Check(module, "")
+	require.Empty(t, errs)
+	require.Len(t, table.References, 1)
+	require.Equal(t, "a::X", table.References[0].Symbol.Name)
+}
+
+func TestCheckSourcePositions(t *testing.T) {
+	code := `module a { struct X { T t; }; };`
+	other := `module b { struct Y { long v; }; };`
+	// Only the text a module was parsed from locates its errors.
+	_, errs := Check(ast.Merge(parse(t, code), parse(t, other)), code)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "a::X.t: type T not found")
+
+	reopened := `module a { struct X { long v; }; };
+module a { struct Y { T t; }; };`
+	module, err := ast.ParseSchema(reopened)
+	require.NoError(t, err)
+	_, errs = Check(module, reopened)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], "2:25: a::Y.t: type T not found")
+}
+
+func TestCheckForward(t *testing.T) {
+	code := `module m {
+	struct Node;
+	struct Node {
+		sequence<Node> children;
+	};
+	struct X;
+	union U;
+	struct U {
+		long v;
+	};
+};`
+	table, errs := Check(parse(t, code), code)
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	require.Equal(t, []string{
+		"6:9: struct m::X is declared but not defined",
+		"8:9: m::U is declared as union and struct",
+	}, got)
+
+	s, ok := table.Lookup("m::Node")
+	require.True(t, ok)
+	require.Equal(t, Position{Line: 3, Column: 9}, s.Pos)
+	require.Equal(t, typ.StructType, s.Def.ModuleContentType())
+	require.Len(t, table.References, 1)
+	require.Equal(t, "m::Node", table.References[0].Symbol.Name)
+}
+
+func TestCheckInheritance(t *testing.T) {
+	code := `module m {
+	struct Base {
+		long id;
+	};
+	module n {
+		struct Middle : Base {
+			string name;
+		};
+	};
+	struct Derived : n::Middle {
+		double value;
+		short id;
+	};
+	struct A : B { long a; };
+	struct B : C { long b; };
+	struct C : B { long c; };
+	struct D : Missing { long d; };
+	struct E : Derived::value { long e; };
+	enum Kind { K };
+	struct F : Kind { long f; };
+	struct G : A { long a; };
+};`
+	table, errs := Check(parse(t, code), code)
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	require.Equal(t, []string{
+		"12:9: struct m::Derived: member id is already declared in base m::Base",
+		"15:9: struct m::B inherits from itself",
+		"16:9: struct m::C inherits from itself",
+		"17:9: m::D: base type Missing not found",
+		"18:9: m::E: base Derived in Derived::value is not a module",
+		"20:9: m::F: base Kind is not a struct",
+		"21:22: struct m::G: member a is already declared in base m::A",
+	}, got)
+
+	var refs []string
+	for _, ref := range table.References {
+		if ref.From == "m::n::Middle" || ref.From == "m::Derived" {
+			refs = append(refs, ref.From+" "+ref.Name+" -> "+ref.Symbol.Name+" at "+ref.Pos.String())
+		}
+	}
+	require.Equal(t, []string{
+		"m::n::Middle Base -> m::Base at 6:10",
+		"m::Derived n::Middle -> m::n::Middle at 10:9",
+	}, refs)
+}
@@ -0,0 +1,207 @@
+// Package wire holds the primitive encoders and decoders for the binary
+// format understood by the converter package. Generated code imports it so
+// that MarshalIDL/UnmarshalIDL stay byte-compatible with IDLConverter.
+//
+// All values are big-endian without padding. Strings and sequences carry a
+// 4-byte unsigned length prefix unless the schema selects another prefix
+// size with @length_prefix.
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+func AppendInt8(b []byte, v int8) []byte {
+	return append(b, uint8(v))
+}
+
+func AppendUint8(b []byte, v uint8) []byte {
+	return append(b, v)
+}
+
+func AppendInt16(b []byte, v int16) []byte {
+	return binary.BigEndian.AppendUint16(b, uint16(v))
+}
+
+func AppendUint16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+func AppendInt32(b []byte, v int32) []byte {
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
+
+func AppendUint32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func AppendInt64(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+func AppendUint64(b []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func AppendFloat32(b []byte, v float32) []byte {
+	return binary.BigEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+func AppendFloat64(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func AppendLength(b []byte, n int) ([]byte, error) {
+	return AppendLengthN(b, n, 4)
+}
+
+// AppendLengthN appends a length prefix of size bytes (1, 2, 4 or 8). It
+// fails when n does not fit the prefix.
+func AppendLengthN(b []byte, n, size int) ([]byte, error) {
+	if n < 0 || size < 8 && uint64(n) >= 1<<(size*8) {
+		return nil, fmt.Errorf("length %v does not fit a %v-byte prefix", n, size)
+	}
+	switch size {
+	case 1:
+		return append(b, uint8(n)), nil
+	case 2:
+		return binary.BigEndian.AppendUint16(b, uint16(n)), nil
+	case 8:
+		return binary.BigEndian.AppendUint64(b, uint64(n)), nil
+	}
+	return binary.BigEndian.AppendUint32(b, uint32(n)), nil
+}
+
+func AppendString(b []byte, v string) ([]byte, error) {
+	return AppendStringN(b, v, 4)
+}
+
+func AppendStringN(b []byte, v string, size int) ([]byte, error) {
+	b, err := AppendLengthN(b, len(v), size)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, v...), nil
+}
+
+func need(data []byte, n int) error {
+	if len(data) < n {
+		return fmt.Errorf("expect data len %v got len %v", n, len(data))
+	}
+	return nil
+}
+
+func ReadInt8(data []byte) (int8, []byte, error) {
+	v, remained, err := ReadUint8(data)
+	return int8(v), remained, err
+}
+
+func ReadUint8(data []byte) (uint8, []byte, error) {
+	if err := need(data, 1); err != nil {
+		return 0, nil, err
+	}
+	return data[0], data[1:], nil
+}
+
+func ReadInt16(data []byte) (int16, []byte, error) {
+	v, remained, err := ReadUint16(data)
+	return int16(v), remained, err
+}
+
+func ReadUint16(data []byte) (uint16, []byte, error) {
+	if err := need(data, 2); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint16(data), data[2:], nil
+}
+
+func ReadInt32(data []byte) (int32, []byte, error) {
+	v, remained, err := ReadUint32(data)
+	return int32(v), remained, err
+}
+
+func ReadUint32(data []byte) (uint32, []byte, error) {
+	if err := need(data, 4); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint32(data), data[4:], nil
+}
+
+func ReadInt64(data []byte) (int64, []byte, error) {
+	v, remained, err := ReadUint64(data)
+	return int64(v), remained, err
+}
+
+func ReadUint64(data []byte) (uint64, []byte, error) {
+	if err := need(data, 8); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint64(data), data[8:], nil
+}
+
+func ReadBool(data []byte) (bool, []byte, error) {
+	v, remained, err := ReadUint8(data)
+	return v != 0, remained, err
+}
+
+func ReadFloat32(data []byte) (float32, []byte, error) {
+	v, remained, err := ReadUint32(data)
+	return math.Float32frombits(v), remained, err
+}
+
+func ReadFloat64(data []byte) (float64, []byte, error) {
+	v, remained, err := ReadUint64(data)
+	return math.Float64frombits(v), remained, err
+}
+
+// ReadLength reads a sequence or string length prefix. Every element takes
+// at least one byte, so lengths exceeding the remaining data are rejected
+// before the caller allocates.
+func ReadLength(data []byte) (int, []byte, error) {
+	return ReadLengthN(data, 4)
+}
+
+// ReadLengthN reads a length prefix of size bytes (1, 2, 4 or 8).
+func ReadLengthN(data []byte, size int) (int, []byte, error) {
+	if err := need(data, size); err != nil {
+		return 0, nil, err
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(data[0])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(data))
+	case 8:
+		n = binary.BigEndian.Uint64(data)
+	default:
+		size = 4
+		n = uint64(binary.BigEndian.Uint32(data))
+	}
+	remained := data[size:]
+	if n > uint64(len(remained)) {
+		return 0, nil, fmt.Errorf("length %v exceeds remaining data len %v", n, len(remained))
+	}
+	return int(n), remained, nil
+}
+
+func ReadString(data []byte) (string, []byte, error) {
+	return ReadStringN(data, 4)
+}
+
+func ReadStringN(data []byte, size int) (string, []byte, error) {
+	n, remained, err := ReadLengthN(data, size)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(remained[:n]), remained[n:], nil
+}
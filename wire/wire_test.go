@@ -0,0 +1,80 @@
+package wire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var b []byte
+	b = AppendUint8(b, 0xFE)
+	b = AppendInt16(b, -12345)
+	b = AppendUint32(b, 4294967295)
+	b = AppendUint64(b, 18446744073709551615)
+	b = AppendBool(b, true)
+	b = AppendFloat32(b, -2.5)
+	b, err := AppendString(b, "hello")
+	require.NoError(t, err)
+	b = AppendInt8(b, -7)
+	b = AppendFloat64(b, 0.1)
+
+	u8, b, err := ReadUint8(b)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0xFE), u8)
+	i16, b, err := ReadInt16(b)
+	require.NoError(t, err)
+	require.Equal(t, int16(-12345), i16)
+	u32, b, err := ReadUint32(b)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4294967295), u32)
+	u64, b, err := ReadUint64(b)
+	require.NoError(t, err)
+	require.Equal(t, uint64(18446744073709551615), u64)
+	bl, b, err := ReadBool(b)
+	require.NoError(t, err)
+	require.True(t, bl)
+	f, b, err := ReadFloat32(b)
+	require.NoError(t, err)
+	require.Equal(t, float32(-2.5), f)
+	s, b, err := ReadString(b)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+	i8, b, err := ReadInt8(b)
+	require.NoError(t, err)
+	require.Equal(t, int8(-7), i8)
+	f64, b, err := ReadFloat64(b)
+	require.NoError(t, err)
+	require.Equal(t, 0.1, f64)
+	require.Empty(t, b)
+}
+
+func TestReadLengthExceedsData(t *testing.T) {
+	_, _, err := ReadLength([]byte{0xFF, 0xFF, 0xFF, 0xFF, 1, 2})
+	require.Error(t, err)
+
+	_, _, err = ReadUint16([]byte{1})
+	require.Error(t, err)
+}
+
+func TestLengthPrefixSizes(t *testing.T) {
+	for _, size := range []int{1, 2, 4, 8} {
+		b, err := AppendStringN(nil, "abc", size)
+		require.NoError(t, err)
+		require.Len(t, b, size+3)
+		s, remained, err := ReadStringN(b, size)
+		require.NoError(t, err)
+		require.Equal(t, "abc", s)
+		require.Empty(t, remained)
+	}
+	_, _, err := ReadLengthN([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 1}, 8)
+	require.Error(t, err)
+
+	b, err := AppendLengthN(nil, 255, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xFF}, b)
+	_, err = AppendLengthN(nil, 300, 1)
+	require.EqualError(t, err, "length 300 does not fit a 1-byte prefix")
+	_, err = AppendStringN(nil, string(make([]byte, 1<<16)), 2)
+	require.EqualError(t, err, "length 65536 does not fit a 2-byte prefix")
+}